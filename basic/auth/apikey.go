@@ -0,0 +1,43 @@
+package auth
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+
+	"google.golang.org/grpc/metadata"
+)
+
+type apiKeyAuthenticator struct {
+	// key为api key的sha256，避免在内存中比较明文
+	keys map[string]Principal
+}
+
+// NewAPIKeyAuthenticator 校验 x-api-key 中的api key，keys的key为api key，value为对应的身份，
+// 身份的Type会被设置为apikey
+func NewAPIKeyAuthenticator(keys map[string]Principal) Authenticator {
+	a := &apiKeyAuthenticator{keys: make(map[string]Principal, len(keys))}
+	for key, p := range keys {
+		p.Type = TypeAPIKey
+		a.keys[hashAPIKey(key)] = p
+	}
+	return a
+}
+
+func (a *apiKeyAuthenticator) Authenticate(ctx context.Context) (*Principal, error) {
+	md, _ := metadata.FromIncomingContext(ctx)
+	values := md.Get(apiKeyKey)
+	if len(values) == 0 || values[0] == "" {
+		return nil, ErrNoCredentials
+	}
+	p, ok := a.keys[hashAPIKey(values[0])]
+	if !ok {
+		return nil, unauthenticated("invalid api key")
+	}
+	return &p, nil
+}
+
+func hashAPIKey(key string) string {
+	sum := sha256.Sum256([]byte(key))
+	return hex.EncodeToString(sum[:])
+}
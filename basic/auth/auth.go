@@ -0,0 +1,110 @@
+package auth
+
+import (
+	"context"
+	"errors"
+	"strings"
+
+	"google.golang.org/grpc/metadata"
+	"singer.com/basic/errorx"
+)
+
+const (
+	TypeJWT    = "jwt"
+	TypeAPIKey = "apikey"
+	TypeMTLS   = "mtls"
+
+	authorizationKey = "authorization"
+	apiKeyKey        = "x-api-key"
+	bearerPrefix     = "bearer "
+)
+
+// ErrNoCredentials 请求没有携带该认证方式的凭证，Chain会继续尝试下一种认证方式
+var ErrNoCredentials = errors.New("auth: no credentials")
+
+// Principal 认证通过的调用方身份
+type Principal struct {
+	// 用户id、api key名称或证书的身份
+	Subject string
+	// 认证方式：jwt、apikey或mtls
+	Type   string
+	Roles  []string
+	Scopes []string
+	// jwt的全部claims，其他认证方式为nil
+	Claims map[string]interface{}
+}
+
+// HasRole 判断是否拥有指定角色
+func (p *Principal) HasRole(role string) bool {
+	for _, r := range p.Roles {
+		if r == role {
+			return true
+		}
+	}
+	return false
+}
+
+// HasScope 判断是否拥有指定scope
+func (p *Principal) HasScope(scope string) bool {
+	for _, s := range p.Scopes {
+		if s == scope {
+			return true
+		}
+	}
+	return false
+}
+
+// Authenticator 从请求中提取并校验凭证。请求没有携带对应凭证时返回ErrNoCredentials，
+// 凭证无效时返回errorx错误
+type Authenticator interface {
+	Authenticate(ctx context.Context) (*Principal, error)
+}
+
+type AuthenticatorFunc func(ctx context.Context) (*Principal, error)
+
+func (f AuthenticatorFunc) Authenticate(ctx context.Context) (*Principal, error) {
+	return f(ctx)
+}
+
+// Chain 依次尝试多种认证方式，返回第一个认证通过的身份；凭证无效时直接返回错误，不再尝试后面的认证方式
+func Chain(authenticators ...Authenticator) Authenticator {
+	return AuthenticatorFunc(func(ctx context.Context) (*Principal, error) {
+		for _, a := range authenticators {
+			p, err := a.Authenticate(ctx)
+			if err == ErrNoCredentials {
+				continue
+			}
+			return p, err
+		}
+		return nil, ErrNoCredentials
+	})
+}
+
+type principalKey struct{}
+
+// NewContext 将身份保存到ctx中
+func NewContext(ctx context.Context, p *Principal) context.Context {
+	return context.WithValue(ctx, principalKey{}, p)
+}
+
+// FromContext 返回认证拦截器保存的身份
+func FromContext(ctx context.Context) (*Principal, bool) {
+	p, ok := ctx.Value(principalKey{}).(*Principal)
+	return p, ok
+}
+
+// unauthenticated 返回UNAUTHENTICATED_ERROR，msg只用于日志，不会返回给调用方
+func unauthenticated(msg string) error {
+	return errorx.Wrap(errorx.UNAUTHENTICATED_ERROR, msg)
+}
+
+// bearerToken 返回metadata中 authorization: Bearer token 的token
+func bearerToken(ctx context.Context) string {
+	md, _ := metadata.FromIncomingContext(ctx)
+	for _, v := range md.Get(authorizationKey) {
+		if len(v) > len(bearerPrefix) && strings.EqualFold(v[:len(bearerPrefix)], bearerPrefix) {
+			return strings.TrimSpace(v[len(bearerPrefix):])
+		}
+	}
+	return ""
+}
@@ -0,0 +1,102 @@
+package auth
+
+import (
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"net/url"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"google.golang.org/grpc/credentials"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/peer"
+	"singer.com/basic/errorx"
+)
+
+func TestAPIKey(t *testing.T) {
+	a := NewAPIKeyAuthenticator(map[string]Principal{
+		"key1": {Subject: "job", Roles: []string{"admin"}},
+	})
+	ctx := metadata.NewIncomingContext(context.Background(), metadata.Pairs("x-api-key", "key1"))
+	p, err := a.Authenticate(ctx)
+	assert.Nil(t, err)
+	assert.Equal(t, "job", p.Subject)
+	assert.Equal(t, TypeAPIKey, p.Type)
+	assert.True(t, p.HasRole("admin"))
+
+	ctx = metadata.NewIncomingContext(context.Background(), metadata.Pairs("x-api-key", "key2"))
+	_, err = a.Authenticate(ctx)
+	assert.True(t, errorx.IsCode(err, errorx.UNAUTHENTICATED_ERROR))
+
+	_, err = a.Authenticate(context.Background())
+	assert.Equal(t, ErrNoCredentials, err)
+}
+
+func tlsContext(cert *x509.Certificate) context.Context {
+	info := credentials.TLSInfo{State: tls.ConnectionState{VerifiedChains: [][]*x509.Certificate{{cert}}}}
+	return peer.NewContext(context.Background(), &peer.Peer{AuthInfo: info})
+}
+
+func TestMTLS(t *testing.T) {
+	a := NewMTLSAuthenticator(map[string][]string{"spiffe://singer/order": {"service"}})
+
+	uri, _ := url.Parse("spiffe://singer/order")
+	p, err := a.Authenticate(tlsContext(&x509.Certificate{URIs: []*url.URL{uri}, Subject: pkix.Name{CommonName: "order"}}))
+	assert.Nil(t, err)
+	assert.Equal(t, "spiffe://singer/order", p.Subject)
+	assert.Equal(t, TypeMTLS, p.Type)
+	assert.True(t, p.HasRole("service"))
+
+	p, err = a.Authenticate(tlsContext(&x509.Certificate{Subject: pkix.Name{CommonName: "user"}}))
+	assert.Nil(t, err)
+	assert.Equal(t, "user", p.Subject)
+	assert.Empty(t, p.Roles)
+
+	// 没有校验客户端证书
+	ctx := peer.NewContext(context.Background(), &peer.Peer{AuthInfo: credentials.TLSInfo{}})
+	_, err = a.Authenticate(ctx)
+	assert.Equal(t, ErrNoCredentials, err)
+}
+
+func TestChain(t *testing.T) {
+	a := Chain(
+		NewAPIKeyAuthenticator(map[string]Principal{"key1": {Subject: "job"}}),
+		AuthenticatorFunc(func(ctx context.Context) (*Principal, error) {
+			return &Principal{Subject: "anonymous"}, nil
+		}),
+	)
+	ctx := metadata.NewIncomingContext(context.Background(), metadata.Pairs("x-api-key", "key1"))
+	p, err := a.Authenticate(ctx)
+	assert.Nil(t, err)
+	assert.Equal(t, "job", p.Subject)
+
+	p, err = a.Authenticate(context.Background())
+	assert.Nil(t, err)
+	assert.Equal(t, "anonymous", p.Subject)
+
+	// 凭证无效时不再尝试后面的认证方式
+	ctx = metadata.NewIncomingContext(context.Background(), metadata.Pairs("x-api-key", "key2"))
+	_, err = a.Authenticate(ctx)
+	assert.True(t, errorx.IsCode(err, errorx.UNAUTHENTICATED_ERROR))
+
+	_, err = Chain().Authenticate(context.Background())
+	assert.Equal(t, ErrNoCredentials, err)
+}
+
+func TestTokenCredentials(t *testing.T) {
+	creds := NewTokenCredentials(StaticToken("abc"), false)
+	md, err := creds.GetRequestMetadata(context.Background())
+	assert.Nil(t, err)
+	assert.Equal(t, "Bearer abc", md["authorization"])
+	assert.True(t, creds.RequireTransportSecurity())
+
+	ctx := metadata.NewIncomingContext(context.Background(), metadata.New(md))
+	assert.Equal(t, "abc", bearerToken(ctx))
+
+	creds = NewAPIKeyCredentials("key1", true)
+	md, _ = creds.GetRequestMetadata(context.Background())
+	assert.Equal(t, "key1", md["x-api-key"])
+	assert.False(t, creds.RequireTransportSecurity())
+}
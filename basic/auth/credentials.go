@@ -0,0 +1,47 @@
+package auth
+
+import (
+	"context"
+
+	"google.golang.org/grpc/credentials"
+)
+
+// TokenSource 返回客户端请求携带的token，每次请求都会调用，需要自行缓存和刷新
+type TokenSource func(ctx context.Context) (string, error)
+
+// StaticToken 返回固定的token
+func StaticToken(token string) TokenSource {
+	return func(context.Context) (string, error) {
+		return token, nil
+	}
+}
+
+type perRPCCredentials struct {
+	key           string
+	prefix        string
+	source        TokenSource
+	allowInsecure bool
+}
+
+// NewTokenCredentials 客户端每次请求自动携带 authorization: Bearer token，
+// allowInsecure为false时只在TLS连接上发送
+func NewTokenCredentials(source TokenSource, allowInsecure bool) credentials.PerRPCCredentials {
+	return &perRPCCredentials{key: authorizationKey, prefix: "Bearer ", source: source, allowInsecure: allowInsecure}
+}
+
+// NewAPIKeyCredentials 客户端每次请求自动携带 x-api-key
+func NewAPIKeyCredentials(key string, allowInsecure bool) credentials.PerRPCCredentials {
+	return &perRPCCredentials{key: apiKeyKey, source: StaticToken(key), allowInsecure: allowInsecure}
+}
+
+func (c *perRPCCredentials) GetRequestMetadata(ctx context.Context, uri ...string) (map[string]string, error) {
+	token, err := c.source(ctx)
+	if err != nil {
+		return nil, err
+	}
+	return map[string]string{c.key: c.prefix + token}, nil
+}
+
+func (c *perRPCCredentials) RequireTransportSecurity() bool {
+	return !c.allowInsecure
+}
@@ -0,0 +1,167 @@
+package auth
+
+import (
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rsa"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"math/big"
+	"os"
+	"sync"
+	"time"
+
+	log "github.com/sirupsen/logrus"
+)
+
+// jwks文件修改检查的最小间隔
+const jwksCheckInterval = 10 * time.Second
+
+type jsonWebKey struct {
+	Kty string `json:"kty"`
+	Kid string `json:"kid"`
+	Use string `json:"use"`
+	N   string `json:"n"`
+	E   string `json:"e"`
+	Crv string `json:"crv"`
+	X   string `json:"x"`
+	Y   string `json:"y"`
+}
+
+// jwksFile 本地的JWKS文件，文件修改后在下一次查找公钥时重新加载
+type jwksFile struct {
+	path string
+
+	mtx       sync.RWMutex
+	keys      map[string]crypto.PublicKey
+	modTime   time.Time
+	lastCheck time.Time
+}
+
+func newJWKSFile(path string) (*jwksFile, error) {
+	f := &jwksFile{path: path}
+	if err := f.load(); err != nil {
+		return nil, err
+	}
+	return f, nil
+}
+
+func (f *jwksFile) key(kid string) (crypto.PublicKey, bool) {
+	f.reloadIfModified()
+	f.mtx.RLock()
+	defer f.mtx.RUnlock()
+	key, ok := f.keys[kid]
+	return key, ok
+}
+
+func (f *jwksFile) reloadIfModified() {
+	f.mtx.Lock()
+	if time.Since(f.lastCheck) < jwksCheckInterval {
+		f.mtx.Unlock()
+		return
+	}
+	f.lastCheck = time.Now()
+	modTime := f.modTime
+	f.mtx.Unlock()
+
+	info, err := os.Stat(f.path)
+	if err != nil || info.ModTime().Equal(modTime) {
+		return
+	}
+	if err := f.load(); err != nil {
+		// 加载失败时继续使用之前的公钥
+		log.Errorf("reload jwks file %s failed, err: %v", f.path, err)
+	}
+}
+
+func (f *jwksFile) load() error {
+	info, err := os.Stat(f.path)
+	if err != nil {
+		return err
+	}
+	data, err := os.ReadFile(f.path)
+	if err != nil {
+		return err
+	}
+	keys, err := ParseJWKS(data)
+	if err != nil {
+		return err
+	}
+	f.mtx.Lock()
+	f.keys, f.modTime, f.lastCheck = keys, info.ModTime(), time.Now()
+	f.mtx.Unlock()
+	return nil
+}
+
+// ParseJWKS 解析JWKS中用于签名的RSA和EC公钥，key为kid
+func ParseJWKS(data []byte) (map[string]crypto.PublicKey, error) {
+	var set struct {
+		Keys []jsonWebKey `json:"keys"`
+	}
+	if err := json.Unmarshal(data, &set); err != nil {
+		return nil, fmt.Errorf("auth: invalid jwks: %w", err)
+	}
+	keys := make(map[string]crypto.PublicKey, len(set.Keys))
+	for _, k := range set.Keys {
+		if k.Use != "" && k.Use != "sig" {
+			continue
+		}
+		key, err := k.publicKey()
+		if err != nil {
+			return nil, fmt.Errorf("auth: invalid jwk %q: %w", k.Kid, err)
+		}
+		keys[k.Kid] = key
+	}
+	return keys, nil
+}
+
+func (k jsonWebKey) publicKey() (crypto.PublicKey, error) {
+	switch k.Kty {
+	case "RSA":
+		n, err := decodeBigInt(k.N)
+		if err != nil {
+			return nil, err
+		}
+		e, err := decodeBigInt(k.E)
+		if err != nil {
+			return nil, err
+		}
+		return &rsa.PublicKey{N: n, E: int(e.Int64())}, nil
+	case "EC":
+		var curve elliptic.Curve
+		switch k.Crv {
+		case "P-256":
+			curve = elliptic.P256()
+		case "P-384":
+			curve = elliptic.P384()
+		case "P-521":
+			curve = elliptic.P521()
+		default:
+			return nil, fmt.Errorf("unsupported curve %q", k.Crv)
+		}
+		x, err := decodeBigInt(k.X)
+		if err != nil {
+			return nil, err
+		}
+		y, err := decodeBigInt(k.Y)
+		if err != nil {
+			return nil, err
+		}
+		if !curve.IsOnCurve(x, y) {
+			return nil, fmt.Errorf("point is not on curve %s", k.Crv)
+		}
+		return &ecdsa.PublicKey{Curve: curve, X: x, Y: y}, nil
+	default:
+		return nil, fmt.Errorf("unsupported key type %q", k.Kty)
+	}
+}
+
+func decodeBigInt(s string) (*big.Int, error) {
+	data, err := base64.RawURLEncoding.DecodeString(s)
+	if err != nil {
+		return nil, err
+	}
+	return new(big.Int).SetBytes(data), nil
+}
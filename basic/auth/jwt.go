@@ -0,0 +1,153 @@
+package auth
+
+import (
+	"context"
+	"crypto"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/golang-jwt/jwt/v4"
+	"singer.com/basic/errorx"
+)
+
+const (
+	defaultRolesClaim  = "roles"
+	defaultScopesClaim = "scope"
+)
+
+type JWTConfig struct {
+	// HS256/HS384/HS512使用的密钥
+	Secret []byte
+	// RS*、ES*使用的公钥，key为kid，token没有kid时使用key为空字符串的公钥
+	PublicKeys map[string]crypto.PublicKey
+	// 本地JWKS文件，文件修改后自动重新加载，与PublicKeys同时配置时优先使用JWKS中的公钥
+	JWKSFile string
+	// 允许的签名算法，默认为配置的密钥所支持的全部算法
+	Algorithms []string
+	// 不为空时校验iss
+	Issuer string
+	// 不为空时校验aud
+	Audience string
+	// 校验exp、nbf、iat时允许的时钟误差
+	Leeway time.Duration
+	// 角色所在的claim，默认roles，支持字符串数组或空格分隔的字符串
+	RolesClaim string
+	// scope所在的claim，默认scope，支持字符串数组或空格分隔的字符串
+	ScopesClaim string
+}
+
+type jwtAuthenticator struct {
+	cfg    JWTConfig
+	jwks   *jwksFile
+	parser *jwt.Parser
+}
+
+// NewJWTAuthenticator 校验 authorization: Bearer token 中的jwt，支持HS、RS和ES签名算法
+func NewJWTAuthenticator(cfg JWTConfig) (Authenticator, error) {
+	if len(cfg.Secret) == 0 && len(cfg.PublicKeys) == 0 && cfg.JWKSFile == "" {
+		return nil, errors.New("auth: jwt requires a secret, public keys or a jwks file")
+	}
+	if cfg.RolesClaim == "" {
+		cfg.RolesClaim = defaultRolesClaim
+	}
+	if cfg.ScopesClaim == "" {
+		cfg.ScopesClaim = defaultScopesClaim
+	}
+	if len(cfg.Algorithms) == 0 {
+		if len(cfg.Secret) > 0 {
+			cfg.Algorithms = append(cfg.Algorithms, "HS256", "HS384", "HS512")
+		}
+		if len(cfg.PublicKeys) > 0 || cfg.JWKSFile != "" {
+			cfg.Algorithms = append(cfg.Algorithms, "RS256", "RS384", "RS512", "ES256", "ES384", "ES512")
+		}
+	}
+	a := &jwtAuthenticator{cfg: cfg}
+	if cfg.JWKSFile != "" {
+		jwks, err := newJWKSFile(cfg.JWKSFile)
+		if err != nil {
+			return nil, err
+		}
+		a.jwks = jwks
+	}
+	// exp、nbf、iat由validate校验，以支持Leeway
+	a.parser = jwt.NewParser(jwt.WithValidMethods(cfg.Algorithms), jwt.WithoutClaimsValidation())
+	return a, nil
+}
+
+func (a *jwtAuthenticator) Authenticate(ctx context.Context) (*Principal, error) {
+	raw := bearerToken(ctx)
+	if raw == "" {
+		return nil, ErrNoCredentials
+	}
+	claims := jwt.MapClaims{}
+	if _, err := a.parser.ParseWithClaims(raw, claims, a.keyFunc); err != nil {
+		return nil, unauthenticated(fmt.Sprintf("invalid jwt: %v", err))
+	}
+	if err := a.validate(claims); err != nil {
+		return nil, err
+	}
+	sub, _ := claims["sub"].(string)
+	return &Principal{
+		Subject: sub,
+		Type:    TypeJWT,
+		Roles:   stringsClaim(claims[a.cfg.RolesClaim]),
+		Scopes:  stringsClaim(claims[a.cfg.ScopesClaim]),
+		Claims:  claims,
+	}, nil
+}
+
+func (a *jwtAuthenticator) keyFunc(token *jwt.Token) (interface{}, error) {
+	if strings.HasPrefix(token.Method.Alg(), "HS") {
+		if len(a.cfg.Secret) == 0 {
+			return nil, errors.New("hmac secret not configured")
+		}
+		return a.cfg.Secret, nil
+	}
+	kid, _ := token.Header["kid"].(string)
+	if a.jwks != nil {
+		if key, ok := a.jwks.key(kid); ok {
+			return key, nil
+		}
+	}
+	if key, ok := a.cfg.PublicKeys[kid]; ok {
+		return key, nil
+	}
+	return nil, fmt.Errorf("unknown kid %q", kid)
+}
+
+func (a *jwtAuthenticator) validate(claims jwt.MapClaims) error {
+	now := time.Now()
+	leeway := a.cfg.Leeway
+	if !claims.VerifyExpiresAt(now.Add(-leeway).Unix(), false) {
+		return errorx.Wrap(errorx.TOKEN_EXPIRE_ERROR, "jwt expired")
+	}
+	if !claims.VerifyNotBefore(now.Add(leeway).Unix(), false) || !claims.VerifyIssuedAt(now.Add(leeway).Unix(), false) {
+		return unauthenticated("jwt used before valid")
+	}
+	if a.cfg.Issuer != "" && !claims.VerifyIssuer(a.cfg.Issuer, true) {
+		return unauthenticated("invalid jwt issuer")
+	}
+	if a.cfg.Audience != "" && !claims.VerifyAudience(a.cfg.Audience, true) {
+		return unauthenticated("invalid jwt audience")
+	}
+	return nil
+}
+
+// stringsClaim 支持["a","b"]和"a b"两种格式
+func stringsClaim(v interface{}) []string {
+	switch v := v.(type) {
+	case string:
+		return strings.Fields(v)
+	case []interface{}:
+		var ss []string
+		for _, s := range v {
+			if s, ok := s.(string); ok {
+				ss = append(ss, s)
+			}
+		}
+		return ss
+	}
+	return nil
+}
@@ -0,0 +1,164 @@
+package auth
+
+import (
+	"context"
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/rsa"
+	"encoding/base64"
+	"encoding/json"
+	"math/big"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/golang-jwt/jwt/v4"
+	"github.com/stretchr/testify/assert"
+	"google.golang.org/grpc/metadata"
+	"singer.com/basic/errorx"
+)
+
+func bearerContext(token string) context.Context {
+	return metadata.NewIncomingContext(context.Background(), metadata.Pairs("authorization", "Bearer "+token))
+}
+
+func sign(t *testing.T, method jwt.SigningMethod, key interface{}, kid string, claims jwt.MapClaims) string {
+	token := jwt.NewWithClaims(method, claims)
+	if kid != "" {
+		token.Header["kid"] = kid
+	}
+	s, err := token.SignedString(key)
+	assert.Nil(t, err)
+	return s
+}
+
+func b64(i *big.Int) string {
+	return base64.RawURLEncoding.EncodeToString(i.Bytes())
+}
+
+func TestJWTHMAC(t *testing.T) {
+	a, err := NewJWTAuthenticator(JWTConfig{Secret: []byte("secret"), Issuer: "singer", Audience: "api"})
+	assert.Nil(t, err)
+
+	token := sign(t, jwt.SigningMethodHS256, []byte("secret"), "", jwt.MapClaims{
+		"sub":   "user1",
+		"iss":   "singer",
+		"aud":   []string{"api", "web"},
+		"exp":   time.Now().Add(time.Minute).Unix(),
+		"roles": []string{"admin"},
+		"scope": "read write",
+	})
+	p, err := a.Authenticate(bearerContext(token))
+	assert.Nil(t, err)
+	assert.Equal(t, "user1", p.Subject)
+	assert.Equal(t, TypeJWT, p.Type)
+	assert.True(t, p.HasRole("admin"))
+	assert.True(t, p.HasScope("write"))
+
+	// 签名错误
+	token = sign(t, jwt.SigningMethodHS256, []byte("other"), "", jwt.MapClaims{"sub": "user1", "iss": "singer", "aud": "api"})
+	_, err = a.Authenticate(bearerContext(token))
+	assert.True(t, errorx.IsCode(err, errorx.UNAUTHENTICATED_ERROR))
+
+	// iss错误
+	token = sign(t, jwt.SigningMethodHS256, []byte("secret"), "", jwt.MapClaims{"sub": "user1", "iss": "other", "aud": "api"})
+	_, err = a.Authenticate(bearerContext(token))
+	assert.True(t, errorx.IsCode(err, errorx.UNAUTHENTICATED_ERROR))
+
+	// 过期
+	token = sign(t, jwt.SigningMethodHS256, []byte("secret"), "", jwt.MapClaims{
+		"sub": "user1", "iss": "singer", "aud": "api", "exp": time.Now().Add(-time.Minute).Unix(),
+	})
+	_, err = a.Authenticate(bearerContext(token))
+	assert.True(t, errorx.IsCode(err, errorx.TOKEN_EXPIRE_ERROR))
+
+	// 没有token
+	_, err = a.Authenticate(context.Background())
+	assert.Equal(t, ErrNoCredentials, err)
+}
+
+func TestJWTLeeway(t *testing.T) {
+	a, err := NewJWTAuthenticator(JWTConfig{Secret: []byte("secret"), Leeway: time.Minute})
+	assert.Nil(t, err)
+	token := sign(t, jwt.SigningMethodHS256, []byte("secret"), "", jwt.MapClaims{
+		"sub": "user1", "exp": time.Now().Add(-30 * time.Second).Unix(),
+	})
+	_, err = a.Authenticate(bearerContext(token))
+	assert.Nil(t, err)
+}
+
+func TestJWTAlgorithmNotAllowed(t *testing.T) {
+	key, _ := rsa.GenerateKey(rand.Reader, 2048)
+	a, err := NewJWTAuthenticator(JWTConfig{Secret: []byte("secret"), PublicKeys: map[string]crypto.PublicKey{"": &key.PublicKey}, Algorithms: []string{"RS256"}})
+	assert.Nil(t, err)
+	token := sign(t, jwt.SigningMethodHS256, []byte("secret"), "", jwt.MapClaims{"sub": "user1"})
+	_, err = a.Authenticate(bearerContext(token))
+	assert.True(t, errorx.IsCode(err, errorx.UNAUTHENTICATED_ERROR))
+}
+
+func TestJWTJWKSFile(t *testing.T) {
+	rsaKey, _ := rsa.GenerateKey(rand.Reader, 2048)
+	ecKey, _ := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	jwks := map[string]interface{}{
+		"keys": []map[string]string{
+			{"kty": "RSA", "kid": "rsa1", "use": "sig", "n": b64(rsaKey.N), "e": b64(big.NewInt(int64(rsaKey.E)))},
+			{"kty": "EC", "kid": "ec1", "crv": "P-256", "x": b64(ecKey.X), "y": b64(ecKey.Y)},
+		},
+	}
+	data, _ := json.Marshal(jwks)
+	path := filepath.Join(t.TempDir(), "jwks.json")
+	assert.Nil(t, os.WriteFile(path, data, 0644))
+
+	a, err := NewJWTAuthenticator(JWTConfig{JWKSFile: path})
+	assert.Nil(t, err)
+
+	token := sign(t, jwt.SigningMethodRS256, rsaKey, "rsa1", jwt.MapClaims{"sub": "rsa-user"})
+	p, err := a.Authenticate(bearerContext(token))
+	assert.Nil(t, err)
+	assert.Equal(t, "rsa-user", p.Subject)
+
+	token = sign(t, jwt.SigningMethodES256, ecKey, "ec1", jwt.MapClaims{"sub": "ec-user"})
+	p, err = a.Authenticate(bearerContext(token))
+	assert.Nil(t, err)
+	assert.Equal(t, "ec-user", p.Subject)
+
+	// 未知kid
+	token = sign(t, jwt.SigningMethodRS256, rsaKey, "rsa2", jwt.MapClaims{"sub": "rsa-user"})
+	_, err = a.Authenticate(bearerContext(token))
+	assert.True(t, errorx.IsCode(err, errorx.UNAUTHENTICATED_ERROR))
+}
+
+func TestJWKSFileReload(t *testing.T) {
+	key1, _ := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	key2, _ := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	write := func(path, kid string, key *ecdsa.PrivateKey, mtime time.Time) {
+		data, _ := json.Marshal(map[string]interface{}{"keys": []map[string]string{
+			{"kty": "EC", "kid": kid, "crv": "P-256", "x": b64(key.X), "y": b64(key.Y)},
+		}})
+		assert.Nil(t, os.WriteFile(path, data, 0644))
+		assert.Nil(t, os.Chtimes(path, mtime, mtime))
+	}
+	path := filepath.Join(t.TempDir(), "jwks.json")
+	write(path, "k1", key1, time.Now().Add(-time.Hour))
+
+	f, err := newJWKSFile(path)
+	assert.Nil(t, err)
+	_, ok := f.key("k1")
+	assert.True(t, ok)
+
+	write(path, "k2", key2, time.Now())
+	f.lastCheck = time.Time{}
+	_, ok = f.key("k2")
+	assert.True(t, ok)
+	_, ok = f.key("k1")
+	assert.False(t, ok)
+
+	// 文件损坏时继续使用之前的公钥
+	assert.Nil(t, os.WriteFile(path, []byte("{"), 0644))
+	f.lastCheck = time.Time{}
+	_, ok = f.key("k2")
+	assert.True(t, ok)
+}
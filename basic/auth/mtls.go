@@ -0,0 +1,46 @@
+package auth
+
+import (
+	"context"
+	"crypto/x509"
+
+	"google.golang.org/grpc/credentials"
+	"google.golang.org/grpc/peer"
+)
+
+type mtlsAuthenticator struct {
+	roles map[string][]string
+}
+
+// NewMTLSAuthenticator 使用已校验的客户端证书作为身份，证书有URI SAN(如spiffe id)时使用第一个URI，
+// 否则使用CN。roles的key为身份，value为该身份的角色。服务端需要配置为校验客户端证书
+func NewMTLSAuthenticator(roles map[string][]string) Authenticator {
+	return &mtlsAuthenticator{roles: roles}
+}
+
+func (a *mtlsAuthenticator) Authenticate(ctx context.Context) (*Principal, error) {
+	pr, ok := peer.FromContext(ctx)
+	if !ok {
+		return nil, ErrNoCredentials
+	}
+	info, ok := pr.AuthInfo.(credentials.TLSInfo)
+	if !ok || len(info.State.VerifiedChains) == 0 || len(info.State.VerifiedChains[0]) == 0 {
+		return nil, ErrNoCredentials
+	}
+	subject := certIdentity(info.State.VerifiedChains[0][0])
+	if subject == "" {
+		return nil, unauthenticated("client certificate has no identity")
+	}
+	return &Principal{
+		Subject: subject,
+		Type:    TypeMTLS,
+		Roles:   a.roles[subject],
+	}, nil
+}
+
+func certIdentity(cert *x509.Certificate) string {
+	if len(cert.URIs) > 0 {
+		return cert.URIs[0].String()
+	}
+	return cert.Subject.CommonName
+}
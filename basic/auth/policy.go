@@ -0,0 +1,147 @@
+package auth
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+	"sync"
+
+	"github.com/fsnotify/fsnotify"
+	log "github.com/sirupsen/logrus"
+	"github.com/spf13/viper"
+	"singer.com/basic/errorx"
+)
+
+// AnyRole 拥有任意身份即可访问
+const AnyRole = "*"
+
+// Rule 方法级授权规则，Method为方法前缀，与NewPrefixHystrixBreaker的前缀约定相同，
+// 如"/api.Order/"匹配Order服务的所有方法，"/api.Order/Get"只匹配Get
+type Rule struct {
+	Method string `mapstructure:"method"`
+	// 拥有任一角色即可访问，*表示任意已认证的调用方
+	Roles []string `mapstructure:"roles"`
+	// 拥有任一scope即可访问
+	Scopes []string `mapstructure:"scopes"`
+	// 不需要认证即可访问，使用micro.Authorize时认证拦截器也会跳过该方法
+	Public bool `mapstructure:"public"`
+}
+
+type PolicyConfig struct {
+	Rules []Rule `mapstructure:"rules"`
+	// 没有匹配的规则时是否允许访问，默认拒绝
+	DefaultAllow bool `mapstructure:"default_allow"`
+}
+
+// Policy 基于角色和scope的方法级授权策略，多条规则匹配时使用前缀最长的规则，可以并发更新
+type Policy struct {
+	mtx          sync.RWMutex
+	rules        []Rule
+	defaultAllow bool
+}
+
+func NewPolicy(cfg PolicyConfig) *Policy {
+	p := &Policy{}
+	p.Update(cfg)
+	return p
+}
+
+// Update 替换全部规则
+func (p *Policy) Update(cfg PolicyConfig) {
+	rules := make([]Rule, len(cfg.Rules))
+	copy(rules, cfg.Rules)
+	sort.SliceStable(rules, func(i, j int) bool {
+		return len(rules[i].Method) > len(rules[j].Method)
+	})
+	p.mtx.Lock()
+	p.rules, p.defaultAllow = rules, cfg.DefaultAllow
+	p.mtx.Unlock()
+}
+
+// LoadPolicy 从配置文件(yaml、json、toml等viper支持的格式)加载授权策略，文件修改后自动重新加载，
+// 重新加载失败时继续使用之前的策略
+func LoadPolicy(path string) (*Policy, error) {
+	v := viper.New()
+	v.SetConfigFile(path)
+	cfg, err := readPolicy(v)
+	if err != nil {
+		return nil, err
+	}
+	p := NewPolicy(cfg)
+	v.OnConfigChange(func(e fsnotify.Event) {
+		cfg, err := readPolicy(v)
+		if err != nil {
+			log.Errorf("reload policy %s failed, err: %v", path, err)
+			return
+		}
+		p.Update(cfg)
+		log.Infof("policy %s reloaded, %d rules", path, len(cfg.Rules))
+	})
+	v.WatchConfig()
+	return p, nil
+}
+
+func readPolicy(v *viper.Viper) (PolicyConfig, error) {
+	var cfg PolicyConfig
+	if err := v.ReadInConfig(); err != nil {
+		return cfg, fmt.Errorf("auth: read policy failed: %w", err)
+	}
+	if err := v.Unmarshal(&cfg); err != nil {
+		return cfg, fmt.Errorf("auth: invalid policy: %w", err)
+	}
+	for _, r := range cfg.Rules {
+		if !strings.HasPrefix(r.Method, "/") {
+			return cfg, fmt.Errorf("auth: invalid policy method %q, must start with /", r.Method)
+		}
+	}
+	return cfg, nil
+}
+
+// match 返回前缀最长的匹配规则
+func (p *Policy) match(method string) (Rule, bool) {
+	p.mtx.RLock()
+	defer p.mtx.RUnlock()
+	for _, r := range p.rules {
+		if strings.HasPrefix(method, r.Method) {
+			return r, true
+		}
+	}
+	return Rule{}, false
+}
+
+// IsPublic 方法匹配的规则是否为Public，认证拦截器据此跳过认证，修改策略后立即生效
+func (p *Policy) IsPublic(method string) bool {
+	r, ok := p.match(method)
+	return ok && r.Public
+}
+
+// Authorize 判断调用方是否可以访问method，principal为nil表示未认证。拒绝时返回PERMISSION_DENIED_ERROR
+func (p *Policy) Authorize(principal *Principal, method string) error {
+	r, ok := p.match(method)
+	if !ok {
+		p.mtx.RLock()
+		allow := p.defaultAllow
+		p.mtx.RUnlock()
+		if allow {
+			return nil
+		}
+		return errorx.Wrap(errorx.PERMISSION_DENIED_ERROR, "no policy rule matches "+method)
+	}
+	if r.Public {
+		return nil
+	}
+	if principal == nil {
+		return errorx.Wrap(errorx.PERMISSION_DENIED_ERROR, "unauthenticated caller")
+	}
+	for _, role := range r.Roles {
+		if role == AnyRole || principal.HasRole(role) {
+			return nil
+		}
+	}
+	for _, scope := range r.Scopes {
+		if principal.HasScope(scope) {
+			return nil
+		}
+	}
+	return errorx.Wrap(errorx.PERMISSION_DENIED_ERROR, fmt.Sprintf("%s does not match rule %s", principal.Subject, r.Method))
+}
@@ -0,0 +1,74 @@
+package auth
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"singer.com/basic/errorx"
+)
+
+func TestPolicyAuthorize(t *testing.T) {
+	p := NewPolicy(PolicyConfig{Rules: []Rule{
+		{Method: "/api.Order/", Roles: []string{"admin"}},
+		{Method: "/api.Order/Get", Roles: []string{"viewer"}, Scopes: []string{"order:read"}},
+		{Method: "/api.User/", Roles: []string{AnyRole}},
+		{Method: "/api.Public/", Public: true},
+	}})
+	admin := &Principal{Subject: "u1", Roles: []string{"admin"}}
+	viewer := &Principal{Subject: "u2", Roles: []string{"viewer"}}
+	reader := &Principal{Subject: "u3", Scopes: []string{"order:read"}}
+
+	assert.Nil(t, p.Authorize(admin, "/api.Order/Delete"))
+	assert.True(t, errorx.IsCode(p.Authorize(viewer, "/api.Order/Delete"), errorx.PERMISSION_DENIED_ERROR))
+	// 前缀最长的规则优先
+	assert.Nil(t, p.Authorize(viewer, "/api.Order/Get"))
+	assert.Nil(t, p.Authorize(reader, "/api.Order/Get"))
+	assert.True(t, errorx.IsCode(p.Authorize(admin, "/api.Order/Get"), errorx.PERMISSION_DENIED_ERROR))
+
+	assert.Nil(t, p.Authorize(reader, "/api.User/Get"))
+	assert.True(t, errorx.IsCode(p.Authorize(nil, "/api.User/Get"), errorx.PERMISSION_DENIED_ERROR))
+	assert.Nil(t, p.Authorize(nil, "/api.Public/Get"))
+	assert.True(t, p.IsPublic("/api.Public/Get"))
+	assert.False(t, p.IsPublic("/api.Order/Get"))
+	assert.False(t, p.IsPublic("/api.Other/Get"))
+
+	// 默认拒绝
+	assert.True(t, errorx.IsCode(p.Authorize(admin, "/api.Other/Get"), errorx.PERMISSION_DENIED_ERROR))
+	p.Update(PolicyConfig{DefaultAllow: true})
+	assert.Nil(t, p.Authorize(nil, "/api.Other/Get"))
+}
+
+func TestLoadPolicy(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "policy.yaml")
+	assert.Nil(t, os.WriteFile(path, []byte(`
+rules:
+  - method: /api.Order/
+    roles: [admin]
+`), 0644))
+	p, err := LoadPolicy(path)
+	assert.Nil(t, err)
+	viewer := &Principal{Subject: "u1", Roles: []string{"viewer"}}
+	assert.NotNil(t, p.Authorize(viewer, "/api.Order/Get"))
+
+	assert.Nil(t, os.WriteFile(path, []byte(`
+rules:
+  - method: /api.Order/
+    roles: [admin, viewer]
+`), 0644))
+	assert.Eventually(t, func() bool {
+		return p.Authorize(viewer, "/api.Order/Get") == nil
+	}, 5*time.Second, 50*time.Millisecond)
+
+	_, err = LoadPolicy(filepath.Join(t.TempDir(), "missing.yaml"))
+	assert.NotNil(t, err)
+}
+
+func TestLoadPolicyInvalidMethod(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "policy.json")
+	assert.Nil(t, os.WriteFile(path, []byte(`{"rules":[{"method":"api.Order","roles":["admin"]}]}`), 0644))
+	_, err := LoadPolicy(path)
+	assert.NotNil(t, err)
+}
@@ -1,8 +1,23 @@
 package breaker
 
+import "singer.com/basic/errorx"
+
 // Acceptable is the func to check if the error can be accepted.
 type Acceptable func(err error) bool
 
+// AcceptCodes returns an Acceptable that accepts errors carrying one of the given codes,
+// both errorx business codes and grpc codes are supported.
+func AcceptCodes(codes ...errorx.ErrorCode) Acceptable {
+	return func(err error) bool {
+		for _, code := range codes {
+			if errorx.IsCode(err, code) {
+				return true
+			}
+		}
+		return false
+	}
+}
+
 type Breaker interface {
 	// Do runs the given request if the Breaker accepts it.
 	// Do returns an error instantly if the Breaker rejects the request.
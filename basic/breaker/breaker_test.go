@@ -0,0 +1,32 @@
+package breaker
+
+import (
+	"testing"
+
+	"github.com/pkg/errors"
+	"github.com/stretchr/testify/assert"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	"singer.com/basic/errorx"
+)
+
+func TestAcceptCodes(t *testing.T) {
+	accept := AcceptCodes(errorx.REUQEST_PARAM_ERROR, errorx.ErrorCode(codes.NotFound))
+
+	// 本地的CodeError
+	assert.True(t, accept(errorx.Wrap(errorx.REUQEST_PARAM_ERROR, "invalid id")))
+	assert.True(t, accept(errors.Wrap(errorx.NewErrCode(errorx.REUQEST_PARAM_ERROR), "call order")))
+
+	// 下游返回的status，业务错误码在ErrorInfo中
+	remote := errorx.NewErrCode(errorx.REUQEST_PARAM_ERROR).GRPCStatus().Err()
+	assert.Equal(t, codes.InvalidArgument, status.Code(remote))
+	assert.True(t, accept(remote))
+
+	// grpc code
+	assert.True(t, accept(status.Error(codes.NotFound, "not found")))
+
+	assert.False(t, accept(errorx.Wrap(errorx.DB_ERROR, "db is busy")))
+	assert.False(t, accept(status.Error(codes.Unavailable, "unavailable")))
+	assert.False(t, accept(errors.New("unknown")))
+	assert.False(t, accept(nil))
+}
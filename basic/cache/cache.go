@@ -0,0 +1,369 @@
+package cache
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"singer.com/basic/single"
+	"singer.com/util/clock"
+)
+
+type Policy int
+
+const (
+	// LRU 淘汰最久没有访问的
+	LRU Policy = iota
+	// LFU 淘汰访问次数最少的，次数相同时淘汰最久没有访问的
+	LFU
+	// TinyLFU W-TinyLFU，新数据先进入窗口LRU，离开窗口时与主区域的淘汰候选比较访问频率决定是否准入，
+	// 对突发的一次性访问(如扫描)有较好的抵抗能力
+	TinyLFU
+)
+
+const (
+	defaultShards = 16
+	// 每个分片至少的容量(条数或开销)，容量较小时只使用一个分片，避免分片不均导致提前淘汰
+	minShardEntries = 64
+
+	defaultLoadTimeout = 10 * time.Second
+)
+
+var ErrNoLoader = errors.New("cache: no loader")
+
+type Config[K comparable, V any] struct {
+	// 淘汰策略，默认LRU
+	Policy Policy
+	// 最大条数，0表示不限制
+	MaxEntries int
+	// 最大开销(如字节数)，0表示不限制
+	MaxCost int64
+	// 计算一条数据的开销，默认string和[]byte为长度，其他类型为1
+	Cost func(key K, value V) int64
+	// 默认过期时间，0表示不过期
+	TTL time.Duration
+	// 分片数，向上取整为2的幂，默认16，容量较小时为1。MaxEntries和MaxCost平均分配到每个分片
+	Shards int
+	// GetOrLoad未命中时的加载函数
+	Loader func(ctx context.Context, key K) (V, error)
+	// 加载的超时时间，默认10s。加载在不随调用方取消的ctx中执行，某个调用方取消不影响其他等待的调用方
+	LoadTimeout time.Duration
+	// 默认为真实时钟，测试中可以替换
+	Clock clock.PassiveClock
+}
+
+// Stats 缓存统计
+type Stats struct {
+	Hits        uint64
+	Misses      uint64
+	Evictions   uint64 // 因容量不足淘汰的条数
+	Expirations uint64 // 访问时发现已过期删除的条数
+	Loads       uint64
+	LoadErrors  uint64
+}
+
+// HitRatio 命中率，没有访问时为0
+func (s Stats) HitRatio() float64 {
+	if total := s.Hits + s.Misses; total > 0 {
+		return float64(s.Hits) / float64(total)
+	}
+	return 0
+}
+
+// Cache 并发安全的本地缓存，支持按条过期、按条数或开销淘汰
+type Cache[K comparable, V any] struct {
+	// 原子操作的字段放在最前面保证64位对齐
+	hits, misses, evictions, expirations, loads, loadErrors uint64
+
+	cfg    Config[K, V]
+	shards []*shard[K, V]
+	mask   uint64
+	group  single.Group
+}
+
+func New[K comparable, V any](cfg Config[K, V]) *Cache[K, V] {
+	if cfg.Clock == nil {
+		cfg.Clock = clock.RealClock{}
+	}
+	if cfg.Cost == nil {
+		cfg.Cost = defaultCost[K, V]
+	}
+	if cfg.LoadTimeout <= 0 {
+		cfg.LoadTimeout = defaultLoadTimeout
+	}
+	n := cfg.Shards
+	if n <= 0 {
+		n = defaultShards
+		if (cfg.MaxEntries > 0 && cfg.MaxEntries < defaultShards*minShardEntries) ||
+			(cfg.MaxCost > 0 && cfg.MaxCost < defaultShards*minShardEntries) {
+			n = 1
+		}
+	}
+	n = int(nextPowerOfTwo(uint64(n)))
+
+	c := &Cache[K, V]{
+		cfg:    cfg,
+		shards: make([]*shard[K, V], n),
+		mask:   uint64(n - 1),
+		group:  single.New(),
+	}
+	maxEntries := (cfg.MaxEntries + n - 1) / n
+	maxCost := (cfg.MaxCost + int64(n) - 1) / int64(n)
+	for i := range c.shards {
+		c.shards[i] = newShard[K, V](cfg.Policy, maxEntries, maxCost)
+	}
+	return c
+}
+
+func (c *Cache[K, V]) shardOf(h uint64) *shard[K, V] {
+	return c.shards[h&c.mask]
+}
+
+// Get 返回未过期的数据
+func (c *Cache[K, V]) Get(key K) (V, bool) {
+	v, ok := c.get(key, true)
+	if ok {
+		atomic.AddUint64(&c.hits, 1)
+	} else {
+		atomic.AddUint64(&c.misses, 1)
+	}
+	return v, ok
+}
+
+func (c *Cache[K, V]) get(key K, record bool) (V, bool) {
+	h := hashKey(key)
+	s := c.shardOf(h)
+	s.mtx.Lock()
+	defer s.mtx.Unlock()
+	e, ok := s.items[key]
+	if ok && e.expired(c.cfg.Clock.Now()) {
+		s.remove(e)
+		atomic.AddUint64(&c.expirations, 1)
+		ok = false
+	}
+	if !ok {
+		if record {
+			s.policy.touch(h)
+		}
+		var zero V
+		return zero, false
+	}
+	if record {
+		s.policy.access(e)
+	}
+	return e.value, true
+}
+
+// Set 使用默认过期时间保存数据
+func (c *Cache[K, V]) Set(key K, value V) {
+	c.SetWithTTL(key, value, c.cfg.TTL)
+}
+
+// SetWithTTL 保存数据，ttl为0表示不过期。开销超过单个分片容量的数据不会保存
+func (c *Cache[K, V]) SetWithTTL(key K, value V, ttl time.Duration) {
+	e := &entry[K, V]{key: key, value: value, hash: hashKey(key), weight: 1}
+	if c.cfg.MaxCost > 0 {
+		e.weight = c.cfg.Cost(key, value)
+	}
+	if ttl > 0 {
+		e.expireAt = c.cfg.Clock.Now().Add(ttl)
+	}
+	s := c.shardOf(e.hash)
+	s.mtx.Lock()
+	evicted := s.add(e)
+	s.mtx.Unlock()
+	if evicted > 0 {
+		atomic.AddUint64(&c.evictions, uint64(evicted))
+	}
+}
+
+// Delete 删除数据
+func (c *Cache[K, V]) Delete(key K) {
+	s := c.shardOf(hashKey(key))
+	s.mtx.Lock()
+	defer s.mtx.Unlock()
+	if e, ok := s.items[key]; ok {
+		s.remove(e)
+	}
+}
+
+// Len 返回保存的条数，包括已过期但还没有删除的数据
+func (c *Cache[K, V]) Len() int {
+	n := 0
+	for _, s := range c.shards {
+		s.mtx.Lock()
+		n += len(s.items)
+		s.mtx.Unlock()
+	}
+	return n
+}
+
+// Clear 删除所有数据
+func (c *Cache[K, V]) Clear() {
+	for _, s := range c.shards {
+		s.mtx.Lock()
+		s.reset()
+		s.mtx.Unlock()
+	}
+}
+
+func (c *Cache[K, V]) Stats() Stats {
+	return Stats{
+		Hits:        atomic.LoadUint64(&c.hits),
+		Misses:      atomic.LoadUint64(&c.misses),
+		Evictions:   atomic.LoadUint64(&c.evictions),
+		Expirations: atomic.LoadUint64(&c.expirations),
+		Loads:       atomic.LoadUint64(&c.loads),
+		LoadErrors:  atomic.LoadUint64(&c.loadErrors),
+	}
+}
+
+// GetOrLoad 未命中时使用Config.Loader加载并保存，并发的相同key只加载一次
+func (c *Cache[K, V]) GetOrLoad(ctx context.Context, key K) (V, error) {
+	if c.cfg.Loader == nil {
+		var zero V
+		return zero, ErrNoLoader
+	}
+	return c.GetOrLoadFunc(ctx, key, c.cfg.Loader)
+}
+
+// GetOrLoadFunc 未命中时使用loader加载并保存，并发的相同key只加载一次，加载失败时不保存。
+// ctx取消时返回ctx.Err()，加载继续进行，不影响其他等待的调用方
+func (c *Cache[K, V]) GetOrLoadFunc(ctx context.Context, key K, loader func(ctx context.Context, key K) (V, error)) (V, error) {
+	if v, ok := c.Get(key); ok {
+		return v, nil
+	}
+	v, err := load(ctx, &c.group, keyString(key), c.cfg.LoadTimeout, func(ctx context.Context) (interface{}, error) {
+		// 等待期间其他调用可能已经加载完成
+		if v, ok := c.get(key, false); ok {
+			return v, nil
+		}
+		atomic.AddUint64(&c.loads, 1)
+		v, err := loader(ctx, key)
+		if err != nil {
+			atomic.AddUint64(&c.loadErrors, 1)
+			return nil, err
+		}
+		c.Set(key, v)
+		return v, nil
+	})
+	if err != nil {
+		var zero V
+		return zero, err
+	}
+	return v.(V), nil
+}
+
+// load 在不随调用方取消、超时时间为timeout的ctx中执行fn，并发的相同key只执行一次，每个调用方只等待自己的ctx
+func load(ctx context.Context, group *single.Group, key string, timeout time.Duration, fn func(ctx context.Context) (interface{}, error)) (interface{}, error) {
+	loadCtx := detachedContext{ctx}
+	ch := group.DoChan(key, func() (interface{}, error) {
+		ctx, cancel := context.WithTimeout(loadCtx, timeout)
+		defer cancel()
+		return fn(ctx)
+	})
+	select {
+	case r := <-ch:
+		return r.Val, r.Err
+	case <-ctx.Done():
+		return nil, ctx.Err()
+	}
+}
+
+// detachedContext 保留ctx中的值(logger、trace等)，但不会随调用方取消
+type detachedContext struct {
+	context.Context
+}
+
+func (detachedContext) Deadline() (time.Time, bool) { return time.Time{}, false }
+func (detachedContext) Done() <-chan struct{}       { return nil }
+func (detachedContext) Err() error                  { return nil }
+
+type shard[K comparable, V any] struct {
+	mtx        sync.Mutex
+	items      map[K]*entry[K, V]
+	policy     policy[K, V]
+	kind       Policy
+	weight     int64
+	maxWeight  int64
+	maxEntries int
+}
+
+func newShard[K comparable, V any](kind Policy, maxEntries int, maxCost int64) *shard[K, V] {
+	s := &shard[K, V]{kind: kind, maxEntries: maxEntries, maxWeight: maxCost}
+	s.reset()
+	return s
+}
+
+func (s *shard[K, V]) reset() {
+	s.items = make(map[K]*entry[K, V])
+	s.weight = 0
+	capacity := int64(s.maxEntries)
+	if s.maxWeight > 0 {
+		capacity = s.maxWeight
+	}
+	s.policy = newPolicy[K, V](s.kind, capacity)
+}
+
+// add 保存数据并淘汰超出容量的数据，返回淘汰的条数
+func (s *shard[K, V]) add(e *entry[K, V]) int {
+	if s.maxWeight > 0 && e.weight > s.maxWeight {
+		// 数据太大，同时删除旧数据避免读到过期的值
+		if old, ok := s.items[e.key]; ok {
+			s.remove(old)
+		}
+		return 0
+	}
+	if old, ok := s.items[e.key]; ok {
+		e.freq = old.freq
+		s.remove(old)
+	}
+	s.items[e.key] = e
+	s.weight += e.weight
+	s.policy.add(e)
+
+	evicted := 0
+	for (s.maxWeight > 0 && s.weight > s.maxWeight) || (s.maxEntries > 0 && len(s.items) > s.maxEntries) {
+		victim := s.policy.victim()
+		if victim == nil {
+			break
+		}
+		s.remove(victim)
+		evicted++
+	}
+	return evicted
+}
+
+func (s *shard[K, V]) remove(e *entry[K, V]) {
+	s.policy.remove(e)
+	delete(s.items, e.key)
+	s.weight -= e.weight
+}
+
+func defaultCost[K comparable, V any](key K, value V) int64 {
+	switch v := any(value).(type) {
+	case string:
+		return int64(len(v))
+	case []byte:
+		return int64(len(v))
+	}
+	return 1
+}
+
+func keyString[K comparable](key K) string {
+	if s, ok := any(key).(string); ok {
+		return s
+	}
+	return fmt.Sprintf("%#v", key)
+}
+
+func nextPowerOfTwo(n uint64) uint64 {
+	p := uint64(1)
+	for p < n {
+		p <<= 1
+	}
+	return p
+}
@@ -0,0 +1,284 @@
+package cache
+
+import (
+	"context"
+	"errors"
+	"strconv"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+type fakeClock struct {
+	now time.Time
+}
+
+func (c *fakeClock) Now() time.Time                  { return c.now }
+func (c *fakeClock) Since(t time.Time) time.Duration { return c.now.Sub(t) }
+
+func TestTTL(t *testing.T) {
+	clk := &fakeClock{now: time.Now()}
+	c := New(Config[string, int]{TTL: time.Minute, Clock: clk})
+
+	c.Set("a", 1)
+	c.SetWithTTL("b", 2, 2*time.Minute)
+	c.SetWithTTL("c", 3, 0)
+
+	v, ok := c.Get("a")
+	assert.True(t, ok)
+	assert.Equal(t, 1, v)
+
+	clk.now = clk.now.Add(time.Minute)
+	_, ok = c.Get("a")
+	assert.False(t, ok)
+	_, ok = c.Get("b")
+	assert.True(t, ok)
+
+	clk.now = clk.now.Add(time.Hour)
+	_, ok = c.Get("b")
+	assert.False(t, ok)
+	_, ok = c.Get("c")
+	assert.True(t, ok)
+
+	stats := c.Stats()
+	assert.Equal(t, uint64(3), stats.Hits)
+	assert.Equal(t, uint64(2), stats.Misses)
+	assert.Equal(t, uint64(2), stats.Expirations)
+	assert.Equal(t, 0.6, stats.HitRatio())
+	assert.Equal(t, 1, c.Len())
+
+	c.Delete("c")
+	assert.Equal(t, 0, c.Len())
+}
+
+func TestLRU(t *testing.T) {
+	c := New(Config[int, int]{Policy: LRU, MaxEntries: 3})
+	for i := 0; i < 3; i++ {
+		c.Set(i, i)
+	}
+	c.Get(0)
+	c.Set(3, 3)
+
+	_, ok := c.Get(1)
+	assert.False(t, ok)
+	for _, k := range []int{0, 2, 3} {
+		_, ok := c.Get(k)
+		assert.True(t, ok, k)
+	}
+	assert.Equal(t, uint64(1), c.Stats().Evictions)
+}
+
+func TestLFU(t *testing.T) {
+	c := New(Config[string, int]{Policy: LFU, MaxEntries: 3})
+	c.Set("a", 1)
+	c.Set("b", 2)
+	c.Set("c", 3)
+	c.Get("a")
+	c.Get("a")
+	c.Get("c")
+	// 覆盖写入保留访问次数
+	c.Set("b", 20)
+	c.Set("d", 4)
+
+	_, ok := c.Get("c")
+	assert.True(t, ok)
+	_, ok = c.Get("a")
+	assert.True(t, ok)
+	v, ok := c.Get("b")
+	assert.True(t, ok)
+	assert.Equal(t, 20, v)
+	// d访问次数最少
+	c.Set("e", 5)
+	_, ok = c.Get("d")
+	assert.False(t, ok)
+}
+
+func TestTinyLFUScanResistance(t *testing.T) {
+	const size = 1000
+	c := New(Config[string, int]{Policy: TinyLFU, MaxEntries: size, Shards: 1})
+
+	hot := func(i int) string { return "hot" + strconv.Itoa(i) }
+	for round := 0; round < 5; round++ {
+		for i := 0; i < size/2; i++ {
+			if _, ok := c.Get(hot(i)); !ok {
+				c.Set(hot(i), i)
+			}
+		}
+	}
+	// 一次性扫描大量冷数据
+	for i := 0; i < 10*size; i++ {
+		c.Set("scan"+strconv.Itoa(i), i)
+	}
+
+	hits := 0
+	for i := 0; i < size/2; i++ {
+		if _, ok := c.Get(hot(i)); ok {
+			hits++
+		}
+	}
+	assert.Greater(t, hits, size/2*9/10)
+	assert.LessOrEqual(t, c.Len(), size)
+
+	lru := New(Config[string, int]{Policy: LRU, MaxEntries: size, Shards: 1})
+	for i := 0; i < size/2; i++ {
+		lru.Set(hot(i), i)
+	}
+	for i := 0; i < 10*size; i++ {
+		lru.Set("scan"+strconv.Itoa(i), i)
+	}
+	_, ok := lru.Get(hot(0))
+	assert.False(t, ok)
+}
+
+func TestMaxCost(t *testing.T) {
+	c := New(Config[string, []byte]{MaxCost: 10})
+
+	c.Set("a", []byte("12345"))
+	c.Set("b", []byte("1234"))
+	c.Set("c", []byte("123"))
+	_, ok := c.Get("a")
+	assert.False(t, ok)
+	assert.Equal(t, 2, c.Len())
+
+	// 超过容量的数据不保存
+	c.Set("b", []byte("12345678901"))
+	_, ok = c.Get("b")
+	assert.False(t, ok)
+
+	costs := New(Config[int, string]{MaxCost: 3, Cost: func(int, string) int64 { return 1 }})
+	for i := 0; i < 5; i++ {
+		costs.Set(i, "long value")
+	}
+	assert.Equal(t, 3, costs.Len())
+}
+
+func TestShards(t *testing.T) {
+	assert.Len(t, New(Config[int, int]{MaxEntries: 100}).shards, 1)
+	assert.Len(t, New(Config[int, int]{MaxEntries: 10000}).shards, 16)
+	assert.Len(t, New(Config[int, int]{Shards: 5}).shards, 8)
+
+	c := New(Config[int, int]{MaxEntries: 10000, Policy: TinyLFU})
+	for i := 0; i < 20000; i++ {
+		c.Set(i, i)
+	}
+	assert.LessOrEqual(t, c.Len(), 10000)
+	c.Clear()
+	assert.Equal(t, 0, c.Len())
+}
+
+func TestGetOrLoad(t *testing.T) {
+	ctx := context.Background()
+	_, err := New(Config[string, string]{}).GetOrLoad(ctx, "a")
+	assert.Equal(t, ErrNoLoader, err)
+
+	var calls int32
+	release := make(chan struct{})
+	c := New(Config[string, string]{Loader: func(ctx context.Context, key string) (string, error) {
+		atomic.AddInt32(&calls, 1)
+		<-release
+		if key == "bad" {
+			return "", errors.New("load failed")
+		}
+		return "v:" + key, nil
+	}})
+
+	var wg sync.WaitGroup
+	for i := 0; i < 10; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			v, err := c.GetOrLoad(ctx, "a")
+			assert.Nil(t, err)
+			assert.Equal(t, "v:a", v)
+		}()
+	}
+	time.Sleep(50 * time.Millisecond)
+	close(release)
+	wg.Wait()
+	assert.Equal(t, int32(1), atomic.LoadInt32(&calls))
+
+	v, err := c.GetOrLoad(ctx, "a")
+	assert.Nil(t, err)
+	assert.Equal(t, "v:a", v)
+	assert.Equal(t, int32(1), atomic.LoadInt32(&calls))
+
+	_, err = c.GetOrLoad(ctx, "bad")
+	assert.NotNil(t, err)
+	_, ok := c.Get("bad")
+	assert.False(t, ok)
+
+	stats := c.Stats()
+	assert.Equal(t, uint64(2), stats.Loads)
+	assert.Equal(t, uint64(1), stats.LoadErrors)
+}
+
+func TestGetOrLoadCanceled(t *testing.T) {
+	release := make(chan struct{})
+	c := New(Config[string, string]{Loader: func(ctx context.Context, key string) (string, error) {
+		select {
+		case <-release:
+			return "v:" + key, nil
+		case <-ctx.Done():
+			return "", ctx.Err()
+		}
+	}})
+
+	// 发起加载的调用方取消后，其他等待的调用方仍然得到结果
+	first, cancel := context.WithCancel(context.Background())
+	firstDone := make(chan error, 1)
+	go func() {
+		_, err := c.GetOrLoad(first, "a")
+		firstDone <- err
+	}()
+	time.Sleep(20 * time.Millisecond)
+	var wg sync.WaitGroup
+	for i := 0; i < 5; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			v, err := c.GetOrLoad(context.Background(), "a")
+			assert.Nil(t, err)
+			assert.Equal(t, "v:a", v)
+		}()
+	}
+	time.Sleep(20 * time.Millisecond)
+	cancel()
+	assert.Equal(t, context.Canceled, <-firstDone)
+	close(release)
+	wg.Wait()
+
+	// 加载有自己的超时时间
+	c = New(Config[string, string]{LoadTimeout: 20 * time.Millisecond, Loader: func(ctx context.Context, key string) (string, error) {
+		<-ctx.Done()
+		return "", ctx.Err()
+	}})
+	_, err := c.GetOrLoad(context.Background(), "a")
+	assert.Equal(t, context.DeadlineExceeded, err)
+}
+
+func TestConcurrent(t *testing.T) {
+	for _, p := range []Policy{LRU, LFU, TinyLFU} {
+		c := New(Config[int, int]{Policy: p, MaxEntries: 2000, TTL: time.Second})
+		var wg sync.WaitGroup
+		for g := 0; g < 8; g++ {
+			wg.Add(1)
+			go func(g int) {
+				defer wg.Done()
+				for i := 0; i < 5000; i++ {
+					k := (i * (g + 1)) % 3000
+					if _, ok := c.Get(k); !ok {
+						c.Set(k, i)
+					}
+					if i%100 == 0 {
+						c.Delete(k)
+					}
+				}
+			}(g)
+		}
+		wg.Wait()
+		assert.LessOrEqual(t, c.Len(), 2000)
+	}
+}
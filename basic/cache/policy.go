@@ -0,0 +1,143 @@
+package cache
+
+import (
+	"container/heap"
+	"container/list"
+	"math"
+	"time"
+)
+
+type entry[K comparable, V any] struct {
+	key      K
+	value    V
+	hash     uint64
+	weight   int64
+	expireAt time.Time
+
+	// 以下字段由淘汰策略维护
+	elem   *list.Element
+	region uint8
+	freq   uint64
+	tick   uint64
+	index  int
+}
+
+func (e *entry[K, V]) expired(now time.Time) bool {
+	return !e.expireAt.IsZero() && !now.Before(e.expireAt)
+}
+
+// policy 淘汰策略，调用方负责加锁
+type policy[K comparable, V any] interface {
+	add(e *entry[K, V])
+	access(e *entry[K, V])
+	remove(e *entry[K, V])
+	// touch 记录一次未命中的访问
+	touch(hash uint64)
+	// victim 返回下一条需要淘汰的数据
+	victim() *entry[K, V]
+}
+
+func newPolicy[K comparable, V any](kind Policy, capacity int64) policy[K, V] {
+	switch kind {
+	case LFU:
+		return &lfuPolicy[K, V]{}
+	case TinyLFU:
+		// 没有容量限制时不会淘汰，准入策略没有意义
+		if capacity > 0 {
+			return newTinyLFUPolicy[K, V](capacity)
+		}
+	}
+	return &lruPolicy[K, V]{ll: list.New()}
+}
+
+type lruPolicy[K comparable, V any] struct {
+	ll *list.List
+}
+
+func (p *lruPolicy[K, V]) add(e *entry[K, V]) {
+	e.elem = p.ll.PushFront(e)
+}
+
+func (p *lruPolicy[K, V]) access(e *entry[K, V]) {
+	p.ll.MoveToFront(e.elem)
+}
+
+func (p *lruPolicy[K, V]) remove(e *entry[K, V]) {
+	p.ll.Remove(e.elem)
+}
+
+func (p *lruPolicy[K, V]) touch(uint64) {}
+
+func (p *lruPolicy[K, V]) victim() *entry[K, V] {
+	if back := p.ll.Back(); back != nil {
+		return back.Value.(*entry[K, V])
+	}
+	return nil
+}
+
+// lfuPolicy 按(访问次数, 最后访问时间)排序的小顶堆
+type lfuPolicy[K comparable, V any] struct {
+	items []*entry[K, V]
+	tick  uint64
+}
+
+func (p *lfuPolicy[K, V]) Len() int { return len(p.items) }
+
+func (p *lfuPolicy[K, V]) Less(i, j int) bool {
+	a, b := p.items[i], p.items[j]
+	if a.freq != b.freq {
+		return a.freq < b.freq
+	}
+	return a.tick < b.tick
+}
+
+func (p *lfuPolicy[K, V]) Swap(i, j int) {
+	p.items[i], p.items[j] = p.items[j], p.items[i]
+	p.items[i].index = i
+	p.items[j].index = j
+}
+
+func (p *lfuPolicy[K, V]) Push(x any) {
+	e := x.(*entry[K, V])
+	e.index = len(p.items)
+	p.items = append(p.items, e)
+}
+
+func (p *lfuPolicy[K, V]) Pop() any {
+	n := len(p.items) - 1
+	e := p.items[n]
+	p.items[n] = nil
+	p.items = p.items[:n]
+	e.index = -1
+	return e
+}
+
+func (p *lfuPolicy[K, V]) add(e *entry[K, V]) {
+	// 覆盖写入时保留原来的访问次数
+	e.freq++
+	p.tick++
+	e.tick = p.tick
+	heap.Push(p, e)
+}
+
+func (p *lfuPolicy[K, V]) access(e *entry[K, V]) {
+	if e.freq < math.MaxUint64 {
+		e.freq++
+	}
+	p.tick++
+	e.tick = p.tick
+	heap.Fix(p, e.index)
+}
+
+func (p *lfuPolicy[K, V]) remove(e *entry[K, V]) {
+	heap.Remove(p, e.index)
+}
+
+func (p *lfuPolicy[K, V]) touch(uint64) {}
+
+func (p *lfuPolicy[K, V]) victim() *entry[K, V] {
+	if len(p.items) == 0 {
+		return nil
+	}
+	return p.items[0]
+}
@@ -0,0 +1,171 @@
+package cache
+
+import "container/list"
+
+const (
+	windowRegion uint8 = iota
+	probationRegion
+	protectedRegion
+)
+
+// tinyLFUPolicy W-TinyLFU
+// 窗口区(1%)为LRU，主区域为SLRU，其中保护区占80%，试用区占20%。
+// 数据离开窗口后进入试用区头部，需要淘汰时比较试用区头部(新来的候选)和尾部(最久没有访问)的访问频率，
+// 频率更高的留下；试用区的数据再次被访问后晋升到保护区，保护区超出容量时降级回试用区
+type tinyLFUPolicy[K comparable, V any] struct {
+	sketch *countMinSketch
+
+	window, probation, protected *list.List
+
+	windowWeight, protectedWeight int64
+	windowMax, protectedMax       int64
+}
+
+func newTinyLFUPolicy[K comparable, V any](capacity int64) *tinyLFUPolicy[K, V] {
+	windowMax := capacity / 100
+	if windowMax < 1 {
+		windowMax = 1
+	}
+	return &tinyLFUPolicy[K, V]{
+		sketch:       newCountMinSketch(capacity),
+		window:       list.New(),
+		probation:    list.New(),
+		protected:    list.New(),
+		windowMax:    windowMax,
+		protectedMax: (capacity - windowMax) * 8 / 10,
+	}
+}
+
+func (p *tinyLFUPolicy[K, V]) add(e *entry[K, V]) {
+	p.sketch.increment(e.hash)
+	e.region = windowRegion
+	e.elem = p.window.PushFront(e)
+	p.windowWeight += e.weight
+	for p.windowWeight > p.windowMax && p.window.Len() > 1 {
+		tail := p.window.Remove(p.window.Back()).(*entry[K, V])
+		p.windowWeight -= tail.weight
+		tail.region = probationRegion
+		tail.elem = p.probation.PushFront(tail)
+	}
+}
+
+func (p *tinyLFUPolicy[K, V]) access(e *entry[K, V]) {
+	p.sketch.increment(e.hash)
+	switch e.region {
+	case windowRegion:
+		p.window.MoveToFront(e.elem)
+	case probationRegion:
+		p.probation.Remove(e.elem)
+		e.region = protectedRegion
+		e.elem = p.protected.PushFront(e)
+		p.protectedWeight += e.weight
+		for p.protectedWeight > p.protectedMax && p.protected.Len() > 1 {
+			tail := p.protected.Remove(p.protected.Back()).(*entry[K, V])
+			p.protectedWeight -= tail.weight
+			tail.region = probationRegion
+			tail.elem = p.probation.PushFront(tail)
+		}
+	case protectedRegion:
+		p.protected.MoveToFront(e.elem)
+	}
+}
+
+func (p *tinyLFUPolicy[K, V]) remove(e *entry[K, V]) {
+	switch e.region {
+	case windowRegion:
+		p.window.Remove(e.elem)
+		p.windowWeight -= e.weight
+	case probationRegion:
+		p.probation.Remove(e.elem)
+	case protectedRegion:
+		p.protected.Remove(e.elem)
+		p.protectedWeight -= e.weight
+	}
+}
+
+func (p *tinyLFUPolicy[K, V]) touch(hash uint64) {
+	p.sketch.increment(hash)
+}
+
+func (p *tinyLFUPolicy[K, V]) victim() *entry[K, V] {
+	if back := p.probation.Back(); back != nil {
+		victim := back.Value.(*entry[K, V])
+		candidate := p.probation.Front().Value.(*entry[K, V])
+		if candidate != victim && p.sketch.estimate(candidate.hash) <= p.sketch.estimate(victim.hash) {
+			return candidate
+		}
+		return victim
+	}
+	if back := p.protected.Back(); back != nil {
+		return back.Value.(*entry[K, V])
+	}
+	if back := p.window.Back(); back != nil {
+		return back.Value.(*entry[K, V])
+	}
+	return nil
+}
+
+const (
+	sketchDepth   = 4
+	maxSketchFreq = 15
+	// 累计增加次数达到宽度的倍数后所有计数减半，让旧的热点逐渐冷却
+	sketchResetFactor = 10
+)
+
+var sketchSeeds = [sketchDepth]uint64{0xc3a5c85c97cb3127, 0xb492b66fbe98f273, 0x9ae16a3b2f90404f, 0xcbf29ce484222325}
+
+// countMinSketch 估算访问频率，计数上限为15
+type countMinSketch struct {
+	rows      [sketchDepth][]uint8
+	mask      uint64
+	additions int64
+	resetAt   int64
+}
+
+func newCountMinSketch(capacity int64) *countMinSketch {
+	if capacity < 16 {
+		capacity = 16
+	}
+	width := nextPowerOfTwo(uint64(capacity))
+	s := &countMinSketch{mask: width - 1, resetAt: int64(width) * sketchResetFactor}
+	for i := range s.rows {
+		s.rows[i] = make([]uint8, width)
+	}
+	return s
+}
+
+func (s *countMinSketch) index(hash uint64, i int) uint64 {
+	return mix64(hash^sketchSeeds[i]) & s.mask
+}
+
+func (s *countMinSketch) increment(hash uint64) {
+	for i := range s.rows {
+		idx := s.index(hash, i)
+		if s.rows[i][idx] < maxSketchFreq {
+			s.rows[i][idx]++
+		}
+	}
+	s.additions++
+	if s.additions >= s.resetAt {
+		s.reset()
+	}
+}
+
+func (s *countMinSketch) estimate(hash uint64) uint8 {
+	min := uint8(maxSketchFreq)
+	for i := range s.rows {
+		if v := s.rows[i][s.index(hash, i)]; v < min {
+			min = v
+		}
+	}
+	return min
+}
+
+func (s *countMinSketch) reset() {
+	for i := range s.rows {
+		for j := range s.rows[i] {
+			s.rows[i][j] >>= 1
+		}
+	}
+	s.additions /= 2
+}
@@ -0,0 +1,360 @@
+package cache
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"math/rand"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/go-redis/redis/v8"
+	"github.com/google/uuid"
+
+	"singer.com/basic/log"
+	"singer.com/basic/single"
+	"singer.com/util/clock"
+)
+
+// ErrNotFound 数据不存在，Loader返回ErrNotFound时会缓存空值
+var ErrNotFound = errors.New("cache: not found")
+
+// nullValue redis中空值的占位，序列化后的数据不能与它相同
+const nullValue = "\x00null"
+
+// BloomFilter 保存所有存在的key，redis中没有且Exists返回false时不再调用Loader，直接返回ErrNotFound。
+// TwoLevel在Set和Loader加载到数据时把key加入过滤器，过滤器应当预先包含后端存储中所有的key
+// (通过TwoLevelConfig.BloomWarmUp)，并且在缓存之外写入后端存储时通过TwoLevel.AddToBloom保持同步。
+// 遗漏的key只能通过TwoLevelConfig.BloomFallbackRate抽样加载后加入过滤器，在此之前会返回ErrNotFound
+type BloomFilter interface {
+	Add(ctx context.Context, key string) error
+	Exists(ctx context.Context, key string) (bool, error)
+}
+
+type TwoLevelConfig[K comparable, V any] struct {
+	Client *redis.Client
+	// redis key的前缀，失效广播的channel为Prefix+"invalidate"
+	Prefix string
+	// redis过期时间，默认10分钟
+	TTL time.Duration
+
+	// 本地缓存的淘汰策略、最大条数(默认10000)和过期时间(默认1分钟)。
+	// 失效广播丢失时(如与redis断开)，本地数据最多在LocalTTL后更新
+	LocalPolicy     Policy
+	LocalMaxEntries int
+	LocalTTL        time.Duration
+
+	// Loader返回ErrNotFound时空值的缓存时间，0表示不缓存空值
+	NullTTL time.Duration
+	// 过期时间随机增加[0, TTL*Jitter)，避免大量key同时过期，默认0.1，小于0表示不增加
+	Jitter float64
+	// 可选，防止查询不存在的key时穿透到Loader，需要包含所有存在的key，见BloomFilter
+	Bloom BloomFilter
+	// 创建时调用，通过add把后端存储中已有的key加入Bloom，如遍历数据库的主键
+	BloomWarmUp func(ctx context.Context, add func(key K) error) error
+	// Bloom返回不存在时仍然调用Loader的比例，加载到数据时把key加入Bloom，
+	// 使在缓存之外写入后端存储的key最终可以读取。默认0.01，小于0表示不调用
+	BloomFallbackRate float64
+
+	// 默认使用json
+	Marshal   func(value V) ([]byte, error)
+	Unmarshal func(data []byte, value *V) error
+	// redis中不存在时的加载函数，为nil时返回ErrNotFound
+	Loader func(ctx context.Context, key K) (V, error)
+	// 查询redis和Loader的超时时间，默认10s。查询在不随调用方取消的ctx中执行，某个调用方取消不影响其他等待的调用方
+	LoadTimeout time.Duration
+	Clock  clock.PassiveClock
+}
+
+func (c TwoLevelConfig[K, V]) WithDefaults() TwoLevelConfig[K, V] {
+	if c.TTL <= 0 {
+		c.TTL = 10 * time.Minute
+	}
+	if c.LocalMaxEntries <= 0 {
+		c.LocalMaxEntries = 10000
+	}
+	if c.LocalTTL <= 0 {
+		c.LocalTTL = time.Minute
+	}
+	if c.LoadTimeout <= 0 {
+		c.LoadTimeout = defaultLoadTimeout
+	}
+	if c.Jitter == 0 {
+		c.Jitter = 0.1
+	}
+	if c.BloomFallbackRate == 0 {
+		c.BloomFallbackRate = 0.01
+	}
+	if c.Marshal == nil {
+		c.Marshal = func(value V) ([]byte, error) { return json.Marshal(value) }
+	}
+	if c.Unmarshal == nil {
+		c.Unmarshal = func(data []byte, value *V) error { return json.Unmarshal(data, value) }
+	}
+	return c
+}
+
+type item[V any] struct {
+	value V
+	null  bool
+}
+
+// TwoLevel 本地缓存+redis的二级缓存。
+// 读取顺序为本地、redis、Loader，写入时同时写redis和本地，并通过redis pub/sub通知其他实例删除本地数据
+type TwoLevel[K comparable, V any] struct {
+	cfg     TwoLevelConfig[K, V]
+	local   *Cache[string, item[V]]
+	group   single.Group
+	id      string
+	channel string
+	pubsub  *redis.PubSub
+
+	rndMtx sync.Mutex
+	rnd    *rand.Rand
+}
+
+// NewTwoLevel 创建二级缓存并订阅失效广播，不再使用时需要调用Close
+func NewTwoLevel[K comparable, V any](cfg TwoLevelConfig[K, V]) (*TwoLevel[K, V], error) {
+	cfg = cfg.WithDefaults()
+	t := &TwoLevel[K, V]{
+		cfg: cfg,
+		local: New(Config[string, item[V]]{
+			Policy:     cfg.LocalPolicy,
+			MaxEntries: cfg.LocalMaxEntries,
+			Clock:      cfg.Clock,
+		}),
+		group:   single.New(),
+		id:      uuid.NewString(),
+		channel: cfg.Prefix + "invalidate",
+		rnd:     rand.New(rand.NewSource(time.Now().UnixNano())),
+	}
+
+	t.pubsub = cfg.Client.Subscribe(context.Background(), t.channel)
+	// 等待订阅成功，避免创建后马上写入的数据收不到广播
+	if _, err := t.pubsub.Receive(context.Background()); err != nil {
+		t.pubsub.Close()
+		return nil, err
+	}
+	go t.watch(t.pubsub.Channel())
+
+	if cfg.Bloom != nil && cfg.BloomWarmUp != nil {
+		ctx := context.Background()
+		err := cfg.BloomWarmUp(ctx, func(key K) error {
+			return cfg.Bloom.Add(ctx, keyString(key))
+		})
+		if err != nil {
+			t.Close()
+			return nil, err
+		}
+	}
+	return t, nil
+}
+
+func (t *TwoLevel[K, V]) watch(ch <-chan *redis.Message) {
+	for msg := range ch {
+		parts := strings.SplitN(msg.Payload, " ", 2)
+		if len(parts) != 2 || parts[0] == t.id {
+			continue
+		}
+		t.local.Delete(parts[1])
+	}
+}
+
+// Close 取消订阅，不会关闭redis客户端
+func (t *TwoLevel[K, V]) Close() error {
+	return t.pubsub.Close()
+}
+
+// Get 依次查询本地、redis和Loader，数据不存在时返回ErrNotFound，并发的相同key只查询一次。
+// ctx取消时返回ctx.Err()，查询继续进行，不影响其他等待的调用方
+func (t *TwoLevel[K, V]) Get(ctx context.Context, key K) (V, error) {
+	var zero V
+	k := keyString(key)
+	it, ok := t.local.Get(k)
+	if !ok {
+		v, err := load(ctx, &t.group, k, t.cfg.LoadTimeout, func(ctx context.Context) (interface{}, error) {
+			return t.load(ctx, key, k)
+		})
+		if err != nil {
+			return zero, err
+		}
+		it = v.(item[V])
+	}
+	if it.null {
+		return zero, ErrNotFound
+	}
+	return it.value, nil
+}
+
+func (t *TwoLevel[K, V]) load(ctx context.Context, key K, k string) (item[V], error) {
+	if it, ok := t.local.get(k, false); ok {
+		return it, nil
+	}
+
+	writeRedis := true
+	data, err := t.cfg.Client.Get(ctx, t.cfg.Prefix+k).Bytes()
+	switch {
+	case err == nil:
+		if string(data) == nullValue {
+			it := item[V]{null: true}
+			if t.cfg.NullTTL > 0 {
+				t.local.SetWithTTL(k, it, t.nullLocalTTL())
+			}
+			return it, nil
+		}
+		var v V
+		if err = t.cfg.Unmarshal(data, &v); err == nil {
+			it := item[V]{value: v}
+			t.local.SetWithTTL(k, it, t.jitter(t.cfg.LocalTTL))
+			return it, nil
+		}
+		log.FromContext(ctx).Warnf("cache: unmarshal %s failed, err: %v", k, err)
+	case err != redis.Nil:
+		// redis不可用时直接使用Loader，也不再写入redis
+		log.FromContext(ctx).Warnf("cache: get %s failed, err: %v", k, err)
+		writeRedis = false
+	}
+
+	inBloom := false
+	if t.cfg.Bloom != nil {
+		exists, err := t.cfg.Bloom.Exists(ctx, k)
+		if err != nil {
+			log.FromContext(ctx).Warnf("cache: bloom check %s failed, err: %v", k, err)
+		} else if !exists && !t.sample(t.cfg.BloomFallbackRate) {
+			return item[V]{null: true}, nil
+		}
+		inBloom = exists
+	}
+	if t.cfg.Loader == nil {
+		return item[V]{null: true}, nil
+	}
+
+	v, err := t.cfg.Loader(ctx, key)
+	if errors.Is(err, ErrNotFound) {
+		it := item[V]{null: true}
+		if t.cfg.NullTTL > 0 && (!writeRedis || t.writeBack(ctx, k, []byte(nullValue), t.cfg.NullTTL)) {
+			t.local.SetWithTTL(k, it, t.nullLocalTTL())
+		}
+		return it, nil
+	}
+	if err != nil {
+		return item[V]{}, err
+	}
+
+	// 过滤器中缺少的key(检查失败或抽样加载到的数据)加入过滤器
+	if t.cfg.Bloom != nil && !inBloom {
+		if err := t.cfg.Bloom.Add(ctx, k); err != nil {
+			log.FromContext(ctx).Warnf("cache: bloom add %s failed, err: %v", k, err)
+		}
+	}
+
+	it := item[V]{value: v}
+	if writeRedis {
+		data, err := t.cfg.Marshal(v)
+		if err != nil {
+			log.FromContext(ctx).Warnf("cache: marshal %s failed, err: %v", k, err)
+			return it, nil
+		}
+		if !t.writeBack(ctx, k, data, t.cfg.TTL) {
+			return it, nil
+		}
+	}
+	t.local.SetWithTTL(k, it, t.jitter(t.cfg.LocalTTL))
+	return it, nil
+}
+
+// writeBack 用SETNX把Loader的结果写回redis，加载期间其他实例通过Set写入的新值不会被覆盖。
+// 没有写入时返回false，这时不写入本地缓存，下次从redis读取新值
+func (t *TwoLevel[K, V]) writeBack(ctx context.Context, k string, data []byte, ttl time.Duration) bool {
+	ok, err := t.cfg.Client.SetNX(ctx, t.cfg.Prefix+k, data, t.jitter(ttl)).Result()
+	if err != nil {
+		log.FromContext(ctx).Warnf("cache: set %s failed, err: %v", k, err)
+		return false
+	}
+	return ok
+}
+
+// Set 写入redis和本地，并通知其他实例删除本地数据
+func (t *TwoLevel[K, V]) Set(ctx context.Context, key K, value V) error {
+	k := keyString(key)
+	if err := t.setRedis(ctx, k, value); err != nil {
+		return err
+	}
+	if t.cfg.Bloom != nil {
+		if err := t.cfg.Bloom.Add(ctx, k); err != nil {
+			log.FromContext(ctx).Warnf("cache: bloom add %s failed, err: %v", k, err)
+		}
+	}
+	t.local.SetWithTTL(k, item[V]{value: value}, t.jitter(t.cfg.LocalTTL))
+	t.publish(ctx, k)
+	return nil
+}
+
+// Delete 删除redis和本地数据，并通知其他实例删除本地数据
+func (t *TwoLevel[K, V]) Delete(ctx context.Context, key K) error {
+	k := keyString(key)
+	err := t.cfg.Client.Del(ctx, t.cfg.Prefix+k).Err()
+	t.local.Delete(k)
+	t.publish(ctx, k)
+	return err
+}
+
+// AddToBloom 把key加入Bloom，数据在缓存之外写入后端存储时需要调用，没有配置Bloom时不做任何事
+func (t *TwoLevel[K, V]) AddToBloom(ctx context.Context, keys ...K) error {
+	if t.cfg.Bloom == nil {
+		return nil
+	}
+	for _, key := range keys {
+		if err := t.cfg.Bloom.Add(ctx, keyString(key)); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Stats 本地缓存的统计
+func (t *TwoLevel[K, V]) Stats() Stats {
+	return t.local.Stats()
+}
+
+func (t *TwoLevel[K, V]) setRedis(ctx context.Context, k string, value V) error {
+	data, err := t.cfg.Marshal(value)
+	if err != nil {
+		return err
+	}
+	return t.cfg.Client.Set(ctx, t.cfg.Prefix+k, data, t.jitter(t.cfg.TTL)).Err()
+}
+
+func (t *TwoLevel[K, V]) publish(ctx context.Context, k string) {
+	if err := t.cfg.Client.Publish(ctx, t.channel, t.id+" "+k).Err(); err != nil {
+		log.FromContext(ctx).Warnf("cache: publish invalidation %s failed, err: %v", k, err)
+	}
+}
+
+func (t *TwoLevel[K, V]) nullLocalTTL() time.Duration {
+	if t.cfg.NullTTL < t.cfg.LocalTTL {
+		return t.jitter(t.cfg.NullTTL)
+	}
+	return t.jitter(t.cfg.LocalTTL)
+}
+
+// sample 以rate的概率返回true
+func (t *TwoLevel[K, V]) sample(rate float64) bool {
+	if rate <= 0 {
+		return false
+	}
+	t.rndMtx.Lock()
+	defer t.rndMtx.Unlock()
+	return t.rnd.Float64() < rate
+}
+
+func (t *TwoLevel[K, V]) jitter(ttl time.Duration) time.Duration {
+	max := int64(float64(ttl) * t.cfg.Jitter)
+	if max <= 0 {
+		return ttl
+	}
+	t.rndMtx.Lock()
+	defer t.rndMtx.Unlock()
+	return ttl + time.Duration(t.rnd.Int63n(max))
+}
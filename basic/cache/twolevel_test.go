@@ -0,0 +1,366 @@
+package cache
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/alicebob/miniredis/v2"
+	"github.com/go-redis/redis/v8"
+	"github.com/stretchr/testify/assert"
+)
+
+type user struct {
+	ID   int
+	Name string
+}
+
+type mapBloom struct {
+	sync.Mutex
+	keys map[string]bool
+}
+
+func (b *mapBloom) Add(ctx context.Context, key string) error {
+	b.Lock()
+	defer b.Unlock()
+	b.keys[key] = true
+	return nil
+}
+
+func (b *mapBloom) Exists(ctx context.Context, key string) (bool, error) {
+	b.Lock()
+	defer b.Unlock()
+	return b.keys[key], nil
+}
+
+func newTwoLevel(t *testing.T, cfg TwoLevelConfig[int, user]) *TwoLevel[int, user] {
+	tl, err := NewTwoLevel(cfg)
+	assert.Nil(t, err)
+	t.Cleanup(func() { tl.Close() })
+	return tl
+}
+
+func TestTwoLevel(t *testing.T) {
+	ctx := context.Background()
+	mr := miniredis.RunT(t)
+	client := redis.NewClient(&redis.Options{Addr: mr.Addr()})
+
+	var loads int32
+	cfg := TwoLevelConfig[int, user]{
+		Client: client,
+		Prefix: "user:",
+		TTL:    time.Hour,
+		Loader: func(ctx context.Context, id int) (user, error) {
+			atomic.AddInt32(&loads, 1)
+			return user{ID: id, Name: "db"}, nil
+		},
+	}
+	a := newTwoLevel(t, cfg)
+	b := newTwoLevel(t, cfg)
+
+	u, err := a.Get(ctx, 1)
+	assert.Nil(t, err)
+	assert.Equal(t, user{ID: 1, Name: "db"}, u)
+	assert.True(t, mr.Exists("user:1"))
+	// 带随机增加的过期时间
+	assert.GreaterOrEqual(t, mr.TTL("user:1"), time.Hour)
+	assert.Less(t, mr.TTL("user:1"), time.Hour+6*time.Minute)
+
+	// b从redis读取
+	u, err = b.Get(ctx, 1)
+	assert.Nil(t, err)
+	assert.Equal(t, "db", u.Name)
+	assert.Equal(t, int32(1), atomic.LoadInt32(&loads))
+
+	// a更新后b的本地数据失效
+	assert.Nil(t, a.Set(ctx, 1, user{ID: 1, Name: "new"}))
+	assert.Eventually(t, func() bool {
+		u, err := b.Get(ctx, 1)
+		return err == nil && u.Name == "new"
+	}, time.Second, 10*time.Millisecond)
+	u, _ = a.Get(ctx, 1)
+	assert.Equal(t, "new", u.Name)
+
+	assert.Nil(t, b.Delete(ctx, 1))
+	assert.False(t, mr.Exists("user:1"))
+	assert.Eventually(t, func() bool {
+		_, ok := a.local.get("1", false)
+		return !ok
+	}, time.Second, 10*time.Millisecond)
+}
+
+func TestTwoLevelLoadDoesNotOverwriteSet(t *testing.T) {
+	ctx := context.Background()
+	mr := miniredis.RunT(t)
+	client := redis.NewClient(&redis.Options{Addr: mr.Addr()})
+
+	loading, release := make(chan struct{}), make(chan struct{})
+	slow := newTwoLevel(t, TwoLevelConfig[int, user]{
+		Client: client,
+		Prefix: "user:",
+		Loader: func(ctx context.Context, id int) (user, error) {
+			close(loading)
+			<-release
+			return user{ID: id, Name: "old"}, nil
+		},
+	})
+	other := newTwoLevel(t, TwoLevelConfig[int, user]{Client: client, Prefix: "user:"})
+
+	done := make(chan user)
+	go func() {
+		u, _ := slow.Get(ctx, 1)
+		done <- u
+	}()
+	<-loading
+	// 加载期间其他实例写入新值，加载到的旧值不会覆盖新值
+	assert.Nil(t, other.Set(ctx, 1, user{ID: 1, Name: "new"}))
+	close(release)
+	assert.Equal(t, "old", (<-done).Name)
+
+	u, err := other.Get(ctx, 1)
+	assert.Nil(t, err)
+	assert.Equal(t, "new", u.Name)
+	u, err = slow.Get(ctx, 1)
+	assert.Nil(t, err)
+	assert.Equal(t, "new", u.Name)
+}
+
+func TestTwoLevelNull(t *testing.T) {
+	ctx := context.Background()
+	mr := miniredis.RunT(t)
+	clk := &fakeClock{now: time.Now()}
+
+	var loads int32
+	c := newTwoLevel(t, TwoLevelConfig[int, user]{
+		Client:  redis.NewClient(&redis.Options{Addr: mr.Addr()}),
+		NullTTL: time.Minute,
+		Jitter:  -1,
+		Clock:   clk,
+		Loader: func(ctx context.Context, id int) (user, error) {
+			atomic.AddInt32(&loads, 1)
+			return user{}, ErrNotFound
+		},
+	})
+
+	for i := 0; i < 3; i++ {
+		_, err := c.Get(ctx, 1)
+		assert.Equal(t, ErrNotFound, err)
+	}
+	assert.Equal(t, int32(1), atomic.LoadInt32(&loads))
+	assert.Equal(t, time.Minute, mr.TTL("1"))
+
+	clk.now = clk.now.Add(time.Minute)
+	mr.FastForward(time.Minute)
+	_, err := c.Get(ctx, 1)
+	assert.Equal(t, ErrNotFound, err)
+	assert.Equal(t, int32(2), atomic.LoadInt32(&loads))
+}
+
+func TestTwoLevelBloom(t *testing.T) {
+	ctx := context.Background()
+	mr := miniredis.RunT(t)
+
+	var loads int32
+	bloom := &mapBloom{keys: map[string]bool{"1": true}}
+	c := newTwoLevel(t, TwoLevelConfig[int, user]{
+		Client: redis.NewClient(&redis.Options{Addr: mr.Addr()}),
+		Bloom:  bloom,
+		// 不抽样加载
+		BloomFallbackRate: -1,
+		Loader: func(ctx context.Context, id int) (user, error) {
+			atomic.AddInt32(&loads, 1)
+			return user{ID: id}, nil
+		},
+	})
+
+	_, err := c.Get(ctx, 2)
+	assert.Equal(t, ErrNotFound, err)
+	assert.Equal(t, int32(0), atomic.LoadInt32(&loads))
+	u, err := c.Get(ctx, 1)
+	assert.Nil(t, err)
+	assert.Equal(t, 1, u.ID)
+
+	assert.Nil(t, c.Set(ctx, 3, user{ID: 3}))
+	ok, _ := bloom.Exists(ctx, "3")
+	assert.True(t, ok)
+}
+
+func TestTwoLevelRedisDown(t *testing.T) {
+	ctx := context.Background()
+	mr := miniredis.RunT(t)
+
+	c := newTwoLevel(t, TwoLevelConfig[int, user]{
+		Client: redis.NewClient(&redis.Options{Addr: mr.Addr(), MaxRetries: -1}),
+		Loader: func(ctx context.Context, id int) (user, error) {
+			if id == 2 {
+				return user{}, errors.New("db error")
+			}
+			return user{ID: id}, nil
+		},
+	})
+	mr.Close()
+
+	u, err := c.Get(ctx, 1)
+	assert.Nil(t, err)
+	assert.Equal(t, 1, u.ID)
+	_, err = c.Get(ctx, 2)
+	assert.EqualError(t, err, "db error")
+	assert.NotNil(t, c.Set(ctx, 3, user{ID: 3}))
+}
+
+// errBloom 检查总是失败
+type errBloom struct {
+	mapBloom
+}
+
+func (b *errBloom) Exists(ctx context.Context, key string) (bool, error) {
+	return false, errors.New("bloom unavailable")
+}
+
+func TestTwoLevelBloomAddLoaded(t *testing.T) {
+	ctx := context.Background()
+	mr := miniredis.RunT(t)
+	client := redis.NewClient(&redis.Options{Addr: mr.Addr()})
+	// 在缓存之外写入后端存储，没有加入过滤器
+	db := map[int]user{1: {ID: 1}}
+	loader := func(ctx context.Context, id int) (user, error) {
+		if u, ok := db[id]; ok {
+			return u, nil
+		}
+		return user{}, ErrNotFound
+	}
+
+	// 抽样加载到的数据加入过滤器
+	bloom := &mapBloom{keys: map[string]bool{}}
+	sampled := newTwoLevel(t, TwoLevelConfig[int, user]{
+		Client:            client,
+		Prefix:            "sampled:",
+		Bloom:             bloom,
+		BloomFallbackRate: 1,
+		Loader:            loader,
+	})
+	u, err := sampled.Get(ctx, 1)
+	assert.Nil(t, err)
+	assert.Equal(t, 1, u.ID)
+	ok, _ := bloom.Exists(ctx, "1")
+	assert.True(t, ok)
+	_, err = sampled.Get(ctx, 2)
+	assert.Equal(t, ErrNotFound, err)
+	ok, _ = bloom.Exists(ctx, "2")
+	assert.False(t, ok)
+
+	// 过滤器检查失败时调用Loader，加载到的数据加入过滤器
+	eb := &errBloom{mapBloom{keys: map[string]bool{}}}
+	broken := newTwoLevel(t, TwoLevelConfig[int, user]{
+		Client:            client,
+		Prefix:            "broken:",
+		Bloom:             eb,
+		BloomFallbackRate: -1,
+		Loader:            loader,
+	})
+	u, err = broken.Get(ctx, 1)
+	assert.Nil(t, err)
+	assert.Equal(t, 1, u.ID)
+	ok, _ = eb.mapBloom.Exists(ctx, "1")
+	assert.True(t, ok)
+}
+
+func TestTwoLevelBloomWarmUp(t *testing.T) {
+	ctx := context.Background()
+	mr := miniredis.RunT(t)
+	client := redis.NewClient(&redis.Options{Addr: mr.Addr()})
+	// 后端存储中存在的数据
+	db := map[int]user{1: {ID: 1}, 2: {ID: 2}, 3: {ID: 3}}
+	loader := func(ctx context.Context, id int) (user, error) {
+		if u, ok := db[id]; ok {
+			return u, nil
+		}
+		return user{}, ErrNotFound
+	}
+
+	// 过滤器为空且不抽样加载时，只通过Loader加载的数据也会返回ErrNotFound
+	empty := newTwoLevel(t, TwoLevelConfig[int, user]{
+		Client:            client,
+		Prefix:            "empty:",
+		Bloom:             &mapBloom{keys: map[string]bool{}},
+		BloomFallbackRate: -1,
+		Loader:            loader,
+	})
+	_, err := empty.Get(ctx, 1)
+	assert.Equal(t, ErrNotFound, err)
+
+	warm := newTwoLevel(t, TwoLevelConfig[int, user]{
+		Client: client,
+		Prefix: "warm:",
+		Bloom:  &mapBloom{keys: map[string]bool{}},
+		BloomWarmUp: func(ctx context.Context, add func(key int) error) error {
+			for id := range db {
+				if err := add(id); err != nil {
+					return err
+				}
+			}
+			return nil
+		},
+		Loader: loader,
+	})
+	for id := range db {
+		u, err := warm.Get(ctx, id)
+		assert.Nil(t, err)
+		assert.Equal(t, id, u.ID)
+	}
+
+	// 在缓存之外写入后端存储
+	db[4] = user{ID: 4}
+	assert.Nil(t, warm.AddToBloom(ctx, 4))
+	u, err := warm.Get(ctx, 4)
+	assert.Nil(t, err)
+	assert.Equal(t, 4, u.ID)
+
+	_, err = NewTwoLevel(TwoLevelConfig[int, user]{
+		Client: client,
+		Bloom:  &mapBloom{keys: map[string]bool{}},
+		BloomWarmUp: func(ctx context.Context, add func(key int) error) error {
+			return errors.New("scan failed")
+		},
+	})
+	assert.EqualError(t, err, "scan failed")
+}
+
+func TestTwoLevelGetCanceled(t *testing.T) {
+	mr := miniredis.RunT(t)
+	release := make(chan struct{})
+	c := newTwoLevel(t, TwoLevelConfig[int, user]{
+		Client: redis.NewClient(&redis.Options{Addr: mr.Addr()}),
+		Loader: func(ctx context.Context, id int) (user, error) {
+			select {
+			case <-release:
+				return user{ID: id}, nil
+			case <-ctx.Done():
+				return user{}, ctx.Err()
+			}
+		},
+	})
+
+	// 发起查询的调用方取消后，其他等待的调用方仍然得到结果
+	first, cancel := context.WithCancel(context.Background())
+	firstDone := make(chan error, 1)
+	go func() {
+		_, err := c.Get(first, 1)
+		firstDone <- err
+	}()
+	time.Sleep(20 * time.Millisecond)
+	done := make(chan user)
+	go func() {
+		u, err := c.Get(context.Background(), 1)
+		assert.Nil(t, err)
+		done <- u
+	}()
+	time.Sleep(20 * time.Millisecond)
+	cancel()
+	assert.Equal(t, context.Canceled, <-firstDone)
+	close(release)
+	assert.Equal(t, 1, (<-done).ID)
+}
@@ -0,0 +1,120 @@
+package errorx
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+)
+
+/*
+错误码目录文件，支持yaml和json格式，例如:
+
+	codes:
+	  - code: 200001
+	    name: USER_NOT_FOUND
+	    messages:
+	      zh-CN: 用户不存在
+	      en-US: User not found
+	    grpc_code: NotFound   # 可选, 也可写作 NOT_FOUND, 默认Unknown
+	    http_status: 404      # 可选, 默认由grpc_code推导
+*/
+type Catalog struct {
+	Codes []CodeDef `json:"codes" yaml:"codes"`
+}
+
+type CodeDef struct {
+	Code       ErrorCode       `json:"code" yaml:"code"`
+	Name       string          `json:"name" yaml:"name"`
+	Messages   map[Lang]string `json:"messages" yaml:"messages"`
+	GRPCCode   string          `json:"grpc_code,omitempty" yaml:"grpc_code,omitempty"`
+	HTTPStatus int             `json:"http_status,omitempty" yaml:"http_status,omitempty"`
+}
+
+func (d *CodeDef) options() ([]CodeOption, error) {
+	opts := make([]CodeOption, 0, 2)
+	if d.GRPCCode != "" {
+		c, err := parseGRPCCode(d.GRPCCode)
+		if err != nil {
+			return nil, err
+		}
+		opts = append(opts, WithGRPCCode(c))
+	}
+	if d.HTTPStatus != 0 {
+		opts = append(opts, WithHTTPStatus(d.HTTPStatus))
+	}
+	return opts, nil
+}
+
+// LoadCatalog 根据文件后缀(.yaml/.yml/.json)加载错误码目录
+func LoadCatalog(path string) (*Catalog, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	return ParseCatalog(data, strings.TrimPrefix(filepath.Ext(path), "."))
+}
+
+// ParseCatalog format 为 yaml、yml 或 json
+func ParseCatalog(data []byte, format string) (*Catalog, error) {
+	var c Catalog
+	var err error
+	switch strings.ToLower(format) {
+	case "yaml", "yml":
+		err = yaml.Unmarshal(data, &c)
+	case "json":
+		err = json.Unmarshal(data, &c)
+	default:
+		return nil, fmt.Errorf("errorx: unsupported catalog format %q", format)
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &c, c.validate()
+}
+
+func (c *Catalog) validate() error {
+	codes := make(map[ErrorCode]struct{}, len(c.Codes))
+	names := make(map[string]struct{}, len(c.Codes))
+	for _, d := range c.Codes {
+		if _, ok := codes[d.Code]; ok {
+			return fmt.Errorf("errorx: duplicate code %d in catalog", d.Code)
+		}
+		codes[d.Code] = struct{}{}
+		if d.Name == "" {
+			return fmt.Errorf("errorx: code %d has no name", d.Code)
+		}
+		if _, ok := names[d.Name]; ok {
+			return fmt.Errorf("errorx: duplicate name %q in catalog", d.Name)
+		}
+		names[d.Name] = struct{}{}
+		if _, err := d.options(); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// RegisterCatalog 注册目录中所有错误码，遇到错误立即返回
+func RegisterCatalog(c *Catalog) error {
+	for _, d := range c.Codes {
+		opts, err := d.options()
+		if err != nil {
+			return err
+		}
+		if err := register(d.Code, d.Name, d.Messages, opts...); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// MustRegisterCatalog 同RegisterCatalog，注册失败时panic，供生成的代码在init中使用
+func MustRegisterCatalog(c *Catalog) {
+	if err := RegisterCatalog(c); err != nil {
+		panic(err)
+	}
+}
@@ -0,0 +1,92 @@
+package errorx
+
+import (
+	"time"
+
+	"github.com/golang/protobuf/proto"
+	"google.golang.org/genproto/googleapis/rpc/errdetails"
+	"google.golang.org/protobuf/types/known/durationpb"
+)
+
+/*
+	错误详情(google.rpc.Status.details)
+	通过 CodeError.WithDetails 或 errorx.WithDetails 附加到错误上，由error拦截器通过 status.WithDetails 发送给调用方
+*/
+
+// FieldViolation 描述某个请求字段的校验失败原因
+func FieldViolation(field, desc string) *errdetails.BadRequest_FieldViolation {
+	return &errdetails.BadRequest_FieldViolation{Field: field, Description: desc}
+}
+
+// BadRequest 参数错误详情
+func BadRequest(violations ...*errdetails.BadRequest_FieldViolation) *errdetails.BadRequest {
+	return &errdetails.BadRequest{FieldViolations: violations}
+}
+
+// RetryInfo 告诉调用方多久之后可以重试
+func RetryInfo(delay time.Duration) *errdetails.RetryInfo {
+	return &errdetails.RetryInfo{RetryDelay: durationpb.New(delay)}
+}
+
+// ErrorInfo 错误的结构化原因, domain一般为服务名, reason为大写下划线格式的错误原因
+func ErrorInfo(domain, reason string, metadata map[string]string) *errdetails.ErrorInfo {
+	return &errdetails.ErrorInfo{Domain: domain, Reason: reason, Metadata: metadata}
+}
+
+// LocalizedMessage 本地化的错误提示, locale遵循 BCP-47, 如 "zh-CN"、"en-US"
+func LocalizedMessage(locale, msg string) *errdetails.LocalizedMessage {
+	return &errdetails.LocalizedMessage{Locale: locale, Message: msg}
+}
+
+// WithDetails 给err的根因CodeError附加详情，err不是CodeError时原样返回
+func WithDetails(err error, details ...proto.Message) error {
+	if e, ok := Cause(err).(*CodeError); ok {
+		e.WithDetails(details...)
+	}
+	return err
+}
+
+func (e *CodeError) WithDetails(details ...proto.Message) *CodeError {
+	e.details = append(e.details, details...)
+	return e
+}
+
+func (e *CodeError) Details() []proto.Message {
+	return e.details
+}
+
+func (e *CodeError) BadRequest() *errdetails.BadRequest {
+	for _, d := range e.details {
+		if v, ok := d.(*errdetails.BadRequest); ok {
+			return v
+		}
+	}
+	return nil
+}
+
+func (e *CodeError) RetryInfo() *errdetails.RetryInfo {
+	for _, d := range e.details {
+		if v, ok := d.(*errdetails.RetryInfo); ok {
+			return v
+		}
+	}
+	return nil
+}
+
+func (e *CodeError) ErrorInfo() *errdetails.ErrorInfo {
+	for _, d := range e.details {
+		if v, ok := d.(*errdetails.ErrorInfo); ok {
+			return v
+		}
+	}
+	return nil
+}
+
+func (e *CodeError) LocalizedMessage() *errdetails.LocalizedMessage {
+	for _, d := range e.details {
+		if v, ok := d.(*errdetails.LocalizedMessage); ok {
+			return v
+		}
+	}
+	return nil
+}
@@ -1,17 +1,20 @@
 package errorx
 
 /**(前3位代表业务,后三位代表具体功能)**/
+/**业务模块通过 errorx.Register 或错误码目录(见 cmd/errorxgen)注册自己的错误码**/
 
 type ErrorCode uint32
 
 //全局错误码
 const (
-	OK                   ErrorCode = 200
-	SERVER_COMMON_ERROR  ErrorCode = 100001
-	REUQEST_PARAM_ERROR  ErrorCode = 100002
-	TOKEN_EXPIRE_ERROR   ErrorCode = 100003
-	TOKEN_GENERATE_ERROR ErrorCode = 100004
-	DB_ERROR             ErrorCode = 100005
+	OK                      ErrorCode = 200
+	SERVER_COMMON_ERROR     ErrorCode = 100001
+	REUQEST_PARAM_ERROR     ErrorCode = 100002
+	TOKEN_EXPIRE_ERROR      ErrorCode = 100003
+	TOKEN_GENERATE_ERROR    ErrorCode = 100004
+	DB_ERROR                ErrorCode = 100005
+	UNAUTHENTICATED_ERROR   ErrorCode = 100006
+	PERMISSION_DENIED_ERROR ErrorCode = 100007
 )
 
 //用户模块
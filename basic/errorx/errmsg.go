@@ -1,29 +1,283 @@
 package errorx
 
-var message map[ErrorCode]string
+import (
+	"fmt"
+	"net/http"
+	"sort"
+	"strings"
+	"sync"
+
+	"google.golang.org/grpc/codes"
+)
+
+// Lang 语言标签，遵循 BCP-47，如 "zh-CN"、"en-US"
+type Lang string
+
+const (
+	ZhCN Lang = "zh-CN"
+	EnUS Lang = "en-US"
+)
+
+const (
+	minModuleCode ErrorCode = 100000
+	maxModuleCode ErrorCode = 999999
+)
+
+type codeEntry struct {
+	name       string
+	msgs       map[Lang]string
+	grpcCode   codes.Code
+	httpStatus int
+}
+
+// CodeOption 声明错误码对应的标准grpc code和http状态码
+type CodeOption func(e *codeEntry)
+
+// WithGRPCCode 错误码对应的grpc code，未声明时为codes.Unknown
+func WithGRPCCode(c codes.Code) CodeOption {
+	return func(e *codeEntry) {
+		e.grpcCode = c
+	}
+}
+
+// WithHTTPStatus 错误码对应的http状态码，未声明时由grpc code推导
+func WithHTTPStatus(status int) CodeOption {
+	return func(e *codeEntry) {
+		e.httpStatus = status
+	}
+}
+
+var (
+	registryMtx sync.RWMutex
+	registry    = make(map[ErrorCode]*codeEntry)
+	langs       = make(map[Lang]struct{}) //所有注册过的语言
+	defaultLang = ZhCN
+)
 
 func init() {
-	message = make(map[ErrorCode]string)
-	message[OK] = "SUCCESS"
-	message[SERVER_COMMON_ERROR] = "服务器开小差啦, 稍后再来试一试"
-	message[REUQEST_PARAM_ERROR] = "参数错误"
-	message[TOKEN_EXPIRE_ERROR] = "token失效, 请重新登陆"
-	message[TOKEN_GENERATE_ERROR] = "生成token失败"
-	message[DB_ERROR] = "数据库繁忙, 请稍后再试"
+	mustRegister(OK, "OK", map[Lang]string{ZhCN: "SUCCESS", EnUS: "SUCCESS"},
+		WithGRPCCode(codes.OK), WithHTTPStatus(http.StatusOK))
+	mustRegister(SERVER_COMMON_ERROR, "SERVER_COMMON_ERROR", map[Lang]string{
+		ZhCN: "服务器开小差啦, 稍后再来试一试",
+		EnUS: "Server is busy, please try again later",
+	}, WithGRPCCode(codes.Internal))
+	mustRegister(REUQEST_PARAM_ERROR, "REUQEST_PARAM_ERROR", map[Lang]string{
+		ZhCN: "参数错误",
+		EnUS: "Invalid request parameter",
+	}, WithGRPCCode(codes.InvalidArgument))
+	mustRegister(TOKEN_EXPIRE_ERROR, "TOKEN_EXPIRE_ERROR", map[Lang]string{
+		ZhCN: "token失效, 请重新登陆",
+		EnUS: "Token expired, please login again",
+	}, WithGRPCCode(codes.Unauthenticated))
+	mustRegister(TOKEN_GENERATE_ERROR, "TOKEN_GENERATE_ERROR", map[Lang]string{
+		ZhCN: "生成token失败",
+		EnUS: "Failed to generate token",
+	}, WithGRPCCode(codes.Internal))
+	mustRegister(DB_ERROR, "DB_ERROR", map[Lang]string{
+		ZhCN: "数据库繁忙, 请稍后再试",
+		EnUS: "Database is busy, please try again later",
+	}, WithGRPCCode(codes.Unavailable))
+	mustRegister(UNAUTHENTICATED_ERROR, "UNAUTHENTICATED_ERROR", map[Lang]string{
+		ZhCN: "身份认证失败",
+		EnUS: "Authentication failed",
+	}, WithGRPCCode(codes.Unauthenticated))
+	mustRegister(PERMISSION_DENIED_ERROR, "PERMISSION_DENIED_ERROR", map[Lang]string{
+		ZhCN: "没有权限",
+		EnUS: "Permission denied",
+	}, WithGRPCCode(codes.PermissionDenied))
+}
+
+// SetDefaultLang 设置没有指定语言或者指定语言没有翻译时使用的语言
+func SetDefaultLang(lang Lang) {
+	registryMtx.Lock()
+	defer registryMtx.Unlock()
+	defaultLang = lang
+}
+
+// Register 注册业务模块的错误码及其多语言提示，错误码必须为6位(前3位代表业务,后三位代表具体功能)且不能重复注册
+// opts 用于声明错误码对应的标准grpc code和http状态码
+func Register(code ErrorCode, msgs map[Lang]string, opts ...CodeOption) error {
+	return register(code, "", msgs, opts...)
+}
+
+// MustRegister 同Register，注册失败时panic，适合在init中使用
+func MustRegister(code ErrorCode, msgs map[Lang]string, opts ...CodeOption) {
+	mustRegister(code, "", msgs, opts...)
+}
+
+func mustRegister(code ErrorCode, name string, msgs map[Lang]string, opts ...CodeOption) {
+	if err := register(code, name, msgs, opts...); err != nil {
+		panic(err)
+	}
+}
+
+func register(code ErrorCode, name string, msgs map[Lang]string, opts ...CodeOption) error {
+	if code != OK && (code < minModuleCode || code > maxModuleCode) {
+		return fmt.Errorf("errorx: code %d is not a 6-digit code", code)
+	}
+	if len(msgs) == 0 {
+		return fmt.Errorf("errorx: code %d has no message", code)
+	}
+
+	registryMtx.Lock()
+	defer registryMtx.Unlock()
+	if e, ok := registry[code]; ok {
+		return fmt.Errorf("errorx: code %d is already registered as %q", code, e.name)
+	}
+	entry := &codeEntry{name: name, msgs: make(map[Lang]string, len(msgs)), grpcCode: codes.Unknown}
+	for _, o := range opts {
+		o(entry)
+	}
+	if entry.httpStatus == 0 {
+		entry.httpStatus = httpStatusFromGRPCCode(entry.grpcCode)
+	}
+	for lang, msg := range msgs {
+		entry.msgs[lang] = msg
+		langs[lang] = struct{}{}
+	}
+	registry[code] = entry
+	return nil
 }
 
+// MapErrMsg 返回错误码在默认语言下的提示
 func MapErrMsg(errcode ErrorCode) string {
-	if msg, ok := message[errcode]; ok {
+	return MapErrMsgLang(errcode, "")
+}
+
+// MapErrMsgLang 返回错误码在指定语言下的提示
+// 依次匹配: 完整语言标签 -> 相同主语言(如en-GB匹配en-US) -> 默认语言; 未注册的错误码返回SERVER_COMMON_ERROR的提示
+func MapErrMsgLang(errcode ErrorCode, lang Lang) string {
+	registryMtx.RLock()
+	defer registryMtx.RUnlock()
+	if lang == "" {
+		lang = defaultLang
+	}
+	entry, ok := registry[errcode]
+	if !ok {
+		entry = registry[SERVER_COMMON_ERROR]
+	}
+	if msg, ok := entry.msgs[lang]; ok {
 		return msg
-	} else {
-		return "服务器开小差啦, 稍后再来试一试"
 	}
+	for _, l := range sortedLangs(entry.msgs) {
+		if strings.EqualFold(primaryLang(l), primaryLang(lang)) {
+			return entry.msgs[l]
+		}
+	}
+	if msg, ok := entry.msgs[defaultLang]; ok {
+		return msg
+	}
+	return entry.msgs[sortedLangs(entry.msgs)[0]]
+}
+
+// CodeName 返回错误码注册时的名字，未注册或没有名字时返回空
+func CodeName(errcode ErrorCode) string {
+	registryMtx.RLock()
+	defer registryMtx.RUnlock()
+	if e, ok := registry[errcode]; ok {
+		return e.name
+	}
+	return ""
+}
+
+// GRPCCodeOf 返回错误码声明的grpc code
+// 未注册的错误码若本身就是合法的grpc code(0-16)则原样返回，否则为codes.Unknown
+func GRPCCodeOf(errcode ErrorCode) codes.Code {
+	registryMtx.RLock()
+	defer registryMtx.RUnlock()
+	if e, ok := registry[errcode]; ok {
+		return e.grpcCode
+	}
+	if errcode <= ErrorCode(codes.Unauthenticated) {
+		return codes.Code(errcode)
+	}
+	return codes.Unknown
+}
+
+// HTTPStatusOf 返回错误码声明的http状态码
+func HTTPStatusOf(errcode ErrorCode) int {
+	registryMtx.RLock()
+	e, ok := registry[errcode]
+	registryMtx.RUnlock()
+	if ok {
+		return e.httpStatus
+	}
+	return httpStatusFromGRPCCode(GRPCCodeOf(errcode))
 }
 
 func IsCodeErr(errcode ErrorCode) bool {
-	if _, ok := message[errcode]; ok {
-		return true
-	} else {
-		return false
+	registryMtx.RLock()
+	defer registryMtx.RUnlock()
+	_, ok := registry[errcode]
+	return ok
+}
+
+func isSupportedLang(lang Lang) (Lang, bool) {
+	registryMtx.RLock()
+	defer registryMtx.RUnlock()
+	for l := range langs {
+		if strings.EqualFold(string(l), string(lang)) {
+			return l, true
+		}
+	}
+	for _, l := range sortedLangs(langs) {
+		if strings.EqualFold(primaryLang(l), primaryLang(lang)) {
+			return l, true
+		}
+	}
+	return "", false
+}
+
+func primaryLang(lang Lang) string {
+	return strings.SplitN(string(lang), "-", 2)[0]
+}
+
+func sortedLangs[V any](m map[Lang]V) []Lang {
+	res := make([]Lang, 0, len(m))
+	for l := range m {
+		res = append(res, l)
+	}
+	sort.Slice(res, func(i, j int) bool { return res[i] < res[j] })
+	return res
+}
+
+// httpStatusFromGRPCCode 与grpc-gateway的映射保持一致
+func httpStatusFromGRPCCode(c codes.Code) int {
+	switch c {
+	case codes.OK:
+		return http.StatusOK
+	case codes.Canceled:
+		return 499
+	case codes.InvalidArgument, codes.FailedPrecondition, codes.OutOfRange:
+		return http.StatusBadRequest
+	case codes.DeadlineExceeded:
+		return http.StatusGatewayTimeout
+	case codes.NotFound:
+		return http.StatusNotFound
+	case codes.AlreadyExists, codes.Aborted:
+		return http.StatusConflict
+	case codes.PermissionDenied:
+		return http.StatusForbidden
+	case codes.Unauthenticated:
+		return http.StatusUnauthorized
+	case codes.ResourceExhausted:
+		return http.StatusTooManyRequests
+	case codes.Unimplemented:
+		return http.StatusNotImplemented
+	case codes.Unavailable:
+		return http.StatusServiceUnavailable
+	default:
+		return http.StatusInternalServerError
+	}
+}
+
+// parseGRPCCode 支持 "InvalidArgument"、"INVALID_ARGUMENT" 两种写法
+func parseGRPCCode(s string) (codes.Code, error) {
+	norm := strings.ToLower(strings.ReplaceAll(s, "_", ""))
+	for c := codes.OK; c <= codes.Unauthenticated; c++ {
+		if strings.ToLower(c.String()) == norm {
+			return c, nil
+		}
 	}
+	return codes.Unknown, fmt.Errorf("errorx: unknown grpc code %q", s)
 }
@@ -0,0 +1,101 @@
+package errorx
+
+import (
+	"bytes"
+	"context"
+	"io"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"singer.com/basic/meta"
+)
+
+func TestRegister(t *testing.T) {
+	assert.Nil(t, Register(300001, map[Lang]string{ZhCN: "订单不存在", EnUS: "Order not found"}))
+	assert.NotNil(t, Register(300001, map[Lang]string{ZhCN: "重复"}))
+	assert.NotNil(t, Register(3001, map[Lang]string{ZhCN: "不是6位"}))
+	assert.NotNil(t, Register(300002, nil))
+
+	assert.True(t, IsCodeErr(300001))
+	assert.Equal(t, "订单不存在", MapErrMsg(300001))
+	assert.Equal(t, "Order not found", MapErrMsgLang(300001, EnUS))
+	assert.Equal(t, "Order not found", MapErrMsgLang(300001, "en-GB"))
+	assert.Equal(t, "订单不存在", MapErrMsgLang(300001, "fr-FR"))
+	assert.Equal(t, MapErrMsg(SERVER_COMMON_ERROR), MapErrMsg(399999))
+}
+
+func TestLangFromContext(t *testing.T) {
+	assert.Equal(t, Lang(""), LangFromContext(context.Background()))
+
+	ctx := metadata.NewIncomingContext(context.Background(), metadata.Pairs("accept-language", "fr-FR,en;q=0.8,zh-CN;q=0.5"))
+	assert.Equal(t, EnUS, LangFromContext(ctx))
+
+	ctx = metadata.NewIncomingContext(context.Background(), metadata.Pairs("accept-language", "zh-CN"))
+	assert.Equal(t, ZhCN, LangFromContext(ctx))
+
+	e := NewErrCode(DB_ERROR)
+	st := e.GRPCStatusWithLang(EnUS)
+	assert.Equal(t, "Database is busy, please try again later", st.Message())
+	assert.Len(t, st.Details(), 2) //LocalizedMessage + ErrorInfo
+}
+
+func TestLangPropagation(t *testing.T) {
+	for _, key := range []string{"accept-language", "grpcgateway-accept-language"} {
+		ctx := metadata.NewIncomingContext(context.Background(), metadata.Pairs(key, "en-US"))
+		// 客户端拦截器传递给下游，下游收到的incoming metadata
+		out, _ := metadata.FromOutgoingContext(meta.OutgoingContext(ctx))
+		downstream := metadata.NewIncomingContext(context.Background(), out)
+		assert.Equal(t, EnUS, LangFromContext(downstream), key)
+	}
+}
+
+const testCatalog = `
+codes:
+  - code: 400002
+    name: STOCK_NOT_ENOUGH
+    messages:
+      zh-CN: 库存不足
+      en-US: Out of stock
+  - code: 400001
+    name: GOODS_NOT_FOUND
+    grpc_code: NOT_FOUND
+    messages:
+      zh-CN: 商品不存在
+      en-US: Goods not found
+`
+
+func TestCatalog(t *testing.T) {
+	c, err := ParseCatalog([]byte(testCatalog), "yaml")
+	assert.Nil(t, err)
+	assert.Len(t, c.Codes, 2)
+
+	_, err = ParseCatalog([]byte(`{"codes":[{"code":400003,"name":"A"},{"code":400003,"name":"B"}]}`), "json")
+	assert.NotNil(t, err)
+
+	assert.Nil(t, RegisterCatalog(c))
+	assert.Equal(t, "STOCK_NOT_ENOUGH", CodeName(400002))
+	assert.Equal(t, "Goods not found", MapErrMsgLang(400001, EnUS))
+	assert.Equal(t, codes.NotFound, GRPCCodeOf(400001))
+	assert.Equal(t, codes.Unknown, GRPCCodeOf(400002))
+
+	var goSrc bytes.Buffer
+	assert.Nil(t, GenerateGo(&goSrc, "goods", c))
+	assert.Contains(t, goSrc.String(), "errorx.ErrorCode = 400001 // 商品不存在")
+	assert.Contains(t, goSrc.String(), `"en-US": "Out of stock",`)
+	assert.Contains(t, goSrc.String(), `GRPCCode: "NOT_FOUND"`)
+
+	// 生成代码时可以并发修改默认语言
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		SetDefaultLang(ZhCN)
+	}()
+	assert.Nil(t, GenerateGo(io.Discard, "goods", c))
+	<-done
+
+	var md bytes.Buffer
+	assert.Nil(t, GenerateMarkdown(&md, c))
+	assert.Contains(t, md.String(), "| 400001 | GOODS_NOT_FOUND | NotFound | 404 | Goods not found | 商品不存在 |")
+}
@@ -3,10 +3,16 @@ package errorx
 import (
 	"context"
 	"fmt"
+	"strconv"
+	"sync/atomic"
 
+	"github.com/golang/protobuf/proto"
 	"github.com/pkg/errors"
+	"google.golang.org/genproto/googleapis/rpc/errdetails"
 	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
 	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
 )
 
 /**
@@ -25,17 +31,40 @@ import (
 目前的设计：
 	1、proto定义中不包含错误
 	2、grpc 接口通过errorx.Wrapf 返回错误，通过grpc拦截器自动打印错误日志，并将错误码对应的脱敏错误返回给前端
+	3、需要结构化信息时通过WithDetails附加google.rpc错误详情(BadRequest、RetryInfo、ErrorInfo、LocalizedMessage)
+	4、调用方通过errorx.FromError将收到的grpc status还原为CodeError
+	5、grpc status的code使用错误码声明的标准grpc code，业务错误码放在ErrorInfo详情的metadata中
 */
 
 type CodeError struct {
-	errCode ErrorCode //错误码
-	errMsg  string    //内部错误信息
+	errCode  ErrorCode       //错误码
+	errMsg   string          //内部错误信息
+	usrMsg   string          //对端返回的脱敏信息，仅由FromError还原时设置
+	details  []proto.Message //错误详情
+	remote   bool            //是否由对端返回的grpc status还原
+	grpcCode codes.Code      //对端返回的grpc code，仅remote为true时有效
 }
 
 const (
 	kErrorxTrailerKey = "errorx-message"
+	//ErrorInfo.Metadata 中业务错误码的key
+	kErrorInfoCodeKey = "code"
 )
 
+var errorDomain atomic.Value
+
+// SetDomain 设置ErrorInfo详情中的domain，一般为服务名
+func SetDomain(domain string) {
+	errorDomain.Store(domain)
+}
+
+func getDomain() string {
+	if d, ok := errorDomain.Load().(string); ok && d != "" {
+		return d
+	}
+	return "errorx"
+}
+
 //返回给前端的错误码
 func (e *CodeError) GetErrCode() ErrorCode {
 	return e.errCode
@@ -43,9 +72,28 @@ func (e *CodeError) GetErrCode() ErrorCode {
 
 //返回给前端的错误信息
 func (e *CodeError) GetUsrMsg() string {
+	if e.usrMsg != "" {
+		return e.usrMsg
+	}
 	return MapErrMsg(e.errCode)
 }
 
+// GRPCCode 错误码对应的标准grpc code
+func (e *CodeError) GRPCCode() codes.Code {
+	if e.remote {
+		return e.grpcCode
+	}
+	return GRPCCodeOf(e.errCode)
+}
+
+// HTTPStatus 错误码对应的http状态码
+func (e *CodeError) HTTPStatus() int {
+	if e.remote && !IsCodeErr(e.errCode) {
+		return httpStatusFromGRPCCode(e.grpcCode)
+	}
+	return HTTPStatusOf(e.errCode)
+}
+
 func (e *CodeError) Error() string {
 	return fmt.Sprintf("ErrCode:%d, ErrMsg:%s", e.errCode, e.errMsg)
 }
@@ -54,6 +102,108 @@ func (e *CodeError) SetTrailer(ctx context.Context) {
 	grpc.SetTrailer(ctx, metadata.Pairs(kErrorxTrailerKey, e.errMsg))
 }
 
+// GRPCStatus 转换为携带错误详情的grpc status，status.FromError/status.Code可以直接识别
+func (e *CodeError) GRPCStatus() *status.Status {
+	return e.newStatus(e.GetUsrMsg(), e.details)
+}
+
+// GRPCStatusWithLang 同GRPCStatus，但脱敏信息使用lang对应的语言，并附加LocalizedMessage详情
+func (e *CodeError) GRPCStatusWithLang(lang Lang) *status.Status {
+	msg := e.usrMsg
+	if msg == "" {
+		msg = MapErrMsgLang(e.errCode, lang)
+	}
+	details := e.details
+	if e.LocalizedMessage() == nil && lang != "" {
+		details = append(details[:len(details):len(details)], LocalizedMessage(string(lang), msg))
+	}
+	return e.newStatus(msg, details)
+}
+
+func (e *CodeError) newStatus(msg string, details []proto.Message) *status.Status {
+	st := status.New(e.GRPCCode(), msg)
+	details = e.withErrorInfo(details)
+	ds, err := st.WithDetails(details...)
+	if err != nil {
+		return st
+	}
+	return ds
+}
+
+// withErrorInfo 保证详情中有携带业务错误码的ErrorInfo
+func (e *CodeError) withErrorInfo(details []proto.Message) []proto.Message {
+	code := strconv.FormatUint(uint64(e.errCode), 10)
+	for i, d := range details {
+		info, ok := d.(*errdetails.ErrorInfo)
+		if !ok {
+			continue
+		}
+		// details可能是共享的(如包级别的错误)，修改副本避免并发写map
+		info = proto.Clone(info).(*errdetails.ErrorInfo)
+		if info.Metadata == nil {
+			info.Metadata = make(map[string]string)
+		}
+		info.Metadata[kErrorInfoCodeKey] = code
+		cloned := make([]proto.Message, len(details))
+		copy(cloned, details)
+		cloned[i] = info
+		return cloned
+	}
+	reason := CodeName(e.errCode)
+	if reason == "" {
+		reason = "ERROR_" + code
+	}
+	info := ErrorInfo(getDomain(), reason, map[string]string{kErrorInfoCodeKey: code})
+	return append(details[:len(details):len(details)], info)
+}
+
+// FromError 将err还原为CodeError，调用方可据此按错误码做分支处理
+// 本地的CodeError直接返回; grpc status错误则使用其code、message和details重建; 其他错误返回false
+func FromError(err error) (*CodeError, bool) {
+	if err == nil {
+		return nil, false
+	}
+	if e, ok := Cause(err).(*CodeError); ok {
+		return e, true
+	}
+	st, ok := status.FromError(err)
+	if !ok {
+		return nil, false
+	}
+	ce := &CodeError{errCode: ErrorCode(st.Code()), usrMsg: st.Message(), remote: true, grpcCode: st.Code()}
+	for _, d := range st.Details() {
+		if m, ok := d.(proto.Message); ok {
+			ce.details = append(ce.details, m)
+		}
+	}
+	if info := ce.ErrorInfo(); info != nil {
+		if code, err := strconv.ParseUint(info.GetMetadata()[kErrorInfoCodeKey], 10, 32); err == nil {
+			ce.errCode = ErrorCode(code)
+		}
+	}
+	return ce, true
+}
+
+// CodeFromError 返回err携带的业务错误码
+// 支持本地CodeError、ErrorInfo中携带业务错误码的grpc status以及直接以错误码作为grpc code的旧格式
+func CodeFromError(err error) ErrorCode {
+	if err == nil {
+		return OK
+	}
+	if ce, ok := FromError(err); ok {
+		return ce.GetErrCode()
+	}
+	return ErrorCode(codes.Unknown)
+}
+
+// IsCode 判断err是否为指定错误码，code既可以是业务错误码，也可以是grpc code
+func IsCode(err error, code ErrorCode) bool {
+	if err == nil {
+		return code == OK
+	}
+	return ErrorCode(status.Code(err)) == code || CodeFromError(err) == code
+}
+
 func NewErrCodeMsg(errCode ErrorCode, errMsg string) *CodeError {
 	return &CodeError{errCode: errCode, errMsg: errMsg}
 }
@@ -0,0 +1,102 @@
+package errorx
+
+import (
+	"net/http"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/pkg/errors"
+	"github.com/stretchr/testify/assert"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+func TestGRPCStatusWithDetails(t *testing.T) {
+	e := NewErrCodeMsg(REUQEST_PARAM_ERROR, "name is empty").WithDetails(
+		BadRequest(FieldViolation("name", "must not be empty")),
+		RetryInfo(time.Second),
+		ErrorInfo("demo", "INVALID_NAME", map[string]string{"field": "name"}),
+		LocalizedMessage("zh-CN", "名字不能为空"),
+	)
+
+	st := e.GRPCStatus()
+	assert.Equal(t, codes.InvalidArgument, st.Code())
+	assert.Equal(t, e.GetUsrMsg(), st.Message())
+	assert.Len(t, st.Details(), 4)
+}
+
+func TestGRPCStatusSharedError(t *testing.T) {
+	info := ErrorInfo("demo", "INVALID_NAME", map[string]string{"field": "name"})
+	shared := NewErrCodeMsg(REUQEST_PARAM_ERROR, "name is empty").WithDetails(info)
+
+	var wg sync.WaitGroup
+	for i := 0; i < 8; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for j := 0; j < 100; j++ {
+				status.FromError(shared)
+				shared.GRPCStatusWithLang(EnUS)
+			}
+		}()
+	}
+	wg.Wait()
+
+	// 调用方的ErrorInfo不被修改
+	assert.Equal(t, map[string]string{"field": "name"}, info.Metadata)
+	ce, ok := FromError(shared.GRPCStatus().Err())
+	assert.True(t, ok)
+	assert.Equal(t, REUQEST_PARAM_ERROR, ce.GetErrCode())
+	assert.Equal(t, "name", ce.ErrorInfo().GetMetadata()["field"])
+}
+
+func TestFromError(t *testing.T) {
+	err := WithDetails(Wrap(TOKEN_EXPIRE_ERROR, "token expired at 10:00"),
+		ErrorInfo("auth", "TOKEN_EXPIRED", nil))
+
+	local, ok := FromError(err)
+	assert.True(t, ok)
+	assert.Equal(t, TOKEN_EXPIRE_ERROR, local.GetErrCode())
+
+	//模拟经过grpc传输后的错误
+	remote, ok := FromError(local.GRPCStatus().Err())
+	assert.True(t, ok)
+	assert.Equal(t, TOKEN_EXPIRE_ERROR, remote.GetErrCode())
+	assert.Equal(t, local.GetUsrMsg(), remote.GetUsrMsg())
+	assert.Equal(t, "TOKEN_EXPIRED", remote.ErrorInfo().GetReason())
+	assert.Nil(t, remote.BadRequest())
+
+	_, ok = FromError(errors.New("plain error"))
+	assert.False(t, ok)
+
+	ce, ok := FromError(status.Error(codes.NotFound, "not found"))
+	assert.True(t, ok)
+	assert.EqualValues(t, codes.NotFound, ce.GetErrCode())
+	assert.Equal(t, "not found", ce.GetUsrMsg())
+	assert.Equal(t, http.StatusNotFound, ce.HTTPStatus())
+}
+
+func TestCodeMapping(t *testing.T) {
+	e := NewErrCode(TOKEN_EXPIRE_ERROR)
+	assert.Equal(t, codes.Unauthenticated, e.GRPCCode())
+	assert.Equal(t, http.StatusUnauthorized, e.HTTPStatus())
+
+	err := e.GRPCStatus().Err()
+	assert.Equal(t, codes.Unauthenticated, status.Code(err))
+	assert.Equal(t, TOKEN_EXPIRE_ERROR, CodeFromError(err))
+	assert.True(t, IsCode(err, TOKEN_EXPIRE_ERROR))
+	assert.True(t, IsCode(err, ErrorCode(codes.Unauthenticated)))
+	assert.False(t, IsCode(err, DB_ERROR))
+
+	//旧格式: 直接以业务错误码作为grpc code
+	legacy := status.Error(codes.Code(DB_ERROR), "db error")
+	assert.Equal(t, DB_ERROR, CodeFromError(legacy))
+	assert.True(t, IsCode(legacy, DB_ERROR))
+
+	assert.Nil(t, Register(600001, map[Lang]string{ZhCN: "余额不足"}, WithGRPCCode(codes.FailedPrecondition), WithHTTPStatus(http.StatusPaymentRequired)))
+	assert.Equal(t, codes.FailedPrecondition, GRPCCodeOf(600001))
+	assert.Equal(t, http.StatusPaymentRequired, HTTPStatusOf(600001))
+	assert.Equal(t, codes.Unknown, GRPCCodeOf(699999))
+	assert.Equal(t, http.StatusInternalServerError, HTTPStatusOf(699999))
+}
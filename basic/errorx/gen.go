@@ -0,0 +1,140 @@
+package errorx
+
+import (
+	"bytes"
+	"fmt"
+	"go/format"
+	"io"
+	"sort"
+	"strings"
+	"text/template"
+
+	"google.golang.org/grpc/codes"
+)
+
+var goTemplate = template.Must(template.New("go").Funcs(template.FuncMap{
+	"langs": sortedLangs[string],
+}).Parse(`// Code generated by errorxgen. DO NOT EDIT.
+
+package {{.Package}}
+
+import "singer.com/basic/errorx"
+
+const (
+{{- range .Codes}}
+	{{.Name}} errorx.ErrorCode = {{.Code}}{{with .Comment}} // {{.}}{{end}}
+{{- end}}
+)
+
+func init() {
+	errorx.MustRegisterCatalog(&errorx.Catalog{Codes: []errorx.CodeDef{
+{{- range .Codes}}
+		{Code: {{.Name}}, Name: "{{.Name}}",{{with .GRPCCode}} GRPCCode: "{{.}}",{{end}}{{with .HTTPStatus}} HTTPStatus: {{.}},{{end}} Messages: map[errorx.Lang]string{
+		{{- $msgs := .Messages}}{{range langs .Messages}}
+			{{printf "%q" .}}: {{printf "%q" (index $msgs .)}},
+		{{- end}}
+		}},
+{{- end}}
+	}})
+}
+`))
+
+type goCodeDef struct {
+	Code       ErrorCode
+	Name       string
+	Comment    string
+	Messages   map[Lang]string
+	GRPCCode   string
+	HTTPStatus int
+}
+
+// GenerateGo 根据目录生成错误码常量文件，生成的文件在init中完成注册
+func GenerateGo(w io.Writer, pkg string, c *Catalog) error {
+	if err := c.validate(); err != nil {
+		return err
+	}
+	defs := make([]goCodeDef, 0, len(c.Codes))
+	for _, d := range sortedCodes(c) {
+		defs = append(defs, goCodeDef{
+			Code:       d.Code,
+			Name:       d.Name,
+			Comment:    strings.Join(strings.Fields(pickMessage(d.Messages)), " "),
+			Messages:   d.Messages,
+			GRPCCode:   d.GRPCCode,
+			HTTPStatus: d.HTTPStatus,
+		})
+	}
+	var buf bytes.Buffer
+	err := goTemplate.Execute(&buf, struct {
+		Package string
+		Codes   []goCodeDef
+	}{Package: pkg, Codes: defs})
+	if err != nil {
+		return err
+	}
+	src, err := format.Source(buf.Bytes())
+	if err != nil {
+		return err
+	}
+	_, err = w.Write(src)
+	return err
+}
+
+// GenerateMarkdown 根据目录生成错误码表格，每种语言一列
+func GenerateMarkdown(w io.Writer, c *Catalog) error {
+	if err := c.validate(); err != nil {
+		return err
+	}
+	all := make(map[Lang]struct{})
+	for _, d := range c.Codes {
+		for l := range d.Messages {
+			all[l] = struct{}{}
+		}
+	}
+	cols := sortedLangs(all)
+
+	var buf bytes.Buffer
+	buf.WriteString("| 错误码 | 名称 | grpc code | http status |")
+	for _, l := range cols {
+		fmt.Fprintf(&buf, " %s |", l)
+	}
+	buf.WriteString("\n| --- | --- | --- | --- |")
+	buf.WriteString(strings.Repeat(" --- |", len(cols)))
+	buf.WriteString("\n")
+	for _, d := range sortedCodes(c) {
+		grpcCode := codes.Unknown
+		httpStatus := d.HTTPStatus
+		if d.GRPCCode != "" {
+			grpcCode, _ = parseGRPCCode(d.GRPCCode)
+		}
+		if httpStatus == 0 {
+			httpStatus = httpStatusFromGRPCCode(grpcCode)
+		}
+		fmt.Fprintf(&buf, "| %d | %s | %s | %d |", d.Code, d.Name, grpcCode, httpStatus)
+		for _, l := range cols {
+			fmt.Fprintf(&buf, " %s |", strings.ReplaceAll(d.Messages[l], "|", "\\|"))
+		}
+		buf.WriteString("\n")
+	}
+	_, err := w.Write(buf.Bytes())
+	return err
+}
+
+func sortedCodes(c *Catalog) []CodeDef {
+	defs := append([]CodeDef(nil), c.Codes...)
+	sort.Slice(defs, func(i, j int) bool { return defs[i].Code < defs[j].Code })
+	return defs
+}
+
+func pickMessage(msgs map[Lang]string) string {
+	registryMtx.RLock()
+	lang := defaultLang
+	registryMtx.RUnlock()
+	if msg, ok := msgs[lang]; ok {
+		return msg
+	}
+	if len(msgs) == 0 {
+		return ""
+	}
+	return msgs[sortedLangs(msgs)[0]]
+}
@@ -0,0 +1,67 @@
+package errorx
+
+import (
+	"context"
+	"sort"
+	"strconv"
+	"strings"
+
+	"google.golang.org/grpc/metadata"
+)
+
+const (
+	kAcceptLanguageKey        = "accept-language"
+	kGatewayAcceptLanguageKey = "grpcgateway-accept-language" //经过grpc-gateway转发的http头
+)
+
+// LangFromContext 根据请求metadata中的accept-language选择已注册的语言，没有匹配时返回空(即使用默认语言)
+func LangFromContext(ctx context.Context) Lang {
+	md, ok := metadata.FromIncomingContext(ctx)
+	if !ok {
+		return ""
+	}
+	values := append(md.Get(kAcceptLanguageKey), md.Get(kGatewayAcceptLanguageKey)...)
+	for _, v := range values {
+		for _, lang := range parseAcceptLanguage(v) {
+			if l, ok := isSupportedLang(lang); ok {
+				return l
+			}
+		}
+	}
+	return ""
+}
+
+// parseAcceptLanguage 解析 "zh-CN,zh;q=0.9,en;q=0.8"，按权重从高到低返回
+func parseAcceptLanguage(header string) []Lang {
+	type weighted struct {
+		lang Lang
+		q    float64
+	}
+	items := make([]weighted, 0)
+	for _, part := range strings.Split(header, ",") {
+		fields := strings.Split(strings.TrimSpace(part), ";")
+		tag := strings.TrimSpace(fields[0])
+		if tag == "" || tag == "*" {
+			continue
+		}
+		q := 1.0
+		for _, f := range fields[1:] {
+			f = strings.TrimSpace(f)
+			if strings.HasPrefix(f, "q=") {
+				if v, err := strconv.ParseFloat(f[2:], 64); err == nil {
+					q = v
+				}
+			}
+		}
+		if q <= 0 {
+			continue
+		}
+		items = append(items, weighted{lang: Lang(tag), q: q})
+	}
+	sort.SliceStable(items, func(i, j int) bool { return items[i].q > items[j].q })
+	res := make([]Lang, len(items))
+	for i, it := range items {
+		res[i] = it.lang
+	}
+	return res
+}
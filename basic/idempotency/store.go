@@ -0,0 +1,239 @@
+package idempotency
+
+import (
+	"context"
+	"encoding/json"
+	"sync"
+	"time"
+
+	"github.com/go-redis/redis/v8"
+	spb "google.golang.org/genproto/googleapis/rpc/status"
+	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/types/known/anypb"
+	redisx "singer.com/basic/redis"
+)
+
+const (
+	defaultTTL     = 24 * time.Hour
+	defaultLockTTL = 30 * time.Second
+)
+
+type Config struct {
+	Store Store
+	// 结果的保存时间，默认24h
+	TTL time.Duration
+	// 处理请求时持有锁的时间，应大于请求的最长处理时间，默认30s
+	LockTTL time.Duration
+}
+
+// WithDefaults 返回填充了默认值的配置
+func (c Config) WithDefaults() Config {
+	if c.TTL <= 0 {
+		c.TTL = defaultTTL
+	}
+	if c.LockTTL <= 0 {
+		c.LockTTL = defaultLockTTL
+	}
+	return c
+}
+
+// Record 请求第一次处理的结果，Response、Code和Status只有一个不为空
+type Record struct {
+	Response *anypb.Any
+	// errorx错误的业务错误码和错误详情，重放时按请求的语言重新生成脱敏信息
+	Code    uint32
+	Details []*anypb.Any
+	// 其他错误的grpc status
+	Status *spb.Status
+	// 第一次请求内容的hash，用于拒绝使用相同幂等键但内容不同的请求
+	RequestHash []byte
+}
+
+type encodedRecord struct {
+	Response    []byte   `json:"response,omitempty"`
+	Code        uint32   `json:"code,omitempty"`
+	Details     [][]byte `json:"details,omitempty"`
+	Status      []byte   `json:"status,omitempty"`
+	RequestHash []byte   `json:"request_hash,omitempty"`
+}
+
+func (r *Record) Marshal() ([]byte, error) {
+	e := encodedRecord{Code: r.Code, RequestHash: r.RequestHash}
+	var err error
+	if r.Response != nil {
+		if e.Response, err = proto.Marshal(r.Response); err != nil {
+			return nil, err
+		}
+	}
+	for _, d := range r.Details {
+		data, err := proto.Marshal(d)
+		if err != nil {
+			return nil, err
+		}
+		e.Details = append(e.Details, data)
+	}
+	if r.Status != nil {
+		if e.Status, err = proto.Marshal(r.Status); err != nil {
+			return nil, err
+		}
+	}
+	return json.Marshal(e)
+}
+
+func UnmarshalRecord(data []byte) (*Record, error) {
+	var e encodedRecord
+	if err := json.Unmarshal(data, &e); err != nil {
+		return nil, err
+	}
+	r := &Record{Code: e.Code, RequestHash: e.RequestHash}
+	for _, data := range e.Details {
+		d := &anypb.Any{}
+		if err := proto.Unmarshal(data, d); err != nil {
+			return nil, err
+		}
+		r.Details = append(r.Details, d)
+	}
+	if e.Response != nil {
+		r.Response = &anypb.Any{}
+		if err := proto.Unmarshal(e.Response, r.Response); err != nil {
+			return nil, err
+		}
+	}
+	if e.Status != nil {
+		r.Status = &spb.Status{}
+		if err := proto.Unmarshal(e.Status, r.Status); err != nil {
+			return nil, err
+		}
+	}
+	return r, nil
+}
+
+// Store 保存请求的处理结果，key由方法名、调用方身份和幂等键组成
+type Store interface {
+	// Get 返回已保存的结果，不存在时返回nil
+	Get(ctx context.Context, key string) (*Record, error)
+	Save(ctx context.Context, key string, r *Record, ttl time.Duration) error
+	// Lock 获取处理请求的锁，锁被其他请求持有时返回false，ttl应大于请求的最长处理时间
+	Lock(ctx context.Context, key string, ttl time.Duration) (unlock func(), ok bool, err error)
+}
+
+type redisStore struct {
+	client *redis.Client
+	prefix string
+}
+
+// NewRedisStore 使用redis保存结果，多个实例共享，使用RedisLock阻止并发的重复请求
+func NewRedisStore(client *redis.Client, prefix string) Store {
+	return &redisStore{client: client, prefix: prefix}
+}
+
+func (s *redisStore) Get(ctx context.Context, key string) (*Record, error) {
+	data, err := s.client.Get(ctx, s.prefix+key).Bytes()
+	if err == redis.Nil {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	return UnmarshalRecord(data)
+}
+
+func (s *redisStore) Save(ctx context.Context, key string, r *Record, ttl time.Duration) error {
+	data, err := r.Marshal()
+	if err != nil {
+		return err
+	}
+	return s.client.Set(ctx, s.prefix+key, data, ttl).Err()
+}
+
+func (s *redisStore) Lock(ctx context.Context, key string, ttl time.Duration) (func(), bool, error) {
+	lock := redisx.NewRedisLock(s.client, s.prefix+key+":lock", ttl)
+	ok, err := lock.LockCtx(ctx)
+	if err != nil || !ok {
+		return nil, ok, err
+	}
+	// 请求的ctx可能已经取消，解锁使用新的ctx
+	return func() { lock.UnlockCtx(context.Background()) }, true, nil
+}
+
+type localEntry struct {
+	data     []byte
+	expireAt time.Time
+}
+
+type localStore struct {
+	mtx       sync.Mutex
+	records   map[string]localEntry
+	locks     map[string]time.Time
+	lastSweep time.Time
+	now       func() time.Time
+}
+
+// NewLocalStore 在内存中保存结果，只对单实例有效，适用于单实例部署和测试
+func NewLocalStore() Store {
+	return &localStore{
+		records: make(map[string]localEntry),
+		locks:   make(map[string]time.Time),
+		now:     time.Now,
+	}
+}
+
+func (s *localStore) Get(ctx context.Context, key string) (*Record, error) {
+	s.mtx.Lock()
+	e, ok := s.records[key]
+	s.mtx.Unlock()
+	if !ok || !s.now().Before(e.expireAt) {
+		return nil, nil
+	}
+	return UnmarshalRecord(e.data)
+}
+
+func (s *localStore) Save(ctx context.Context, key string, r *Record, ttl time.Duration) error {
+	data, err := r.Marshal()
+	if err != nil {
+		return err
+	}
+	s.mtx.Lock()
+	defer s.mtx.Unlock()
+	now := s.now()
+	s.sweep(now)
+	s.records[key] = localEntry{data: data, expireAt: now.Add(ttl)}
+	return nil
+}
+
+func (s *localStore) Lock(ctx context.Context, key string, ttl time.Duration) (func(), bool, error) {
+	s.mtx.Lock()
+	defer s.mtx.Unlock()
+	now := s.now()
+	if expireAt, ok := s.locks[key]; ok && now.Before(expireAt) {
+		return nil, false, nil
+	}
+	expireAt := now.Add(ttl)
+	s.locks[key] = expireAt
+	return func() {
+		s.mtx.Lock()
+		defer s.mtx.Unlock()
+		// 锁过期后可能已被其他请求持有
+		if s.locks[key].Equal(expireAt) {
+			delete(s.locks, key)
+		}
+	}, true, nil
+}
+
+// sweep 每分钟最多清理一次过期的结果和锁
+func (s *localStore) sweep(now time.Time) {
+	if now.Sub(s.lastSweep) < time.Minute {
+		return
+	}
+	s.lastSweep = now
+	for k, e := range s.records {
+		if !now.Before(e.expireAt) {
+			delete(s.records, k)
+		}
+	}
+	for k, expireAt := range s.locks {
+		if !now.Before(expireAt) {
+			delete(s.locks, k)
+		}
+	}
+}
@@ -0,0 +1,80 @@
+package idempotency
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/alicebob/miniredis/v2"
+	"github.com/go-redis/redis/v8"
+	"github.com/stretchr/testify/assert"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/types/known/anypb"
+	"google.golang.org/protobuf/types/known/wrapperspb"
+)
+
+func testStore(t *testing.T, s Store, expire func(time.Duration)) {
+	ctx := context.Background()
+	r, err := s.Get(ctx, "/api.Order/Create:k1")
+	assert.Nil(t, err)
+	assert.Nil(t, r)
+
+	resp, _ := anypb.New(wrapperspb.String("order-1"))
+	assert.Nil(t, s.Save(ctx, "/api.Order/Create:k1", &Record{Response: resp, RequestHash: []byte{1, 2, 3}}, time.Minute))
+	assert.Nil(t, s.Save(ctx, "/api.Order/Create:k2", &Record{Status: status.New(codes.NotFound, "not found").Proto()}, time.Minute))
+	detail, _ := anypb.New(wrapperspb.String("detail"))
+	assert.Nil(t, s.Save(ctx, "/api.Order/Create:k4", &Record{Code: 100001, Details: []*anypb.Any{detail}}, time.Minute))
+
+	r, err = s.Get(ctx, "/api.Order/Create:k1")
+	assert.Nil(t, err)
+	msg, err := r.Response.UnmarshalNew()
+	assert.Nil(t, err)
+	assert.True(t, proto.Equal(wrapperspb.String("order-1"), msg))
+	assert.Equal(t, []byte{1, 2, 3}, r.RequestHash)
+
+	r, err = s.Get(ctx, "/api.Order/Create:k2")
+	assert.Nil(t, err)
+	assert.Equal(t, codes.NotFound, status.FromProto(r.Status).Code())
+
+	r, err = s.Get(ctx, "/api.Order/Create:k4")
+	assert.Nil(t, err)
+	assert.Equal(t, uint32(100001), r.Code)
+	assert.Len(t, r.Details, 1)
+	assert.True(t, proto.Equal(detail, r.Details[0]))
+
+	unlock, ok, err := s.Lock(ctx, "/api.Order/Create:k3", 10*time.Second)
+	assert.Nil(t, err)
+	assert.True(t, ok)
+	_, ok, _ = s.Lock(ctx, "/api.Order/Create:k3", 10*time.Second)
+	assert.False(t, ok)
+	unlock()
+	unlock2, ok, _ := s.Lock(ctx, "/api.Order/Create:k3", 10*time.Second)
+	assert.True(t, ok)
+
+	// 锁和结果过期
+	expire(2 * time.Minute)
+	_, ok, _ = s.Lock(ctx, "/api.Order/Create:k3", 10*time.Second)
+	assert.True(t, ok)
+	unlock2() // 过期的锁解锁时不影响新的持有者
+	_, ok, _ = s.Lock(ctx, "/api.Order/Create:k3", 10*time.Second)
+	assert.False(t, ok)
+	r, err = s.Get(ctx, "/api.Order/Create:k1")
+	assert.Nil(t, err)
+	assert.Nil(t, r)
+}
+
+func TestLocalStore(t *testing.T) {
+	now := time.Now()
+	s := NewLocalStore().(*localStore)
+	s.now = func() time.Time { return now }
+	testStore(t, s, func(d time.Duration) { now = now.Add(d) })
+}
+
+func TestRedisStore(t *testing.T) {
+	mr := miniredis.RunT(t)
+	client := redis.NewClient(&redis.Options{Addr: mr.Addr()})
+	testStore(t, NewRedisStore(client, "idem:"), mr.FastForward)
+	assert.True(t, mr.Exists("idem:/api.Order/Create:k3:lock"))
+}
@@ -0,0 +1,78 @@
+package log
+
+import (
+	"io"
+	"sync"
+	"sync/atomic"
+)
+
+const defaultAsyncQueueSize = 8192
+
+// AsyncWriter 使用有界队列异步写入，队列满时丢弃日志而不是阻塞业务
+type AsyncWriter struct {
+	w       io.Writer
+	queue   chan []byte
+	done    chan struct{}
+	dropped uint64
+
+	mtx    sync.RWMutex
+	closed bool
+}
+
+func NewAsyncWriter(w io.Writer, queueSize int) *AsyncWriter {
+	if queueSize <= 0 {
+		queueSize = defaultAsyncQueueSize
+	}
+	aw := &AsyncWriter{
+		w:     w,
+		queue: make(chan []byte, queueSize),
+		done:  make(chan struct{}),
+	}
+	go aw.run()
+	return aw
+}
+
+func (aw *AsyncWriter) run() {
+	defer close(aw.done)
+	for p := range aw.queue {
+		aw.w.Write(p)
+	}
+}
+
+// Write 复制p后放入队列，总是返回len(p)
+func (aw *AsyncWriter) Write(p []byte) (int, error) {
+	aw.mtx.RLock()
+	defer aw.mtx.RUnlock()
+	if aw.closed {
+		atomic.AddUint64(&aw.dropped, 1)
+		return len(p), nil
+	}
+	buf := make([]byte, len(p))
+	copy(buf, p)
+	select {
+	case aw.queue <- buf:
+	default:
+		atomic.AddUint64(&aw.dropped, 1)
+	}
+	return len(p), nil
+}
+
+// Dropped 返回丢弃的日志条数
+func (aw *AsyncWriter) Dropped() uint64 {
+	return atomic.LoadUint64(&aw.dropped)
+}
+
+// Close 写完队列中剩余的日志后关闭下层writer
+func (aw *AsyncWriter) Close() error {
+	aw.mtx.Lock()
+	if aw.closed {
+		aw.mtx.Unlock()
+		return nil
+	}
+	aw.closed = true
+	close(aw.queue)
+	aw.mtx.Unlock()
+
+	<-aw.done
+	return closeWriter(aw.w)
+}
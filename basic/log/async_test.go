@@ -0,0 +1,57 @@
+package log
+
+import (
+	"bytes"
+	"sync"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+type blockingWriter struct {
+	mtx     sync.Mutex
+	buf     bytes.Buffer
+	started chan struct{}
+	release chan struct{}
+	closed  bool
+}
+
+func (w *blockingWriter) Write(p []byte) (int, error) {
+	select {
+	case w.started <- struct{}{}:
+	default:
+	}
+	<-w.release
+	w.mtx.Lock()
+	defer w.mtx.Unlock()
+	return w.buf.Write(p)
+}
+
+func (w *blockingWriter) Close() error {
+	w.closed = true
+	return nil
+}
+
+func TestAsyncWriter(t *testing.T) {
+	bw := &blockingWriter{started: make(chan struct{}, 1), release: make(chan struct{})}
+	aw := NewAsyncWriter(bw, 2)
+
+	// 第一条被后台取走并阻塞，队列中最多再放两条
+	aw.Write([]byte("1"))
+	<-bw.started
+	aw.Write([]byte("2"))
+	aw.Write([]byte("3"))
+	n, err := aw.Write([]byte("4"))
+	assert.Nil(t, err)
+	assert.Equal(t, 1, n)
+	assert.Equal(t, uint64(1), aw.Dropped())
+
+	close(bw.release)
+	assert.Nil(t, aw.Close())
+	assert.Equal(t, "123", bw.buf.String())
+	assert.True(t, bw.closed)
+
+	// 关闭后的写入被丢弃
+	aw.Write([]byte("5"))
+	assert.Equal(t, uint64(2), aw.Dropped())
+}
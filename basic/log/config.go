@@ -0,0 +1,178 @@
+package log
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"path"
+	"runtime"
+	"strings"
+	"sync"
+	"time"
+
+	log "github.com/sirupsen/logrus"
+)
+
+type Format string
+
+const (
+	FormatConsole Format = "console" // 带颜色的文本，适合本地开发
+	FormatLogfmt  Format = "logfmt"  // key=value文本，不带颜色
+	FormatJSON    Format = "json"    // 每行一个json对象，适合日志采集
+)
+
+const timestampFormat = "2006-01-02 15:04:05 "
+
+type Config struct {
+	Format Format
+	// 日志级别，如debug、info、warn，为空时不修改
+	Level string
+	// 是否打印调用位置
+	ReportCaller bool
+	// 写入文件，为nil时输出到标准输出
+	File *FileConfig
+	// 写入文件的同时输出到标准输出
+	Stdout bool
+	// 异步写入，为nil时同步写入
+	Async *AsyncConfig
+}
+
+type AsyncConfig struct {
+	// 缓冲队列长度，队列满时丢弃日志并计数，通过Dropped获取
+	QueueSize int
+}
+
+// DefaultConfig 与之前init中的设置保持一致：带颜色的文本输出到标准输出
+func DefaultConfig() Config {
+	return Config{
+		Format:       FormatConsole,
+		ReportCaller: true,
+	}
+}
+
+var (
+	outputMtx sync.Mutex
+	output    io.Writer
+	async     *AsyncWriter
+)
+
+// Init 按照配置初始化logrus的全局logger，重复调用时会关闭之前的输出
+func Init(cfg Config) error {
+	formatter, err := newFormatter(cfg.Format)
+	if err != nil {
+		return err
+	}
+
+	var w io.Writer = os.Stdout
+	if cfg.File != nil {
+		rw, err := NewRotateWriter(*cfg.File)
+		if err != nil {
+			return err
+		}
+		w = rw
+		if cfg.Stdout {
+			w = &teeWriter{Writer: io.MultiWriter(rw, os.Stdout), closer: rw}
+		}
+	}
+	var aw *AsyncWriter
+	if cfg.Async != nil {
+		aw = NewAsyncWriter(w, cfg.Async.QueueSize)
+		w = aw
+	}
+
+	outputMtx.Lock()
+	old := output
+	output, async = w, aw
+	log.SetOutput(w)
+	outputMtx.Unlock()
+	closeWriter(old)
+
+	log.SetFormatter(formatter)
+	log.SetReportCaller(cfg.ReportCaller)
+	syncLoggers()
+	if cfg.Level != "" {
+		SetLevel("", getLogLevel(cfg.Level), 0)
+	}
+	return nil
+}
+
+// Close 刷新异步队列并关闭日志文件，之后的日志输出到标准输出
+func Close() error {
+	outputMtx.Lock()
+	old := output
+	output, async = nil, nil
+	log.SetOutput(os.Stdout)
+	outputMtx.Unlock()
+	syncLoggers()
+	return closeWriter(old)
+}
+
+// Dropped 返回异步写入时因队列已满而丢弃的日志条数
+func Dropped() uint64 {
+	outputMtx.Lock()
+	defer outputMtx.Unlock()
+	if async == nil {
+		return 0
+	}
+	return async.Dropped()
+}
+
+func closeWriter(w io.Writer) error {
+	if w == os.Stdout || w == os.Stderr {
+		return nil
+	}
+	if c, ok := w.(io.Closer); ok {
+		return c.Close()
+	}
+	return nil
+}
+
+type teeWriter struct {
+	io.Writer
+	closer io.Closer
+}
+
+func (t *teeWriter) Close() error {
+	return t.closer.Close()
+}
+
+func newFormatter(format Format) (log.Formatter, error) {
+	switch format {
+	case FormatConsole, "":
+		return &consoleFormatter{&log.TextFormatter{
+			QuoteEmptyFields: true, //empty field will set in ""
+			ForceColors:      true,
+			FullTimestamp:    true,
+			DisableQuote:     true,
+			TimestampFormat:  timestampFormat,
+			CallerPrettyfier: func(frame *runtime.Frame) (function string, file string) {
+				fun, file := callerPrettyfier(frame)
+				return fmt.Sprintf("[\033[1;34m%s\033[0m]", fun), fmt.Sprintf("[%s]", file)
+			},
+		}}, nil
+	case FormatLogfmt:
+		return &log.TextFormatter{
+			QuoteEmptyFields: true,
+			DisableColors:    true,
+			FullTimestamp:    true,
+			TimestampFormat:  time.RFC3339Nano,
+			CallerPrettyfier: callerPrettyfier,
+		}, nil
+	case FormatJSON:
+		return &log.JSONFormatter{
+			TimestampFormat:  time.RFC3339Nano,
+			CallerPrettyfier: callerPrettyfier,
+		}, nil
+	default:
+		return nil, fmt.Errorf("unsupported log format: %s", format)
+	}
+}
+
+// callerPrettyfier 只保留函数名和文件名
+func callerPrettyfier(frame *runtime.Frame) (function string, file string) {
+	fs := strings.Split(frame.Function, ".")
+	if len(fs) > 0 {
+		function = fs[len(fs)-1]
+	}
+	return function, fmt.Sprintf("%s:%d", path.Base(frame.File), frame.Line)
+}
@@ -0,0 +1,66 @@
+package log
+
+import (
+	"context"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	log "github.com/sirupsen/logrus"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestInitJSONFile(t *testing.T) {
+	defer Init(DefaultConfig())
+
+	filename := filepath.Join(t.TempDir(), "app.log")
+	err := Init(Config{
+		Format:       FormatJSON,
+		ReportCaller: true,
+		File:         &FileConfig{Filename: filename},
+		Async:        &AsyncConfig{QueueSize: 16},
+	})
+	assert.Nil(t, err)
+	log.WithField("user", "singer").Info("hello")
+	assert.Nil(t, Close())
+
+	data, err := os.ReadFile(filename)
+	assert.Nil(t, err)
+	assert.False(t, strings.Contains(string(data), "\033["))
+
+	var entry map[string]interface{}
+	assert.Nil(t, json.Unmarshal(data, &entry))
+	assert.Equal(t, "hello", entry["msg"])
+	assert.Equal(t, "singer", entry["user"])
+	assert.Equal(t, "TestInitJSONFile", entry["func"])
+	assert.True(t, strings.HasPrefix(entry["file"].(string), "config_test.go:"))
+}
+
+func TestInitUnknownFormat(t *testing.T) {
+	assert.NotNil(t, Init(Config{Format: "xml"}))
+}
+
+func TestOutputColors(t *testing.T) {
+	defer Init(DefaultConfig())
+
+	for _, format := range []Format{FormatJSON, FormatLogfmt, FormatConsole} {
+		filename := filepath.Join(t.TempDir(), "app.log")
+		assert.Nil(t, Init(Config{Format: format, File: &FileConfig{Filename: filename}}))
+		RpcErrorf(context.Background(), "call %s failed", "/pb.Order/Get")
+		RedisSlowf(context.Background(), time.Second, "get %s", "k")
+		assert.Nil(t, Close())
+
+		data, err := os.ReadFile(filename)
+		assert.Nil(t, err)
+		// 只有控制台输出带颜色
+		assert.Equal(t, format == FormatConsole, strings.Contains(string(data), "\033[31m[RPC-ERR] \033[0m"), format)
+		if format != FormatConsole {
+			assert.False(t, strings.Contains(string(data), "\033["), format)
+			assert.True(t, strings.Contains(string(data), "[RPC-ERR] call /pb.Order/Get failed"), format)
+			assert.True(t, strings.Contains(string(data), "[REDIS-SlowCall] get k"), format)
+		}
+	}
+}
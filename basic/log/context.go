@@ -0,0 +1,61 @@
+package log
+
+import (
+	"context"
+
+	"github.com/sirupsen/logrus"
+	"google.golang.org/grpc/peer"
+	"singer.com/basic/meta"
+	"singer.com/basic/trace"
+)
+
+const (
+	traceIdKey = "TraceId"
+	spanIdKey  = "SpanId"
+	methodKey  = "Method"
+	peerKey    = "Peer"
+	baggageKey = "Baggage"
+)
+
+type loggerKey struct{}
+
+// NewContext 在ctx中安装请求级logger，带有requestid、traceid、spanid、方法名、对端地址和baggage，
+// 由服务端metadata拦截器调用，handler中通过FromContext获取
+func NewContext(ctx context.Context, method string) context.Context {
+	fields := logrus.Fields{requestIdKey: meta.GetRequestId(ctx)}
+	if traceId := trace.TraceIDFromContext(ctx); traceId.IsValid() {
+		fields[traceIdKey] = traceId.String()
+		fields[spanIdKey] = trace.SpanIDFromContext(ctx)
+	}
+	if method != "" {
+		fields[methodKey] = method
+	}
+	if p, ok := peer.FromContext(ctx); ok && p.Addr != nil {
+		fields[peerKey] = p.Addr.String()
+	}
+	if baggage := meta.Baggage(ctx); len(baggage) > 0 {
+		fields[baggageKey] = baggage
+	}
+	return context.WithValue(ctx, loggerKey{}, newEntry(ctx).WithFields(fields))
+}
+
+// newEntry 携带调试标记的请求使用debug级别的logger，使整条调用链都输出调试日志
+func newEntry(ctx context.Context) *logrus.Entry {
+	if meta.IsDebug(ctx) && !logrus.IsLevelEnabled(logrus.DebugLevel) {
+		return logrus.NewEntry(debugLogger)
+	}
+	return logrus.NewEntry(logrus.StandardLogger())
+}
+
+// FromContext 返回请求级logger，ctx中没有时返回只带有requestid的logger
+func FromContext(ctx context.Context) *logrus.Entry {
+	if entry, ok := ctx.Value(loggerKey{}).(*logrus.Entry); ok {
+		return entry
+	}
+	return newEntry(ctx).WithField(requestIdKey, meta.GetRequestId(ctx))
+}
+
+// WithFields 为请求级logger增加字段，之后从返回的ctx中获取的logger都会带上这些字段
+func WithFields(ctx context.Context, fields logrus.Fields) context.Context {
+	return context.WithValue(ctx, loggerKey{}, FromContext(ctx).WithFields(fields))
+}
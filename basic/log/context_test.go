@@ -0,0 +1,42 @@
+package log
+
+import (
+	"context"
+	"net"
+	"testing"
+
+	"github.com/opentracing/opentracing-go"
+	"github.com/stretchr/testify/assert"
+	"github.com/uber/jaeger-client-go"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/peer"
+	"singer.com/basic/meta"
+)
+
+func TestFromContext(t *testing.T) {
+	ctx := metadata.NewIncomingContext(context.Background(), metadata.Pairs("requestid", "req-1"))
+	assert.Equal(t, "req-1", FromContext(ctx).Data[requestIdKey])
+
+	tracer, closer := jaeger.NewTracer("test", jaeger.NewConstSampler(true), jaeger.NewNullReporter())
+	defer closer.Close()
+	span := tracer.StartSpan("op")
+	defer span.Finish()
+	ctx = opentracing.ContextWithSpan(ctx, span)
+	ctx = peer.NewContext(ctx, &peer.Peer{Addr: &net.TCPAddr{IP: net.IPv4(127, 0, 0, 1), Port: 8080}})
+	ctx, _ = meta.SetTenant(ctx, "singer")
+
+	ctx = NewContext(ctx, "/pb.User/Get")
+	entry := FromContext(ctx)
+	sc := span.Context().(jaeger.SpanContext)
+	assert.Equal(t, "req-1", entry.Data[requestIdKey])
+	assert.Equal(t, sc.TraceID().String(), entry.Data[traceIdKey])
+	assert.Equal(t, sc.SpanID().String(), entry.Data[spanIdKey])
+	assert.Equal(t, "/pb.User/Get", entry.Data[methodKey])
+	assert.Equal(t, "127.0.0.1:8080", entry.Data[peerKey])
+	assert.Equal(t, map[string]string{"tenant": "singer"}, entry.Data[baggageKey])
+
+	ctx = WithFields(ctx, map[string]interface{}{"OrderId": 1})
+	entry = FromContext(ctx)
+	assert.Equal(t, 1, entry.Data["OrderId"])
+	assert.Equal(t, "req-1", entry.Data[requestIdKey])
+}
@@ -0,0 +1,198 @@
+package log
+
+import (
+	"sort"
+	"sync"
+	"time"
+
+	log "github.com/sirupsen/logrus"
+)
+
+const loggerNameKey = "Logger"
+
+// 具名logger与全局logger共享输出、格式和hook，但可以单独设置日志级别，
+// 没有单独设置级别时跟随全局级别
+
+type namedLogger struct {
+	logger   *log.Logger
+	explicit bool
+}
+
+type levelExpiry struct {
+	timer    *time.Timer
+	restore  log.Level
+	explicit bool // 到期后是否仍单独设置级别
+	expireAt time.Time
+}
+
+// LevelExpiry 临时修改的日志级别，到期后还原为Restore
+type LevelExpiry struct {
+	Name     string    `json:"name"`
+	Level    string    `json:"level"`
+	Restore  string    `json:"restore"`
+	ExpireAt time.Time `json:"expire_at"`
+}
+
+var (
+	levelMtx sync.Mutex
+	loggers  = map[string]*namedLogger{}
+	expiries = map[string]*levelExpiry{}
+
+	// debugLogger 用于携带调试标记的请求，级别固定为debug
+	debugLogger = newSharedLogger(log.DebugLevel)
+)
+
+func newSharedLogger(level log.Level) *log.Logger {
+	std := log.StandardLogger()
+	return &log.Logger{
+		Out:          std.Out,
+		Formatter:    std.Formatter,
+		Hooks:        std.Hooks,
+		ReportCaller: std.ReportCaller,
+		Level:        level,
+		ExitFunc:     std.ExitFunc,
+	}
+}
+
+// Named 返回具名logger，日志中带有Logger字段，级别可以通过SetLevel或日志服务单独修改
+func Named(name string) *log.Entry {
+	levelMtx.Lock()
+	defer levelMtx.Unlock()
+	nl, ok := loggers[name]
+	if !ok {
+		nl = &namedLogger{logger: newSharedLogger(log.GetLevel())}
+		loggers[name] = nl
+	}
+	return nl.logger.WithField(loggerNameKey, name)
+}
+
+// SetLevel 修改日志级别，name为空时修改全局级别。expire大于0时到期后还原为第一次临时修改前的级别，
+// 为0时永久修改并取消之前的还原
+func SetLevel(name string, level log.Level, expire time.Duration) {
+	levelMtx.Lock()
+	defer levelMtx.Unlock()
+
+	restore, explicit := currentLevel(name)
+	if e, ok := expiries[name]; ok {
+		e.timer.Stop()
+		restore, explicit = e.restore, e.explicit
+		delete(expiries, name)
+	}
+	applyLevel(name, level, true)
+	if expire <= 0 {
+		return
+	}
+
+	e := &levelExpiry{restore: restore, explicit: explicit, expireAt: time.Now().Add(expire)}
+	e.timer = time.AfterFunc(expire, func() {
+		levelMtx.Lock()
+		defer levelMtx.Unlock()
+		// 已被之后的修改替换
+		if expiries[name] != e {
+			return
+		}
+		delete(expiries, name)
+		applyLevel(name, e.restore, e.explicit)
+	})
+	expiries[name] = e
+}
+
+// ResetLevel 取消具名logger单独设置的级别，重新跟随全局级别
+func ResetLevel(name string) {
+	levelMtx.Lock()
+	defer levelMtx.Unlock()
+	if e, ok := expiries[name]; ok {
+		e.timer.Stop()
+		delete(expiries, name)
+	}
+	applyLevel(name, log.GetLevel(), false)
+}
+
+// Levels 返回全局和所有具名logger当前的日志级别，全局级别的key为空字符串
+func Levels() map[string]string {
+	levelMtx.Lock()
+	defer levelMtx.Unlock()
+	levels := map[string]string{"": log.GetLevel().String()}
+	for name, nl := range loggers {
+		levels[name] = nl.logger.GetLevel().String()
+	}
+	return levels
+}
+
+// PendingExpiries 返回所有尚未到期的临时日志级别，按到期时间排序
+func PendingExpiries() []LevelExpiry {
+	levelMtx.Lock()
+	defer levelMtx.Unlock()
+	list := make([]LevelExpiry, 0, len(expiries))
+	for name, e := range expiries {
+		level, _ := currentLevel(name)
+		list = append(list, LevelExpiry{
+			Name:     name,
+			Level:    level.String(),
+			Restore:  e.restore.String(),
+			ExpireAt: e.expireAt,
+		})
+	}
+	sort.Slice(list, func(i, j int) bool {
+		return list[i].ExpireAt.Before(list[j].ExpireAt)
+	})
+	return list
+}
+
+func currentLevelOf(name string) (log.Level, bool) {
+	levelMtx.Lock()
+	defer levelMtx.Unlock()
+	return currentLevel(name)
+}
+
+func currentLevel(name string) (log.Level, bool) {
+	if name == "" {
+		return log.GetLevel(), true
+	}
+	if nl, ok := loggers[name]; ok {
+		return nl.logger.GetLevel(), nl.explicit
+	}
+	return log.GetLevel(), false
+}
+
+// applyLevel 需要持有levelMtx
+func applyLevel(name string, level log.Level, explicit bool) {
+	if name == "" {
+		log.SetLevel(level)
+		for _, nl := range loggers {
+			if !nl.explicit {
+				nl.logger.SetLevel(level)
+			}
+		}
+		return
+	}
+	nl, ok := loggers[name]
+	if !ok {
+		nl = &namedLogger{logger: newSharedLogger(level)}
+		loggers[name] = nl
+	}
+	nl.explicit = explicit
+	if !explicit {
+		level = log.GetLevel()
+	}
+	nl.logger.SetLevel(level)
+}
+
+// syncLoggers 在Init修改全局logger后，同步具名logger和调试logger的输出、格式和全局级别
+func syncLoggers() {
+	levelMtx.Lock()
+	defer levelMtx.Unlock()
+	std := log.StandardLogger()
+	share := func(l *log.Logger) {
+		l.SetOutput(std.Out)
+		l.SetFormatter(std.Formatter)
+		l.SetReportCaller(std.ReportCaller)
+	}
+	share(debugLogger)
+	for _, nl := range loggers {
+		share(nl.logger)
+		if !nl.explicit {
+			nl.logger.SetLevel(log.GetLevel())
+		}
+	}
+}
@@ -0,0 +1,123 @@
+package log
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	log "github.com/sirupsen/logrus"
+	"github.com/stretchr/testify/assert"
+	"google.golang.org/grpc/metadata"
+)
+
+func resetLevels(level log.Level) {
+	levelMtx.Lock()
+	defer levelMtx.Unlock()
+	for _, e := range expiries {
+		e.timer.Stop()
+	}
+	loggers = map[string]*namedLogger{}
+	expiries = map[string]*levelExpiry{}
+	log.SetLevel(level)
+}
+
+func TestNamedLoggerLevel(t *testing.T) {
+	defer resetLevels(log.InfoLevel)
+	resetLevels(log.InfoLevel)
+
+	redis := Named("redis")
+	assert.Equal(t, "redis", redis.Data[loggerNameKey])
+	assert.False(t, redis.Logger.IsLevelEnabled(log.DebugLevel))
+
+	SetLevel("redis", log.DebugLevel, 0)
+	assert.True(t, redis.Logger.IsLevelEnabled(log.DebugLevel))
+	assert.False(t, log.IsLevelEnabled(log.DebugLevel))
+
+	// 单独设置了级别的logger不跟随全局级别
+	SetLevel("", log.WarnLevel, 0)
+	assert.Equal(t, log.DebugLevel, redis.Logger.GetLevel())
+	assert.Equal(t, log.WarnLevel, Named("nsq").Logger.GetLevel())
+
+	ResetLevel("redis")
+	assert.Equal(t, log.WarnLevel, redis.Logger.GetLevel())
+	assert.Equal(t, map[string]string{"": "warning", "redis": "warning", "nsq": "warning"}, Levels())
+}
+
+func TestSetLevelExpire(t *testing.T) {
+	defer resetLevels(log.InfoLevel)
+	resetLevels(log.InfoLevel)
+
+	SetLevel("", log.DebugLevel, time.Hour)
+	// 重复修改不会覆盖最初的还原级别，也不会留下之前的定时器
+	SetLevel("", log.TraceLevel, 50*time.Millisecond)
+	pending := PendingExpiries()
+	assert.Len(t, pending, 1)
+	assert.Equal(t, "trace", pending[0].Level)
+	assert.Equal(t, "info", pending[0].Restore)
+
+	assert.Eventually(t, func() bool {
+		return log.GetLevel() == log.InfoLevel
+	}, time.Second, 10*time.Millisecond)
+	assert.Empty(t, PendingExpiries())
+
+	SetLevel("redis", log.DebugLevel, 50*time.Millisecond)
+	assert.Eventually(t, func() bool {
+		return len(PendingExpiries()) == 0
+	}, time.Second, 10*time.Millisecond)
+	// 到期后重新跟随全局级别
+	SetLevel("", log.WarnLevel, 0)
+	assert.Equal(t, log.WarnLevel, Named("redis").Logger.GetLevel())
+}
+
+func TestDebugRequest(t *testing.T) {
+	defer resetLevels(log.InfoLevel)
+	resetLevels(log.InfoLevel)
+
+	ctx := context.Background()
+	assert.False(t, FromContext(ctx).Logger.IsLevelEnabled(log.DebugLevel))
+
+	ctx = metadata.NewIncomingContext(ctx, metadata.Pairs("x-debug", "1"))
+	assert.True(t, FromContext(ctx).Logger.IsLevelEnabled(log.DebugLevel))
+	assert.True(t, FromContext(NewContext(ctx, "/pb.User/Get")).Logger.IsLevelEnabled(log.DebugLevel))
+}
+
+func TestLogRouter(t *testing.T) {
+	defer resetLevels(log.InfoLevel)
+	resetLevels(log.InfoLevel)
+	gin.SetMode(gin.TestMode)
+	router := newLogRouter()
+
+	do := func(method, url string) *httptest.ResponseRecorder {
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, httptest.NewRequest(method, url, nil))
+		return w
+	}
+
+	assert.Equal(t, http.StatusBadRequest, do(http.MethodPut, "/config?logLevel=verbose").Code)
+	assert.Equal(t, http.StatusBadRequest, do(http.MethodPut, "/config?logLevel=debug&expire=x").Code)
+	assert.Equal(t, http.StatusOK, do(http.MethodPut, "/config?logLevel=debug&name=redis&expire=60").Code)
+
+	var levels struct {
+		Levels map[string]string `json:"levels"`
+	}
+	w := do(http.MethodGet, "/config")
+	assert.Nil(t, json.Unmarshal(w.Body.Bytes(), &levels))
+	assert.Equal(t, map[string]string{"": "info", "redis": "debug"}, levels.Levels)
+
+	var pending struct {
+		Expiries []LevelExpiry `json:"expiries"`
+	}
+	w = do(http.MethodGet, "/config/expiries")
+	assert.Nil(t, json.Unmarshal(w.Body.Bytes(), &pending))
+	assert.Len(t, pending.Expiries, 1)
+	assert.Equal(t, "redis", pending.Expiries[0].Name)
+	assert.Equal(t, "info", pending.Expiries[0].Restore)
+
+	assert.Equal(t, http.StatusOK, do(http.MethodDelete, "/config?name=redis").Code)
+	assert.Empty(t, PendingExpiries())
+	assert.Equal(t, log.InfoLevel, Named("redis").Logger.GetLevel())
+}
@@ -1,40 +1,15 @@
 package log
 
 import (
-	"fmt"
-	"path"
-	"runtime"
+	"net/http"
 	"strconv"
 	"strings"
 	"time"
 
 	"github.com/gin-gonic/gin"
 	log "github.com/sirupsen/logrus"
-	"github.com/spf13/viper"
 )
 
-func init() {
-	//初始化日志
-	log.SetReportCaller(true)
-	log.SetFormatter(&log.TextFormatter{
-		QuoteEmptyFields: true, //empty field will set in ""
-		ForceColors:      true,
-		FullTimestamp:    true,
-		DisableQuote:     true,
-		TimestampFormat:  "2006-01-02 15:04:05 ",
-		CallerPrettyfier: func(frame *runtime.Frame) (function string, file string) {
-			//处理函数名
-			fs := strings.Split(frame.Function, ".")
-			fun := ""
-			if len(fs) > 0 {
-				fun = fs[len(fs)-1]
-			}
-			fileName := path.Base(frame.File)
-			return fmt.Sprintf("[\033[1;34m%s\033[0m]", fun), fmt.Sprintf("[%s:%d]", fileName, frame.Line)
-		},
-	})
-}
-
 func getLogLevel(logLevel string) log.Level {
 	level := log.InfoLevel
 	logLevel = strings.ToUpper(logLevel)
@@ -55,40 +30,65 @@ func getLogLevel(logLevel string) log.Level {
 	return level
 }
 
-//Support modify log level dynamicly
+// InitLogServer support modify log level dynamicly
 func InitLogServer(addr string) {
+	go newLogRouter().Run(addr)
+}
+
+// newLogRouter 日志服务的路由：
+// PUT /config?logLevel=debug&expire=60&name=redis 修改日志级别，name为空时修改全局级别，expire为还原的秒数
+// DELETE /config?name=redis 具名logger重新跟随全局级别
+// GET /config 查看当前日志级别，GET /config/expiries 查看待还原的临时级别
+func newLogRouter() *gin.Engine {
 	router := gin.Default()
 
 	router.PUT("/config", func(c *gin.Context) {
-		oldlevel := getLogLevel(viper.GetString("LOG_LEVEL"))
-		log.Info("OldLevel", oldlevel)
-
+		name := c.Query("name")
 		expire := c.Query("expire")
 		newlevel := c.Query("logLevel")
 
-		level := getLogLevel(newlevel)
-		log.SetLevel(level)
-		log.Info("log level", level) //打印日志级别
+		level, err := log.ParseLevel(newlevel)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"successed:": false, "error": err.Error()})
+			return
+		}
+		tm := 0
+		if expire != "" {
+			if tm, err = strconv.Atoi(expire); err != nil || tm < 0 {
+				c.JSON(http.StatusBadRequest, gin.H{"successed:": false, "error": "invalid expire: " + expire})
+				return
+			}
+		}
+		oldlevel, _ := currentLevelOf(name)
+		SetLevel(name, level, time.Duration(tm)*time.Second)
+		log.Infof("logger %q level changed from %v to %v, expire: %ss", name, oldlevel, level, expire)
 
-		//显示log和viper中的日志级别
-		c.JSON(200, gin.H{
+		c.JSON(http.StatusOK, gin.H{
 			"req time":          expire,
 			"req level":         level,
+			"req name":          name,
 			"successed:":        true,
 			"log level now at ": log.GetLevel(),
 		})
+	})
 
-		if expire != "" {
-			tm, _ := strconv.Atoi(expire)
-			timer := time.NewTimer(time.Duration(tm) * time.Second)
-			go func() {
-				<-timer.C
-				timer.Stop()
-				//定时任务结束，还原日志级别
-				log.SetLevel(oldlevel)
-			}()
+	router.DELETE("/config", func(c *gin.Context) {
+		name := c.Query("name")
+		if name == "" {
+			c.JSON(http.StatusBadRequest, gin.H{"successed:": false, "error": "empty logger name"})
+			return
 		}
+		ResetLevel(name)
+		c.JSON(http.StatusOK, gin.H{"successed:": true})
+	})
+
+	router.GET("/config", func(c *gin.Context) {
+		c.JSON(http.StatusOK, gin.H{"levels": Levels()})
+	})
+
+	router.GET("/config/expiries", func(c *gin.Context) {
+		c.JSON(http.StatusOK, gin.H{"expiries": PendingExpiries()})
 	})
 
-	go router.Run(addr)
+	return router
 }
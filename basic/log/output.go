@@ -2,10 +2,10 @@ package log
 
 import (
 	"context"
+	"strings"
 	"time"
 
-	"github.com/sirupsen/logrus"
-	"singer.com/basic/meta"
+	log "github.com/sirupsen/logrus"
 	"singer.com/util/color"
 )
 
@@ -16,20 +16,50 @@ const (
 	rpcSlowCallKey = "[RPC-SlowCall] "
 	rpcErrorKey    = "[RPC-ERR] "
 	rpcPanicKey    = "[RPC-PANIC] "
+
+	redisSlowCallKey = "[REDIS-SlowCall] "
 )
 
 func RpcSlowf(ctx context.Context, d time.Duration, format string, args ...interface{}) {
-	requestId := meta.GetRequestId(ctx)
-	logrus.WithField(costKey, d).WithField(requestIdKey, requestId).Warnf(color.Yellow(rpcSlowCallKey)+format, args...)
+	FromContext(ctx).WithField(costKey, d).Warnf(rpcSlowCallKey+format, args...)
 }
 
 func RpcErrorf(ctx context.Context, format string, args ...interface{}) {
-	requestId := meta.GetRequestId(ctx)
-	logrus.WithField(requestIdKey, requestId).Errorf(color.Red(rpcErrorKey)+format, args...)
+	FromContext(ctx).Errorf(rpcErrorKey+format, args...)
 }
 
 //will not panic, only log
 func RpcPanicf(ctx context.Context, format string, args ...interface{}) {
-	requestId := meta.GetRequestId(ctx)
-	logrus.WithField(requestIdKey, requestId).Errorf(color.Red(rpcPanicKey)+format, args...)
+	FromContext(ctx).Errorf(rpcPanicKey+format, args...)
+}
+
+func RedisSlowf(ctx context.Context, d time.Duration, format string, args ...interface{}) {
+	FromContext(ctx).WithField(costKey, d).Warnf(redisSlowCallKey+format, args...)
+}
+
+// messageColors 控制台输出时为这些消息前缀加上颜色，消息本身不带颜色，json和logfmt输出中没有转义字符
+var messageColors = []struct {
+	prefix  string
+	colored string
+}{
+	{rpcSlowCallKey, color.Yellow(rpcSlowCallKey)},
+	{rpcErrorKey, color.Red(rpcErrorKey)},
+	{rpcPanicKey, color.Red(rpcPanicKey)},
+	{redisSlowCallKey, color.Yellow(redisSlowCallKey)},
+}
+
+// consoleFormatter 为消息前缀加上颜色
+type consoleFormatter struct {
+	*log.TextFormatter
+}
+
+func (f *consoleFormatter) Format(entry *log.Entry) ([]byte, error) {
+	for _, c := range messageColors {
+		if strings.HasPrefix(entry.Message, c.prefix) {
+			e := *entry
+			e.Message = c.colored + entry.Message[len(c.prefix):]
+			return f.TextFormatter.Format(&e)
+		}
+	}
+	return f.TextFormatter.Format(entry)
 }
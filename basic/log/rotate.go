@@ -0,0 +1,352 @@
+package log
+
+import (
+	"compress/gzip"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"singer.com/util/clock"
+)
+
+const (
+	backupTimeFormat = "20060102T150405.000"
+	compressSuffix   = ".gz"
+	megabyte         = 1024 * 1024
+)
+
+// osRename 测试中替换
+var osRename = os.Rename
+
+type FileConfig struct {
+	// 日志文件路径，备份文件为 name-时间.ext 的形式，位于同一目录
+	Filename string
+	// 单个文件的最大大小(MB)，0表示不按大小切割
+	MaxSize int
+	// 按时间切割的周期，如time.Hour、24*time.Hour，按本地时间对齐，0表示不按时间切割
+	RotateInterval time.Duration
+	// 备份文件保留时间，0表示不按时间清理
+	MaxAge time.Duration
+	// 最多保留的备份文件数，0表示不按数量清理
+	MaxBackups int
+	// 是否gzip压缩备份文件
+	Compress bool
+	// 测试时可以注入假时钟
+	Clock clock.PassiveClock
+}
+
+// RotateWriter 按大小和时间切割日志文件，并在后台压缩和清理备份
+type RotateWriter struct {
+	cfg FileConfig
+
+	mtx         sync.Mutex
+	file        *os.File
+	size        int64
+	periodStart time.Time
+	closed      bool
+
+	millCh   chan struct{}
+	millDone chan struct{}
+}
+
+func NewRotateWriter(cfg FileConfig) (*RotateWriter, error) {
+	if cfg.Filename == "" {
+		return nil, errors.New("log: empty file name")
+	}
+	if cfg.Clock == nil {
+		cfg.Clock = clock.RealClock{}
+	}
+	if err := os.MkdirAll(filepath.Dir(cfg.Filename), 0755); err != nil {
+		return nil, err
+	}
+	w := &RotateWriter{
+		cfg:      cfg,
+		millCh:   make(chan struct{}, 1),
+		millDone: make(chan struct{}),
+	}
+	if err := w.openExisting(); err != nil {
+		return nil, err
+	}
+	go w.millRun()
+	return w, nil
+}
+
+func (w *RotateWriter) Write(p []byte) (int, error) {
+	w.mtx.Lock()
+	defer w.mtx.Unlock()
+
+	if w.closed {
+		return 0, os.ErrClosed
+	}
+	// 之前切割失败且没能重新打开文件
+	if w.file == nil {
+		if err := w.openAppend(); err != nil {
+			return 0, err
+		}
+	}
+	now := w.cfg.Clock.Now()
+	if w.shouldRotate(now, len(p)) {
+		if err := w.rotate(now); err != nil {
+			fmt.Fprintf(os.Stderr, "log: rotate %s failed: %v\n", w.cfg.Filename, err)
+			if w.file == nil {
+				return 0, err
+			}
+		}
+	}
+	n, err := w.file.Write(p)
+	w.size += int64(n)
+	return n, err
+}
+
+func (w *RotateWriter) shouldRotate(now time.Time, n int) bool {
+	if w.cfg.MaxSize > 0 && w.size > 0 && w.size+int64(n) > int64(w.cfg.MaxSize)*megabyte {
+		return true
+	}
+	return w.cfg.RotateInterval > 0 && !w.period(now).Equal(w.periodStart)
+}
+
+// period 返回now所在切割周期的开始时间，按本地时区对齐
+func (w *RotateWriter) period(now time.Time) time.Time {
+	if w.cfg.RotateInterval <= 0 {
+		return time.Time{}
+	}
+	_, offset := now.Zone()
+	shift := time.Duration(offset) * time.Second
+	return now.Add(shift).Truncate(w.cfg.RotateInterval).Add(-shift)
+}
+
+func (w *RotateWriter) openExisting() error {
+	now := w.cfg.Clock.Now()
+	if err := w.openAppend(); err != nil {
+		return err
+	}
+	info, err := w.file.Stat()
+	if err != nil {
+		return err
+	}
+	w.periodStart = w.period(now)
+	// 上次写入的文件不属于当前周期
+	if w.size > 0 && w.cfg.RotateInterval > 0 && w.period(info.ModTime()).Before(w.periodStart) {
+		return w.rotate(now)
+	}
+	return nil
+}
+
+// openAppend 以追加方式打开日志文件
+func (w *RotateWriter) openAppend() error {
+	f, err := os.OpenFile(w.cfg.Filename, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0644)
+	if err != nil {
+		return err
+	}
+	info, err := f.Stat()
+	if err != nil {
+		f.Close()
+		return err
+	}
+	w.file = f
+	w.size = info.Size()
+	return nil
+}
+
+// rotate 切割当前文件。关闭文件之后失败时重新以追加方式打开原文件继续写入，
+// 重新打开也失败时w.file为nil，下次Write时重试
+func (w *RotateWriter) rotate(now time.Time) error {
+	err := w.file.Close()
+	w.file = nil
+	if err == nil {
+		if err = osRename(w.cfg.Filename, w.backupName(now)); os.IsNotExist(err) {
+			err = nil
+		}
+	}
+	if err != nil {
+		w.openAppend()
+		return err
+	}
+	f, err := os.OpenFile(w.cfg.Filename, os.O_CREATE|os.O_WRONLY|os.O_TRUNC, 0644)
+	if err != nil {
+		return err
+	}
+	w.file = f
+	w.size = 0
+	w.periodStart = w.period(now)
+	select {
+	case w.millCh <- struct{}{}:
+	default:
+	}
+	return nil
+}
+
+// backupName 返回备份文件名，同一毫秒内多次切割时加上-1、-2等后缀，避免覆盖已有的备份
+func (w *RotateWriter) backupName(now time.Time) string {
+	dir := filepath.Dir(w.cfg.Filename)
+	prefix, ext := w.prefixAndExt()
+	ts := now.In(time.Local).Format(backupTimeFormat)
+	name := filepath.Join(dir, prefix+ts+ext)
+	for seq := 1; fileExists(name) || fileExists(name+compressSuffix); seq++ {
+		name = filepath.Join(dir, prefix+ts+"-"+strconv.Itoa(seq)+ext)
+	}
+	return name
+}
+
+func fileExists(name string) bool {
+	_, err := os.Lstat(name)
+	return err == nil
+}
+
+func (w *RotateWriter) prefixAndExt() (string, string) {
+	base := filepath.Base(w.cfg.Filename)
+	ext := filepath.Ext(base)
+	return strings.TrimSuffix(base, ext) + "-", ext
+}
+
+type backupFile struct {
+	name string
+	t    time.Time
+	seq  int
+}
+
+// oldBackups 返回所有备份文件，按时间从新到旧排序
+func (w *RotateWriter) oldBackups() ([]backupFile, error) {
+	dir := filepath.Dir(w.cfg.Filename)
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		return nil, err
+	}
+	prefix, ext := w.prefixAndExt()
+	var backups []backupFile
+	for _, e := range entries {
+		if e.IsDir() {
+			continue
+		}
+		name := e.Name()
+		ts := strings.TrimSuffix(name, compressSuffix)
+		if !strings.HasPrefix(ts, prefix) || !strings.HasSuffix(ts, ext) {
+			continue
+		}
+		ts = ts[len(prefix) : len(ts)-len(ext)]
+		seq := 0
+		if i := strings.LastIndexByte(ts, '-'); i >= 0 {
+			if seq, err = strconv.Atoi(ts[i+1:]); err != nil {
+				continue
+			}
+			ts = ts[:i]
+		}
+		t, err := time.ParseInLocation(backupTimeFormat, ts, time.Local)
+		if err != nil {
+			continue
+		}
+		backups = append(backups, backupFile{name: filepath.Join(dir, name), t: t, seq: seq})
+	}
+	sort.Slice(backups, func(i, j int) bool {
+		if backups[i].t.Equal(backups[j].t) {
+			return backups[i].seq > backups[j].seq
+		}
+		return backups[i].t.After(backups[j].t)
+	})
+	return backups, nil
+}
+
+func (w *RotateWriter) millRun() {
+	defer close(w.millDone)
+	for range w.millCh {
+		if err := w.mill(); err != nil {
+			fmt.Fprintf(os.Stderr, "log: clean up rotated files failed: %v\n", err)
+		}
+	}
+}
+
+// mill 压缩并清理过期的备份文件
+func (w *RotateWriter) mill() error {
+	backups, err := w.oldBackups()
+	if err != nil {
+		return err
+	}
+	cutoff := w.cfg.Clock.Now().Add(-w.cfg.MaxAge)
+	var remain []backupFile
+	for i, b := range backups {
+		if (w.cfg.MaxBackups > 0 && i >= w.cfg.MaxBackups) || (w.cfg.MaxAge > 0 && b.t.Before(cutoff)) {
+			if err := os.Remove(b.name); err != nil && !os.IsNotExist(err) {
+				return err
+			}
+			continue
+		}
+		remain = append(remain, b)
+	}
+	if !w.cfg.Compress {
+		return nil
+	}
+	for _, b := range remain {
+		if strings.HasSuffix(b.name, compressSuffix) {
+			continue
+		}
+		if err := compressFile(b.name, b.name+compressSuffix); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func compressFile(src, dst string) error {
+	in, err := os.Open(src)
+	if err != nil {
+		return err
+	}
+	defer in.Close()
+	out, err := os.OpenFile(dst, os.O_CREATE|os.O_WRONLY|os.O_TRUNC, 0644)
+	if err != nil {
+		return err
+	}
+	gz := gzip.NewWriter(out)
+	if _, err = io.Copy(gz, in); err == nil {
+		err = gz.Close()
+	}
+	if cerr := out.Close(); err == nil {
+		err = cerr
+	}
+	if err != nil {
+		os.Remove(dst)
+		return err
+	}
+	return os.Remove(src)
+}
+
+// Rotate 立即切割当前文件
+func (w *RotateWriter) Rotate() error {
+	w.mtx.Lock()
+	defer w.mtx.Unlock()
+	if w.closed {
+		return os.ErrClosed
+	}
+	if w.file == nil {
+		if err := w.openAppend(); err != nil {
+			return err
+		}
+	}
+	return w.rotate(w.cfg.Clock.Now())
+}
+
+// Close 关闭文件，并等待后台的压缩和清理结束
+func (w *RotateWriter) Close() error {
+	w.mtx.Lock()
+	if w.closed {
+		w.mtx.Unlock()
+		return nil
+	}
+	w.closed = true
+	var err error
+	if w.file != nil {
+		err = w.file.Close()
+		w.file = nil
+	}
+	close(w.millCh)
+	w.mtx.Unlock()
+	<-w.millDone
+	return err
+}
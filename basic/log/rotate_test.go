@@ -0,0 +1,153 @@
+package log
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+type fakeClock struct {
+	now time.Time
+}
+
+func (c *fakeClock) Now() time.Time                  { return c.now }
+func (c *fakeClock) Since(t time.Time) time.Duration { return c.now.Sub(t) }
+
+func listFiles(t *testing.T, dir string) []string {
+	entries, err := os.ReadDir(dir)
+	assert.Nil(t, err)
+	var names []string
+	for _, e := range entries {
+		names = append(names, e.Name())
+	}
+	return names
+}
+
+func TestRotateWriterSize(t *testing.T) {
+	dir := t.TempDir()
+	clk := &fakeClock{now: time.Date(2022, 1, 1, 10, 0, 0, 0, time.Local)}
+	w, err := NewRotateWriter(FileConfig{Filename: filepath.Join(dir, "app.log"), MaxSize: 1, Clock: clk})
+	assert.Nil(t, err)
+
+	line := []byte(strings.Repeat("a", 600*1024))
+	_, err = w.Write(line)
+	assert.Nil(t, err)
+	clk.now = clk.now.Add(time.Second)
+	// 超过1MB，切割后写入新文件
+	_, err = w.Write(line)
+	assert.Nil(t, err)
+	assert.Nil(t, w.Close())
+
+	assert.ElementsMatch(t, []string{"app.log", "app-20220101T100001.000.log"}, listFiles(t, dir))
+	info, err := os.Stat(filepath.Join(dir, "app.log"))
+	assert.Nil(t, err)
+	assert.Equal(t, int64(len(line)), info.Size())
+}
+
+func TestRotateWriterInterval(t *testing.T) {
+	dir := t.TempDir()
+	clk := &fakeClock{now: time.Date(2022, 1, 1, 10, 30, 0, 0, time.Local)}
+	w, err := NewRotateWriter(FileConfig{Filename: filepath.Join(dir, "app.log"), RotateInterval: time.Hour, Clock: clk})
+	assert.Nil(t, err)
+
+	w.Write([]byte("first\n"))
+	clk.now = clk.now.Add(20 * time.Minute)
+	w.Write([]byte("second\n"))
+	// 同一小时内不切割
+	assert.Equal(t, []string{"app.log"}, listFiles(t, dir))
+
+	clk.now = time.Date(2022, 1, 1, 11, 0, 0, 0, time.Local)
+	w.Write([]byte("third\n"))
+	assert.Nil(t, w.Close())
+
+	assert.ElementsMatch(t, []string{"app.log", "app-20220101T110000.000.log"}, listFiles(t, dir))
+	data, err := os.ReadFile(filepath.Join(dir, "app-20220101T110000.000.log"))
+	assert.Nil(t, err)
+	assert.Equal(t, "first\nsecond\n", string(data))
+}
+
+func TestRotateWriterRetention(t *testing.T) {
+	dir := t.TempDir()
+	clk := &fakeClock{now: time.Date(2022, 1, 1, 10, 0, 0, 0, time.Local)}
+	w, err := NewRotateWriter(FileConfig{
+		Filename:   filepath.Join(dir, "app.log"),
+		MaxBackups: 2,
+		Compress:   true,
+		Clock:      clk,
+	})
+	assert.Nil(t, err)
+
+	for i := 0; i < 4; i++ {
+		w.Write([]byte("line\n"))
+		clk.now = clk.now.Add(time.Minute)
+		assert.Nil(t, w.Rotate())
+	}
+	assert.Nil(t, w.Close())
+	// Close会等待后台清理，但多次切割可能合并为一次清理，这里再清理一次保证结果确定
+	w2 := &RotateWriter{cfg: w.cfg}
+	assert.Nil(t, w2.mill())
+
+	assert.ElementsMatch(t, []string{
+		"app.log",
+		"app-20220101T100400.000.log.gz",
+		"app-20220101T100300.000.log.gz",
+	}, listFiles(t, dir))
+}
+
+func TestRotateWriterSameMillisecond(t *testing.T) {
+	dir := t.TempDir()
+	clk := &fakeClock{now: time.Date(2022, 1, 1, 10, 0, 0, 0, time.Local)}
+	w, err := NewRotateWriter(FileConfig{Filename: filepath.Join(dir, "app.log"), MaxBackups: 2, Clock: clk})
+	assert.Nil(t, err)
+
+	for _, line := range []string{"first\n", "second\n", "third\n"} {
+		w.Write([]byte(line))
+		assert.Nil(t, w.Rotate())
+	}
+	assert.Nil(t, w.Close())
+	w2 := &RotateWriter{cfg: w.cfg}
+	assert.Nil(t, w2.mill())
+
+	// 同一毫秒内的切割不会覆盖之前的备份，清理时后缀大的更新
+	assert.ElementsMatch(t, []string{
+		"app.log",
+		"app-20220101T100000.000-1.log",
+		"app-20220101T100000.000-2.log",
+	}, listFiles(t, dir))
+	data, err := os.ReadFile(filepath.Join(dir, "app-20220101T100000.000-2.log"))
+	assert.Nil(t, err)
+	assert.Equal(t, "third\n", string(data))
+}
+
+func TestRotateWriterRenameFailed(t *testing.T) {
+	dir := t.TempDir()
+	clk := &fakeClock{now: time.Date(2022, 1, 1, 10, 0, 0, 0, time.Local)}
+	w, err := NewRotateWriter(FileConfig{Filename: filepath.Join(dir, "app.log"), Clock: clk})
+	assert.Nil(t, err)
+	defer w.Close()
+
+	w.Write([]byte("first\n"))
+	osRename = func(string, string) error { return errors.New("rename failed") }
+	assert.NotNil(t, w.Rotate())
+	osRename = os.Rename
+
+	// 切割失败后继续写入原文件
+	_, err = w.Write([]byte("second\n"))
+	assert.Nil(t, err)
+	assert.Equal(t, []string{"app.log"}, listFiles(t, dir))
+	data, _ := os.ReadFile(filepath.Join(dir, "app.log"))
+	assert.Equal(t, "first\nsecond\n", string(data))
+
+	// 之后可以正常切割
+	assert.Nil(t, w.Rotate())
+	_, err = w.Write([]byte("third\n"))
+	assert.Nil(t, err)
+	assert.ElementsMatch(t, []string{"app.log", "app-20220101T100000.000.log"}, listFiles(t, dir))
+	data, _ = os.ReadFile(filepath.Join(dir, "app.log"))
+	assert.Equal(t, "third\n", string(data))
+}
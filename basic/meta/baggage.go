@@ -0,0 +1,203 @@
+package meta
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+	"sync"
+
+	"google.golang.org/grpc/metadata"
+)
+
+// baggage 是随调用链向下游传递的自定义元数据，以kBaggagePrefix为前缀保存在metadata中。
+// 客户端拦截器只会传递baggage和白名单中的key，authorization、grpc内部key等逐跳的header不会被透传。
+
+const (
+	kBaggagePrefix = "x-baggage-"
+
+	// 下游服务返回的错误也需要使用调用方的语言，见errorx.LangFromContext
+	kMetadataKeyAcceptLanguage        = "accept-language"
+	kMetadataKeyGatewayAcceptLanguage = "grpcgateway-accept-language"
+
+	kBaggageUserId        = "user-id"
+	kBaggageTenant        = "tenant"
+	kBaggageClientVersion = "client-version"
+
+	MaxBaggageItems    = 32
+	MaxBaggageKeyLen   = 64
+	MaxBaggageValueLen = 1024
+	MaxBaggageSize     = 8 << 10 // 所有baggage key和value的总长度
+)
+
+var (
+	ErrBaggageInvalidKey = errors.New("meta: invalid baggage key")
+	ErrBaggageTooLarge   = errors.New("meta: baggage exceeds size limits")
+)
+
+var (
+	propagateMtx sync.RWMutex
+	// 除baggage外需要传递给下游的key
+	propagateKeys = map[string]struct{}{
+		kMetadataKeyRequestID:             {},
+		kMetadataKeyDebug:                 {},
+		kMetadataKeyAcceptLanguage:        {},
+		kMetadataKeyGatewayAcceptLanguage: {},
+	}
+)
+
+// AllowPropagation 将key加入传递白名单，用于兼容不带baggage前缀的历史header
+func AllowPropagation(keys ...string) {
+	propagateMtx.Lock()
+	defer propagateMtx.Unlock()
+	for _, k := range keys {
+		propagateKeys[strings.ToLower(k)] = struct{}{}
+	}
+}
+
+func isPropagated(key string) bool {
+	propagateMtx.RLock()
+	defer propagateMtx.RUnlock()
+	_, ok := propagateKeys[key]
+	return ok
+}
+
+// SetBaggage 设置baggage，当前请求可以通过Baggage读取，并由客户端拦截器传递给下游
+func SetBaggage(ctx context.Context, key, value string) (context.Context, error) {
+	key = strings.ToLower(key)
+	if !validBaggageKey(key) {
+		return ctx, fmt.Errorf("%w: %q", ErrBaggageInvalidKey, key)
+	}
+	if len(value) > MaxBaggageValueLen {
+		return ctx, ErrBaggageTooLarge
+	}
+	md, _ := metadata.FromIncomingContext(ctx)
+	md = md.Copy()
+	md.Set(kBaggagePrefix+key, value)
+	if !withinBaggageLimits(md) {
+		return ctx, ErrBaggageTooLarge
+	}
+	return metadata.NewIncomingContext(ctx, md), nil
+}
+
+// GetBaggage 返回指定key的baggage
+func GetBaggage(ctx context.Context, key string) string {
+	md, _ := metadata.FromIncomingContext(ctx)
+	if vals := md.Get(kBaggagePrefix + strings.ToLower(key)); len(vals) > 0 {
+		return vals[0]
+	}
+	return ""
+}
+
+// Baggage 返回所有baggage，key不带前缀
+func Baggage(ctx context.Context) map[string]string {
+	md, _ := metadata.FromIncomingContext(ctx)
+	baggage := map[string]string{}
+	for k, vals := range md {
+		if strings.HasPrefix(k, kBaggagePrefix) && len(vals) > 0 {
+			baggage[strings.TrimPrefix(k, kBaggagePrefix)] = vals[0]
+		}
+	}
+	return baggage
+}
+
+func UserId(ctx context.Context) string {
+	return GetBaggage(ctx, kBaggageUserId)
+}
+
+func SetUserId(ctx context.Context, userId string) (context.Context, error) {
+	return SetBaggage(ctx, kBaggageUserId, userId)
+}
+
+func Tenant(ctx context.Context) string {
+	return GetBaggage(ctx, kBaggageTenant)
+}
+
+func SetTenant(ctx context.Context, tenant string) (context.Context, error) {
+	return SetBaggage(ctx, kBaggageTenant, tenant)
+}
+
+func ClientVersion(ctx context.Context) string {
+	return GetBaggage(ctx, kBaggageClientVersion)
+}
+
+func SetClientVersion(ctx context.Context, version string) (context.Context, error) {
+	return SetBaggage(ctx, kBaggageClientVersion, version)
+}
+
+// PropagatedMetadata 从incoming metadata中挑选需要传递给下游的key：requestid、调试标记、白名单和baggage，
+// 超出数量或大小限制的baggage会被丢弃
+func PropagatedMetadata(ctx context.Context) metadata.MD {
+	in, _ := metadata.FromIncomingContext(ctx)
+	out := metadata.MD{}
+	keys := make([]string, 0, len(in))
+	for k := range in {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	var items, size int
+	for _, k := range keys {
+		vals := in[k]
+		if len(vals) == 0 {
+			continue
+		}
+		if !strings.HasPrefix(k, kBaggagePrefix) {
+			if isPropagated(k) {
+				out[k] = vals
+			}
+			continue
+		}
+		v := vals[0]
+		if !validBaggageKey(strings.TrimPrefix(k, kBaggagePrefix)) || len(v) > MaxBaggageValueLen ||
+			items >= MaxBaggageItems || size+len(k)+len(v) > MaxBaggageSize {
+			continue
+		}
+		items++
+		size += len(k) + len(v)
+		out[k] = []string{v}
+	}
+	return out
+}
+
+// OutgoingContext 将PropagatedMetadata合并到outgoing metadata中，调用方显式设置的key优先
+func OutgoingContext(ctx context.Context) context.Context {
+	propagated := PropagatedMetadata(ctx)
+	if len(propagated) == 0 {
+		return ctx
+	}
+	out, ok := metadata.FromOutgoingContext(ctx)
+	if !ok {
+		return metadata.NewOutgoingContext(ctx, propagated)
+	}
+	out = out.Copy()
+	for k, vals := range propagated {
+		if len(out.Get(k)) == 0 {
+			out[k] = vals
+		}
+	}
+	return metadata.NewOutgoingContext(ctx, out)
+}
+
+func validBaggageKey(key string) bool {
+	if len(key) == 0 || len(key) > MaxBaggageKeyLen {
+		return false
+	}
+	for _, c := range key {
+		if !(c >= 'a' && c <= 'z' || c >= '0' && c <= '9' || c == '-' || c == '_' || c == '.') {
+			return false
+		}
+	}
+	return true
+}
+
+func withinBaggageLimits(md metadata.MD) bool {
+	var items, size int
+	for k, vals := range md {
+		if strings.HasPrefix(k, kBaggagePrefix) && len(vals) > 0 {
+			items++
+			size += len(k) + len(vals[0])
+		}
+	}
+	return items <= MaxBaggageItems && size <= MaxBaggageSize
+}
@@ -0,0 +1,79 @@
+package meta
+
+import (
+	"context"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"google.golang.org/grpc/metadata"
+)
+
+func TestBaggage(t *testing.T) {
+	ctx := context.Background()
+	ctx, err := SetBaggage(ctx, "Order-Source", "app")
+	assert.Nil(t, err)
+	ctx, err = SetUserId(ctx, "10086")
+	assert.Nil(t, err)
+	ctx, err = SetTenant(ctx, "singer")
+	assert.Nil(t, err)
+	ctx, err = SetClientVersion(ctx, "1.2.0")
+	assert.Nil(t, err)
+
+	assert.Equal(t, "app", GetBaggage(ctx, "order-source"))
+	assert.Equal(t, "10086", UserId(ctx))
+	assert.Equal(t, "singer", Tenant(ctx))
+	assert.Equal(t, "1.2.0", ClientVersion(ctx))
+	assert.Equal(t, map[string]string{
+		"order-source":   "app",
+		"user-id":        "10086",
+		"tenant":         "singer",
+		"client-version": "1.2.0",
+	}, Baggage(ctx))
+
+	_, err = SetBaggage(ctx, "bad key", "v")
+	assert.ErrorIs(t, err, ErrBaggageInvalidKey)
+	_, err = SetBaggage(ctx, "big", strings.Repeat("v", MaxBaggageValueLen+1))
+	assert.ErrorIs(t, err, ErrBaggageTooLarge)
+}
+
+func TestBaggageLimits(t *testing.T) {
+	ctx := context.Background()
+	var err error
+	for i := 0; i < MaxBaggageItems; i++ {
+		ctx, err = SetBaggage(ctx, "k"+strings.Repeat("x", i), "v")
+		assert.Nil(t, err)
+	}
+	_, err = SetBaggage(ctx, "one-more", "v")
+	assert.ErrorIs(t, err, ErrBaggageTooLarge)
+}
+
+func TestOutgoingContext(t *testing.T) {
+	AllowPropagation("X-Legacy-Header")
+	in := metadata.Pairs(
+		"requestid", "req-1",
+		"x-debug", "1",
+		"authorization", "Bearer token",
+		"uber-trace-id", "abc:def:0:1",
+		"x-legacy-header", "legacy",
+		"accept-language", "en-US",
+		"grpcgateway-accept-language", "zh-CN",
+		"x-baggage-tenant", "singer",
+		"x-baggage-big", strings.Repeat("v", MaxBaggageValueLen+1),
+	)
+	ctx := metadata.NewIncomingContext(context.Background(), in)
+	ctx = metadata.AppendToOutgoingContext(ctx, "x-baggage-tenant", "override", "custom", "value")
+	ctx = OutgoingContext(ctx)
+
+	out, _ := metadata.FromOutgoingContext(ctx)
+	assert.Equal(t, []string{"req-1"}, out.Get("requestid"))
+	assert.Equal(t, []string{"1"}, out.Get("x-debug"))
+	assert.Equal(t, []string{"legacy"}, out.Get("x-legacy-header"))
+	assert.Equal(t, []string{"en-US"}, out.Get("accept-language"))
+	assert.Equal(t, []string{"zh-CN"}, out.Get("grpcgateway-accept-language"))
+	assert.Equal(t, []string{"override"}, out.Get("x-baggage-tenant"))
+	assert.Equal(t, []string{"value"}, out.Get("custom"))
+	assert.Empty(t, out.Get("authorization"))
+	assert.Empty(t, out.Get("uber-trace-id"))
+	assert.Empty(t, out.Get("x-baggage-big"))
+}
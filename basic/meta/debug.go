@@ -0,0 +1,27 @@
+package meta
+
+import (
+	"context"
+
+	"google.golang.org/grpc/metadata"
+)
+
+const (
+	// kMetadataKeyDebug 调试标记，设置后整条调用链强制采样
+	kMetadataKeyDebug string = "x-debug"
+)
+
+// IsDebug 判断请求是否携带调试标记
+func IsDebug(ctx context.Context) bool {
+	md, ok := metadata.FromIncomingContext(ctx)
+	if !ok {
+		return false
+	}
+	vals := md.Get(kMetadataKeyDebug)
+	return len(vals) > 0 && (vals[0] == "1" || vals[0] == "true")
+}
+
+// WithDebug 为发出的请求设置调试标记
+func WithDebug(ctx context.Context) context.Context {
+	return metadata.AppendToOutgoingContext(ctx, kMetadataKeyDebug, "1")
+}
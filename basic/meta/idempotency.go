@@ -0,0 +1,41 @@
+package meta
+
+import (
+	"context"
+
+	"google.golang.org/grpc/metadata"
+)
+
+const (
+	// kMetadataKeyIdempotency 幂等键，相同幂等键的请求只处理一次
+	kMetadataKeyIdempotency string = "x-idempotency-key"
+)
+
+// GetIdempotencyKey 返回请求携带的幂等键
+func GetIdempotencyKey(ctx context.Context) string {
+	md, ok := metadata.FromIncomingContext(ctx)
+	if !ok {
+		return ""
+	}
+	if vals := md.Get(kMetadataKeyIdempotency); len(vals) > 0 {
+		return vals[0]
+	}
+	return ""
+}
+
+// WithIdempotencyKey 为发出的请求设置幂等键，调用方重启后重发同一业务请求时应使用相同的幂等键
+func WithIdempotencyKey(ctx context.Context, key string) context.Context {
+	md, _ := metadata.FromOutgoingContext(ctx)
+	md = md.Copy()
+	md.Set(kMetadataKeyIdempotency, key)
+	return metadata.NewOutgoingContext(ctx, md)
+}
+
+// OutgoingIdempotencyKey 返回发出的请求已设置的幂等键
+func OutgoingIdempotencyKey(ctx context.Context) string {
+	md, _ := metadata.FromOutgoingContext(ctx)
+	if vals := md.Get(kMetadataKeyIdempotency); len(vals) > 0 {
+		return vals[0]
+	}
+	return ""
+}
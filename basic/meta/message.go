@@ -24,6 +24,14 @@ func GetRequestId(ctx context.Context) (requestId string) {
 	return
 }
 
+// WithRequestId 将requestId写入incoming metadata，用于非grpc入口(如消息队列)恢复请求上下文
+func WithRequestId(ctx context.Context, requestId string) context.Context {
+	md, _ := metadata.FromIncomingContext(ctx)
+	md = md.Copy()
+	md.Set(kMetadataKeyRequestID, requestId)
+	return metadata.NewIncomingContext(ctx, md)
+}
+
 func newTraceId(ctx context.Context) string {
 	traceID := trace.TraceIDFromContext(ctx)
 	if !traceID.IsValid() {
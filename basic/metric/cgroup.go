@@ -0,0 +1,199 @@
+package metric
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+// 容器中runtime.NumCPU和/proc/meminfo返回的是宿主机的资源，需要从cgroup读取实际的限制和用量
+
+var (
+	cgroupRoot     = "/sys/fs/cgroup"
+	procSelfCgroup = "/proc/self/cgroup"
+)
+
+var ErrNoCgroup = errors.New("metric: cgroup not found")
+
+type CgroupStats struct {
+	// CPU限制的核数，0表示不限制
+	CPULimit float64
+	// 累计使用的CPU时间
+	CPUUsage time.Duration
+	// 当前内存用量(字节)
+	MemoryUsage uint64
+	// 内存限制(字节)，0表示不限制
+	MemoryLimit uint64
+}
+
+// MemoryRatio 返回内存用量占限制的比例，不限制时返回0
+func (s CgroupStats) MemoryRatio() float64 {
+	if s.MemoryLimit == 0 {
+		return 0
+	}
+	return float64(s.MemoryUsage) / float64(s.MemoryLimit)
+}
+
+// ReadCgroup 读取当前进程所在cgroup的CPU和内存，同时支持cgroup v1和v2。
+// 进程所在的cgroup由/proc/self/cgroup确定，对应的目录不存在时(如容器中看到的是宿主机的路径)读取cgroup根目录
+func ReadCgroup() (CgroupStats, error) {
+	paths := cgroupPaths()
+	if _, err := os.Stat(filepath.Join(cgroupRoot, "cgroup.controllers")); err == nil {
+		return readCgroupV2(cgroupDir(cgroupRoot, paths[""]))
+	}
+	if _, err := os.Stat(filepath.Join(cgroupRoot, "memory")); err == nil {
+		return readCgroupV1(
+			cgroupDir(filepath.Join(cgroupRoot, "cpu"), paths["cpu"]),
+			cgroupDir(filepath.Join(cgroupRoot, "cpuacct"), paths["cpuacct"]),
+			cgroupDir(filepath.Join(cgroupRoot, "memory"), paths["memory"]),
+		)
+	}
+	return CgroupStats{}, ErrNoCgroup
+}
+
+// cgroupPaths 解析/proc/self/cgroup中"hierarchy-ID:controller-list:cgroup-path"格式的行，
+// 返回controller对应的cgroup路径，cgroup v2的controller为空
+func cgroupPaths() map[string]string {
+	paths := make(map[string]string)
+	data, err := os.ReadFile(procSelfCgroup)
+	if err != nil {
+		return paths
+	}
+	for _, line := range strings.Split(string(data), "\n") {
+		parts := strings.SplitN(line, ":", 3)
+		if len(parts) != 3 {
+			continue
+		}
+		for _, controller := range strings.Split(parts[1], ",") {
+			paths[controller] = parts[2]
+		}
+	}
+	return paths
+}
+
+// cgroupDir 返回base下进程所在cgroup的目录，不存在时返回base
+func cgroupDir(base, path string) string {
+	if path == "" || path == "/" {
+		return base
+	}
+	dir := filepath.Join(base, path)
+	if _, err := os.Stat(dir); err != nil {
+		return base
+	}
+	return dir
+}
+
+func readCgroupV2(dir string) (CgroupStats, error) {
+	var stats CgroupStats
+	// cpu.max的格式为"$MAX $PERIOD"，$MAX为max表示不限制
+	if fields, err := readFields(filepath.Join(dir, "cpu.max")); err == nil && len(fields) == 2 && fields[0] != "max" {
+		quota, _ := strconv.ParseFloat(fields[0], 64)
+		period, _ := strconv.ParseFloat(fields[1], 64)
+		if period > 0 {
+			stats.CPULimit = quota / period
+		}
+	}
+	if data, err := os.ReadFile(filepath.Join(dir, "cpu.stat")); err == nil {
+		for _, line := range strings.Split(string(data), "\n") {
+			fields := strings.Fields(line)
+			if len(fields) == 2 && fields[0] == "usage_usec" {
+				usec, _ := strconv.ParseInt(fields[1], 10, 64)
+				stats.CPUUsage = time.Duration(usec) * time.Microsecond
+			}
+		}
+	}
+	usage, err := readUint(filepath.Join(dir, "memory.current"))
+	if err != nil {
+		return stats, err
+	}
+	stats.MemoryUsage = usage
+	// memory.max为max表示不限制，readUint返回错误
+	stats.MemoryLimit, _ = readUint(filepath.Join(dir, "memory.max"))
+	return stats, nil
+}
+
+func readCgroupV1(cpuDir, cpuacctDir, memoryDir string) (CgroupStats, error) {
+	var stats CgroupStats
+	quota, qerr := readInt(filepath.Join(cpuDir, "cpu.cfs_quota_us"))
+	period, perr := readInt(filepath.Join(cpuDir, "cpu.cfs_period_us"))
+	if qerr == nil && perr == nil && quota > 0 && period > 0 {
+		stats.CPULimit = float64(quota) / float64(period)
+	}
+	if usage, err := readUint(filepath.Join(cpuacctDir, "cpuacct.usage")); err == nil {
+		stats.CPUUsage = time.Duration(usage)
+	}
+	usage, err := readUint(filepath.Join(memoryDir, "memory.usage_in_bytes"))
+	if err != nil {
+		return stats, err
+	}
+	stats.MemoryUsage = usage
+	// 不限制时为一个接近int64最大值的数
+	if limit, err := readUint(filepath.Join(memoryDir, "memory.limit_in_bytes")); err == nil && limit < 1<<62 {
+		stats.MemoryLimit = limit
+	}
+	return stats, nil
+}
+
+func readFields(path string) ([]string, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	return strings.Fields(string(data)), nil
+}
+
+func readUint(path string) (uint64, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return 0, err
+	}
+	return strconv.ParseUint(strings.TrimSpace(string(data)), 10, 64)
+}
+
+func readInt(path string) (int64, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return 0, err
+	}
+	return strconv.ParseInt(strings.TrimSpace(string(data)), 10, 64)
+}
+
+type cgroupCollector struct {
+	cpuLimit    *prometheus.Desc
+	cpuUsage    *prometheus.Desc
+	memoryUsage *prometheus.Desc
+	memoryLimit *prometheus.Desc
+}
+
+// NewCgroupCollector 返回采集cgroup CPU和内存用量及限制的collector，不在cgroup中时不输出指标
+func NewCgroupCollector() prometheus.Collector {
+	return &cgroupCollector{
+		cpuLimit:    prometheus.NewDesc("cgroup_cpu_limit_cores", "CPU limit of the cgroup in cores, 0 if unlimited.", nil, nil),
+		cpuUsage:    prometheus.NewDesc("cgroup_cpu_usage_seconds_total", "Total CPU time consumed by the cgroup.", nil, nil),
+		memoryUsage: prometheus.NewDesc("cgroup_memory_usage_bytes", "Memory usage of the cgroup.", nil, nil),
+		memoryLimit: prometheus.NewDesc("cgroup_memory_limit_bytes", "Memory limit of the cgroup, 0 if unlimited.", nil, nil),
+	}
+}
+
+func (c *cgroupCollector) Describe(ch chan<- *prometheus.Desc) {
+	ch <- c.cpuLimit
+	ch <- c.cpuUsage
+	ch <- c.memoryUsage
+	ch <- c.memoryLimit
+}
+
+func (c *cgroupCollector) Collect(ch chan<- prometheus.Metric) {
+	stats, err := ReadCgroup()
+	if err != nil {
+		return
+	}
+	ch <- prometheus.MustNewConstMetric(c.cpuLimit, prometheus.GaugeValue, stats.CPULimit)
+	ch <- prometheus.MustNewConstMetric(c.cpuUsage, prometheus.CounterValue, stats.CPUUsage.Seconds())
+	ch <- prometheus.MustNewConstMetric(c.memoryUsage, prometheus.GaugeValue, float64(stats.MemoryUsage))
+	ch <- prometheus.MustNewConstMetric(c.memoryLimit, prometheus.GaugeValue, float64(stats.MemoryLimit))
+}
@@ -0,0 +1,120 @@
+package metric
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func writeFiles(t *testing.T, root string, files map[string]string) {
+	for name, content := range files {
+		path := filepath.Join(root, name)
+		assert.Nil(t, os.MkdirAll(filepath.Dir(path), 0755))
+		assert.Nil(t, os.WriteFile(path, []byte(content), 0644))
+	}
+}
+
+// withCgroupRoot 使用临时目录作为cgroup根目录，files中的"self"作为/proc/self/cgroup
+func withCgroupRoot(t *testing.T, files map[string]string) {
+	root := t.TempDir()
+	writeFiles(t, root, files)
+	oldRoot, oldSelf := cgroupRoot, procSelfCgroup
+	cgroupRoot, procSelfCgroup = root, filepath.Join(root, "self")
+	t.Cleanup(func() { cgroupRoot, procSelfCgroup = oldRoot, oldSelf })
+}
+
+func TestReadCgroupV2(t *testing.T) {
+	withCgroupRoot(t, map[string]string{
+		"cgroup.controllers": "cpu memory",
+		"cpu.max":            "200000 100000\n",
+		"cpu.stat":           "usage_usec 1500000\nuser_usec 1000000\n",
+		"memory.current":     "104857600\n",
+		"memory.max":         "209715200\n",
+	})
+	stats, err := ReadCgroup()
+	assert.Nil(t, err)
+	assert.Equal(t, CgroupStats{
+		CPULimit:    2,
+		CPUUsage:    1500 * time.Millisecond,
+		MemoryUsage: 100 << 20,
+		MemoryLimit: 200 << 20,
+	}, stats)
+	assert.Equal(t, 0.5, stats.MemoryRatio())
+}
+
+func TestReadCgroupV2Unlimited(t *testing.T) {
+	withCgroupRoot(t, map[string]string{
+		"cgroup.controllers": "cpu memory",
+		"cpu.max":            "max 100000\n",
+		"memory.current":     "1024\n",
+		"memory.max":         "max\n",
+	})
+	stats, err := ReadCgroup()
+	assert.Nil(t, err)
+	assert.Equal(t, float64(0), stats.CPULimit)
+	assert.Equal(t, uint64(0), stats.MemoryLimit)
+	assert.Equal(t, float64(0), stats.MemoryRatio())
+}
+
+func TestReadCgroupV1(t *testing.T) {
+	withCgroupRoot(t, map[string]string{
+		"cpu/cpu.cfs_quota_us":         "50000\n",
+		"cpu/cpu.cfs_period_us":        "100000\n",
+		"cpuacct/cpuacct.usage":        "2000000000\n",
+		"memory/memory.usage_in_bytes": "1048576\n",
+		"memory/memory.limit_in_bytes": "9223372036854771712\n",
+	})
+	stats, err := ReadCgroup()
+	assert.Nil(t, err)
+	assert.Equal(t, CgroupStats{CPULimit: 0.5, CPUUsage: 2 * time.Second, MemoryUsage: 1 << 20}, stats)
+}
+
+func TestReadCgroupV2Nested(t *testing.T) {
+	withCgroupRoot(t, map[string]string{
+		"self":               "0::/system.slice/app.service\n",
+		"cgroup.controllers": "cpu memory",
+		// 根目录没有memory.current
+		"system.slice/app.service/cpu.max":        "100000 100000\n",
+		"system.slice/app.service/memory.current": "2048\n",
+		"system.slice/app.service/memory.max":     "4096\n",
+	})
+	stats, err := ReadCgroup()
+	assert.Nil(t, err)
+	assert.Equal(t, CgroupStats{CPULimit: 1, MemoryUsage: 2048, MemoryLimit: 4096}, stats)
+}
+
+func TestReadCgroupV2PathNotFound(t *testing.T) {
+	// 容器中看到的是宿主机上的路径，退回到根目录
+	withCgroupRoot(t, map[string]string{
+		"self":               "0::/kubepods/pod1/container1\n",
+		"cgroup.controllers": "cpu memory",
+		"memory.current":     "1024\n",
+	})
+	stats, err := ReadCgroup()
+	assert.Nil(t, err)
+	assert.Equal(t, uint64(1024), stats.MemoryUsage)
+}
+
+func TestReadCgroupV1Nested(t *testing.T) {
+	withCgroupRoot(t, map[string]string{
+		"self":                             "5:memory:/app\n4:cpu,cpuacct:/app\n1:name=systemd:/app\n",
+		"cpu/app/cpu.cfs_quota_us":         "200000\n",
+		"cpu/app/cpu.cfs_period_us":        "100000\n",
+		"cpuacct/app/cpuacct.usage":        "1000000000\n",
+		"memory/memory.usage_in_bytes":     "1\n",
+		"memory/app/memory.usage_in_bytes": "2048\n",
+		"memory/app/memory.limit_in_bytes": "4096\n",
+	})
+	stats, err := ReadCgroup()
+	assert.Nil(t, err)
+	assert.Equal(t, CgroupStats{CPULimit: 2, CPUUsage: time.Second, MemoryUsage: 2048, MemoryLimit: 4096}, stats)
+}
+
+func TestReadCgroupMissing(t *testing.T) {
+	withCgroupRoot(t, nil)
+	_, err := ReadCgroup()
+	assert.Equal(t, ErrNoCgroup, err)
+}
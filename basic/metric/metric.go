@@ -1,83 +1,282 @@
 package metric
 
 import (
-	"fmt"
+	"context"
+	"errors"
 	"net/http"
-	"sort"
+	"sync"
 	"time"
 
-	"github.com/armon/go-metrics"
-	prometheussink "github.com/armon/go-metrics/prometheus"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/collectors"
 	"github.com/prometheus/client_golang/prometheus/promhttp"
+	"github.com/prometheus/client_golang/prometheus/push"
+	dto "github.com/prometheus/client_model/go"
 	log "github.com/sirupsen/logrus"
-	"github.com/spf13/cast"
-	"github.com/spf13/viper"
 )
 
-type metricsFunc func(key []string, val float32, labels []metrics.Label)
+// 通过NewCounterVec等函数创建的指标保存在collectorSet中，Init时以服务的常量标签注册到服务的registry，
+// 因此可以在包初始化时创建指标，不需要等待Init
 
-// InitPrometheusMetrics 初始化Prometheus监控
-func InitPrometheusMetrics(serviceName, metricAddr string) {
+type Config struct {
+	// 服务名，作为常量标签service
+	ServiceName string
+	// 其他常量标签，如版本号、机房
+	ConstLabels prometheus.Labels
+	// 拉取模式的监听地址，为空时不监听
+	Addr string
+	// 是否采集go运行时指标
+	GoCollector bool
+	// 是否采集进程指标
+	ProcessCollector bool
+	// 是否采集runtime/metrics中的GC暂停、调度延迟等运行时指标和cgroup的CPU、内存
+	RuntimeCollector bool
+	// 推送模式，用于定时任务等短生命周期的程序
+	Push *PushConfig
+}
+
+type PushConfig struct {
+	// pushgateway地址
+	URL string
+	// job名，为空时使用服务名
+	Job string
+	// 推送周期，为0时只在Shutdown时推送一次
+	Interval time.Duration
+}
+
+// ShutdownFunc 停止监听，推送模式下会推送最后一次
+type ShutdownFunc func(ctx context.Context) error
+
+var (
+	set = &collectorSet{checker: prometheus.NewRegistry()}
+
+	gathererMtx sync.RWMutex
+	gatherer    prometheus.Gatherer
+)
+
+func init() {
+	// Init之前只有不带常量标签的指标
+	reg := prometheus.NewRegistry()
+	reg.MustRegister(set)
+	gatherer = prometheus.Gatherers{reg, prometheus.DefaultGatherer}
+}
+
+// collectorSet 是unchecked collector，可以在注册到registry后继续添加指标
+type collectorSet struct {
+	mtx        sync.RWMutex
+	checker    *prometheus.Registry // 用于检查指标名和标签是否冲突
+	collectors []prometheus.Collector
+}
 
-	prometheusOpts := prometheussink.PrometheusOpts{
-		Expiration: 60 * time.Second,
+func (s *collectorSet) register(c prometheus.Collector) error {
+	s.mtx.Lock()
+	defer s.mtx.Unlock()
+	if err := s.checker.Register(c); err != nil {
+		return err
 	}
+	s.collectors = append(s.collectors, c)
+	return nil
+}
 
-	if viper.GetBool("SCRAPE_SLOW") {
-		prometheusOpts.Expiration = 10 * time.Minute
+func (s *collectorSet) Describe(chan<- *prometheus.Desc) {}
+
+func (s *collectorSet) Collect(ch chan<- prometheus.Metric) {
+	s.mtx.RLock()
+	collectors := s.collectors
+	s.mtx.RUnlock()
+	for _, c := range collectors {
+		c.Collect(ch)
 	}
+}
 
-	sink, _ := prometheussink.NewPrometheusSinkFrom(prometheusOpts)
-	config := metrics.DefaultConfig(serviceName)
-	config.EnableHostname = false
-	config.EnableHostnameLabel = true
-	config.EnableRuntimeMetrics = false
-	metrics.NewGlobal(config, sink)
+// Register 注册自定义的collector
+func Register(c prometheus.Collector) error {
+	return set.register(c)
+}
 
-	go func() {
-		http.Handle("/metrics", promhttp.Handler())
-		log.Infof("Beginning to serve %s metrics on %s, expiration: %s", serviceName, metricAddr, prometheusOpts.Expiration)
-		log.Fatal(http.ListenAndServe(metricAddr, nil))
-	}()
+// MustRegister 同Register，失败时panic
+func MustRegister(cs ...prometheus.Collector) {
+	for _, c := range cs {
+		if err := set.register(c); err != nil {
+			panic(err)
+		}
+	}
+}
+
+func NewCounterVec(opts prometheus.CounterOpts, labelNames ...string) *prometheus.CounterVec {
+	c := prometheus.NewCounterVec(opts, labelNames)
+	MustRegister(c)
+	return c
 }
 
-// MapToMetricsLables map转换成Labels
-func MapToMetricsLables(maps map[string]interface{}) []metrics.Label {
+func NewGaugeVec(opts prometheus.GaugeOpts, labelNames ...string) *prometheus.GaugeVec {
+	g := prometheus.NewGaugeVec(opts, labelNames)
+	MustRegister(g)
+	return g
+}
 
-	labels := make([]metrics.Label, len(maps))
-	index := 0
-	for k, v := range maps {
-		labels[index].Name = k
-		sv, err := cast.ToStringE(v)
-		if err != nil {
-			labels[index].Value = fmt.Sprintf("%v", v)
-		} else {
-			labels[index].Value = sv
+// NewHistogramVec 创建直方图，opts.Buckets为空时使用prometheus.DefBuckets
+func NewHistogramVec(opts prometheus.HistogramOpts, labelNames ...string) *prometheus.HistogramVec {
+	h := prometheus.NewHistogramVec(opts, labelNames)
+	MustRegister(h)
+	return h
+}
+
+func NewSummaryVec(opts prometheus.SummaryOpts, labelNames ...string) *prometheus.SummaryVec {
+	s := prometheus.NewSummaryVec(opts, labelNames)
+	MustRegister(s)
+	return s
+}
+
+// Gatherer 返回Init创建的服务registry，包含prometheus默认registry中第三方库注册的指标
+func Gatherer() prometheus.Gatherer {
+	gathererMtx.RLock()
+	defer gathererMtx.RUnlock()
+	return gatherer
+}
+
+// Handler 返回拉取指标的http handler
+func Handler() http.Handler {
+	return promhttp.HandlerFor(prometheus.GathererFunc(func() ([]*dto.MetricFamily, error) {
+		return Gatherer().Gather()
+	}), promhttp.HandlerOpts{})
+}
+
+// Init 创建带有常量标签的服务registry，并按配置启动拉取或推送
+func Init(cfg Config) (ShutdownFunc, error) {
+	labels := prometheus.Labels{}
+	for k, v := range cfg.ConstLabels {
+		labels[k] = v
+	}
+	if cfg.ServiceName != "" {
+		labels["service"] = cfg.ServiceName
+	}
+
+	reg := prometheus.NewRegistry()
+	wrapped := prometheus.WrapRegistererWith(labels, reg)
+	if err := wrapped.Register(set); err != nil {
+		return nil, err
+	}
+	if cfg.GoCollector {
+		if err := wrapped.Register(collectors.NewGoCollector()); err != nil {
+			return nil, err
 		}
-		index++
 	}
+	if cfg.ProcessCollector {
+		if err := wrapped.Register(collectors.NewProcessCollector(collectors.ProcessCollectorOpts{})); err != nil {
+			return nil, err
+		}
+	}
+	if cfg.RuntimeCollector {
+		if err := wrapped.Register(NewRuntimeCollector()); err != nil {
+			return nil, err
+		}
+		if err := wrapped.Register(NewCgroupCollector()); err != nil {
+			return nil, err
+		}
+	}
+	// 默认registry的go和进程指标由上面的开关控制
+	prometheus.Unregister(collectors.NewGoCollector())
+	prometheus.Unregister(collectors.NewProcessCollector(collectors.ProcessCollectorOpts{}))
 
-	sort.Slice(labels[:], func(i, j int) bool {
-		return labels[i].Name < labels[j].Name
-	})
+	g := prometheus.Gatherers{reg, prometheus.DefaultGatherer}
+	gathererMtx.Lock()
+	gatherer = g
+	gathererMtx.Unlock()
+
+	var shutdowns []ShutdownFunc
+	if cfg.Addr != "" {
+		shutdowns = append(shutdowns, serve(cfg.ServiceName, cfg.Addr))
+	}
+	if cfg.Push != nil {
+		shutdown, err := startPush(cfg, g)
+		if err != nil {
+			return nil, err
+		}
+		shutdowns = append(shutdowns, shutdown)
+	}
+	return func(ctx context.Context) error {
+		var firstErr error
+		for _, shutdown := range shutdowns {
+			if err := shutdown(ctx); err != nil && firstErr == nil {
+				firstErr = err
+			}
+		}
+		return firstErr
+	}, nil
+}
 
-	return labels
+func serve(serviceName, addr string) ShutdownFunc {
+	mux := http.NewServeMux()
+	mux.Handle("/metrics", Handler())
+	srv := &http.Server{Addr: addr, Handler: mux}
+	go func() {
+		log.Infof("Beginning to serve %s metrics on %s", serviceName, addr)
+		if err := srv.ListenAndServe(); err != nil && err != http.ErrServerClosed {
+			log.Fatal(err)
+		}
+	}()
+	return srv.Shutdown
 }
 
-// PairsToMetricsLables 通过传入的kv返回一组标签
-// len(kv)必须为偶数，传入参数格式为key, value交替出现，且key类型必须为string
-func PairsToMetricsLables(kv ...interface{}) []metrics.Label {
-	if len(kv)%2 == 1 {
-		panic(fmt.Sprintf("metrics: Pairs got the odd number of input pairs for metrics: %d", len(kv)))
+func startPush(cfg Config, g prometheus.Gatherer) (ShutdownFunc, error) {
+	if cfg.Push.URL == "" {
+		return nil, errors.New("metric: empty pushgateway url")
+	}
+	job := cfg.Push.Job
+	if job == "" {
+		job = cfg.ServiceName
 	}
-	maps := map[string]interface{}{}
-	var key string
-	for i, s := range kv {
-		if i%2 == 0 {
-			key = s.(string)
-			continue
+	if job == "" {
+		return nil, errors.New("metric: empty push job name")
+	}
+	pusher := push.New(cfg.Push.URL, job).Gatherer(g)
+
+	stop := make(chan struct{})
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		if cfg.Push.Interval <= 0 {
+			<-stop
+			return
+		}
+		ticker := time.NewTicker(cfg.Push.Interval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-ticker.C:
+				if err := pusher.Push(); err != nil {
+					log.Errorf("push metrics to %s failed, err: %v", cfg.Push.URL, err)
+				}
+			case <-stop:
+				return
+			}
 		}
-		maps[key] = s
+	}()
+
+	var once sync.Once
+	return func(ctx context.Context) error {
+		var err error
+		once.Do(func() {
+			close(stop)
+			<-done
+			err = pusher.PushContext(ctx)
+		})
+		return err
+	}, nil
+}
+
+// InitPrometheusMetrics 初始化Prometheus监控，并在metricAddr上提供拉取接口
+func InitPrometheusMetrics(serviceName, metricAddr string) ShutdownFunc {
+	shutdown, err := Init(Config{
+		ServiceName:      serviceName,
+		Addr:             metricAddr,
+		GoCollector:      true,
+		ProcessCollector: true,
+		RuntimeCollector: true,
+	})
+	if err != nil {
+		log.Fatalf("init %s metrics failed, err: %v", serviceName, err)
 	}
-	return MapToMetricsLables(maps)
+	return shutdown
 }
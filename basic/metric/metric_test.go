@@ -0,0 +1,59 @@
+package metric
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"github.com/stretchr/testify/assert"
+)
+
+var testCounter = NewCounterVec(prometheus.CounterOpts{Name: "test_events_total", Help: "Test events."}, "kind")
+
+func TestInitConstLabels(t *testing.T) {
+	_, err := Init(Config{ServiceName: "demo", ConstLabels: prometheus.Labels{"version": "v1"}})
+	assert.Nil(t, err)
+
+	// Init之后创建的指标同样带有常量标签
+	later := NewGaugeVec(prometheus.GaugeOpts{Name: "test_later", Help: "Created after init."})
+	later.WithLabelValues().Set(2)
+	testCounter.WithLabelValues("a").Inc()
+
+	expected := `
+# HELP test_events_total Test events.
+# TYPE test_events_total counter
+test_events_total{kind="a",service="demo",version="v1"} 1
+# HELP test_later Created after init.
+# TYPE test_later gauge
+test_later{service="demo",version="v1"} 2
+`
+	assert.Nil(t, testutil.GatherAndCompare(Gatherer(), strings.NewReader(expected), "test_events_total", "test_later"))
+
+	// 重复注册
+	assert.NotNil(t, Register(prometheus.NewCounter(prometheus.CounterOpts{Name: "test_events_total", Help: "dup"})))
+}
+
+func TestInitPush(t *testing.T) {
+	var body string
+	gateway := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		assert.Equal(t, "/metrics/job/batch", r.URL.Path)
+		data, _ := io.ReadAll(r.Body)
+		body = string(data)
+		w.WriteHeader(http.StatusOK)
+	}))
+	defer gateway.Close()
+
+	shutdown, err := Init(Config{ServiceName: "demo", Push: &PushConfig{URL: gateway.URL, Job: "batch"}})
+	assert.Nil(t, err)
+	testCounter.WithLabelValues("push").Inc()
+	assert.Nil(t, shutdown(context.Background()))
+	assert.Contains(t, body, "test_events_total")
+
+	_, err = Init(Config{Push: &PushConfig{URL: gateway.URL}})
+	assert.NotNil(t, err)
+}
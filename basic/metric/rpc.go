@@ -0,0 +1,130 @@
+package metric
+
+import (
+	"strings"
+	"sync/atomic"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/proto"
+)
+
+const (
+	RPCServer = "server"
+	RPCClient = "client"
+)
+
+// 消息大小的分桶，64B到16MB
+var sizeBuckets = prometheus.ExponentialBuckets(64, 4, 10)
+
+type rpcMetrics struct {
+	requests *prometheus.CounterVec
+	errors   *prometheus.CounterVec
+	duration *prometheus.HistogramVec
+	inFlight *prometheus.GaugeVec
+	received *prometheus.HistogramVec
+	sent     *prometheus.HistogramVec
+}
+
+func newRPCMetrics(side string) *rpcMetrics {
+	subsystem := "rpc_" + side
+	return &rpcMetrics{
+		requests: NewCounterVec(prometheus.CounterOpts{
+			Subsystem: subsystem, Name: "requests_total", Help: "Total number of RPCs completed.",
+		}, "grpc_service", "grpc_method", "code"),
+		errors: NewCounterVec(prometheus.CounterOpts{
+			Subsystem: subsystem, Name: "errors_total", Help: "Total number of RPCs completed with a non-OK code.",
+		}, "grpc_service", "grpc_method", "code"),
+		duration: NewHistogramVec(prometheus.HistogramOpts{
+			Subsystem: subsystem, Name: "duration_seconds", Help: "Latency of RPCs.",
+			Buckets: []float64{.001, .005, .01, .025, .05, .1, .25, .5, 1, 2.5, 5, 10},
+		}, "grpc_service", "grpc_method", "code"),
+		inFlight: NewGaugeVec(prometheus.GaugeOpts{
+			Subsystem: subsystem, Name: "in_flight", Help: "Number of RPCs in flight.",
+		}, "grpc_service", "grpc_method"),
+		received: NewHistogramVec(prometheus.HistogramOpts{
+			Subsystem: subsystem, Name: "msg_received_bytes", Help: "Size of received messages.", Buckets: sizeBuckets,
+		}, "grpc_service", "grpc_method"),
+		sent: NewHistogramVec(prometheus.HistogramOpts{
+			Subsystem: subsystem, Name: "msg_sent_bytes", Help: "Size of sent messages.", Buckets: sizeBuckets,
+		}, "grpc_service", "grpc_method"),
+	}
+}
+
+var rpcSides = map[string]*rpcMetrics{
+	RPCServer: newRPCMetrics(RPCServer),
+	RPCClient: newRPCMetrics(RPCClient),
+}
+
+// RPCRecorder 记录一次grpc调用的RED指标：请求数、按错误码统计的错误数、耗时，以及处理中的请求数和消息大小，
+// 标签为grpc_service、grpc_method和code。常量标签service已被用作服务名
+type RPCRecorder struct {
+	metrics  *rpcMetrics
+	service  string
+	method   string
+	start    time.Time
+	finished int32
+}
+
+// StartRPC 开始记录一次调用，side为RPCServer或RPCClient，调用结束时必须调用Done
+func StartRPC(side, fullMethod string) *RPCRecorder {
+	service, method := SplitMethodName(fullMethod)
+	r := &RPCRecorder{
+		metrics: rpcSides[side],
+		service: service,
+		method:  method,
+		start:   time.Now(),
+	}
+	r.metrics.inFlight.WithLabelValues(service, method).Inc()
+	return r
+}
+
+// MsgReceived 记录收到的消息大小，非proto消息忽略
+func (r *RPCRecorder) MsgReceived(msg interface{}) {
+	r.observeSize(r.metrics.received, msg)
+}
+
+// MsgSent 记录发送的消息大小，非proto消息忽略
+func (r *RPCRecorder) MsgSent(msg interface{}) {
+	r.observeSize(r.metrics.sent, msg)
+}
+
+func (r *RPCRecorder) observeSize(h *prometheus.HistogramVec, msg interface{}) {
+	if m, ok := msg.(proto.Message); ok {
+		h.WithLabelValues(r.service, r.method).Observe(float64(proto.Size(m)))
+	}
+}
+
+// Done 按err对应的grpc错误码记录请求数、错误数和耗时，重复调用只记录一次
+func (r *RPCRecorder) Done(err error) {
+	if !atomic.CompareAndSwapInt32(&r.finished, 0, 1) {
+		return
+	}
+	r.metrics.inFlight.WithLabelValues(r.service, r.method).Dec()
+
+	code := errorCode(err).String()
+	r.metrics.requests.WithLabelValues(r.service, r.method, code).Inc()
+	if err != nil {
+		r.metrics.errors.WithLabelValues(r.service, r.method, code).Inc()
+	}
+	r.metrics.duration.WithLabelValues(r.service, r.method, code).Observe(time.Since(r.start).Seconds())
+}
+
+// errorCode 返回err对应的grpc错误码，context超时和取消转换为对应的错误码
+func errorCode(err error) codes.Code {
+	if s, ok := status.FromError(err); ok {
+		return s.Code()
+	}
+	return status.FromContextError(err).Code()
+}
+
+// SplitMethodName 将grpc的完整方法名/package.Service/Method拆分为服务名和方法名
+func SplitMethodName(fullMethod string) (string, string) {
+	fullMethod = strings.TrimPrefix(fullMethod, "/")
+	if i := strings.LastIndex(fullMethod, "/"); i >= 0 {
+		return fullMethod[:i], fullMethod[i+1:]
+	}
+	return "unknown", fullMethod
+}
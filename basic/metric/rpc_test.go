@@ -0,0 +1,53 @@
+package metric
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	dto "github.com/prometheus/client_model/go"
+	"github.com/stretchr/testify/assert"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/types/known/wrapperspb"
+)
+
+func sampleCount(t *testing.T, o prometheus.Observer) uint64 {
+	var m dto.Metric
+	assert.Nil(t, o.(prometheus.Metric).Write(&m))
+	return m.GetHistogram().GetSampleCount()
+}
+
+func TestSplitMethodName(t *testing.T) {
+	service, method := SplitMethodName("/pb.User/Get")
+	assert.Equal(t, "pb.User", service)
+	assert.Equal(t, "Get", method)
+
+	service, method = SplitMethodName("Get")
+	assert.Equal(t, "unknown", service)
+	assert.Equal(t, "Get", method)
+}
+
+func TestRPCRecorder(t *testing.T) {
+	m := rpcSides[RPCServer]
+
+	r := StartRPC(RPCServer, "/pb.Recorder/Get")
+	assert.Equal(t, float64(1), testutil.ToFloat64(m.inFlight.WithLabelValues("pb.Recorder", "Get")))
+	r.MsgReceived(wrapperspb.String("hello"))
+	r.MsgReceived("not a proto message")
+	r.Done(nil)
+	r.Done(errors.New("ignored"))
+
+	StartRPC(RPCServer, "/pb.Recorder/Get").Done(status.Error(codes.NotFound, "not found"))
+	StartRPC(RPCServer, "/pb.Recorder/Get").Done(context.DeadlineExceeded)
+
+	assert.Equal(t, float64(0), testutil.ToFloat64(m.inFlight.WithLabelValues("pb.Recorder", "Get")))
+	assert.Equal(t, float64(1), testutil.ToFloat64(m.requests.WithLabelValues("pb.Recorder", "Get", "OK")))
+	assert.Equal(t, float64(1), testutil.ToFloat64(m.requests.WithLabelValues("pb.Recorder", "Get", "NotFound")))
+	assert.Equal(t, float64(1), testutil.ToFloat64(m.errors.WithLabelValues("pb.Recorder", "Get", "DeadlineExceeded")))
+	assert.Equal(t, float64(0), testutil.ToFloat64(m.errors.WithLabelValues("pb.Recorder", "Get", "OK")))
+	assert.Equal(t, uint64(1), sampleCount(t, m.duration.WithLabelValues("pb.Recorder", "Get", "OK")))
+	assert.Equal(t, uint64(1), sampleCount(t, m.received.WithLabelValues("pb.Recorder", "Get")))
+}
@@ -0,0 +1,116 @@
+package metric
+
+import (
+	"math"
+	"runtime/metrics"
+
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+// runtimeCollector 通过runtime/metrics采集运行时指标，读取时不会像runtime.ReadMemStats一样stop the world
+type runtimeCollector struct {
+	samples []metrics.Sample
+	descs   []runtimeDesc
+}
+
+type runtimeDesc struct {
+	desc      *prometheus.Desc
+	valueType prometheus.ValueType // 直方图忽略该字段
+	histogram bool
+}
+
+var runtimeMetrics = []struct {
+	name      string
+	metric    string
+	help      string
+	valueType prometheus.ValueType
+	histogram bool
+}{
+	{"/sched/goroutines:goroutines", "runtime_goroutines", "Number of live goroutines.", prometheus.GaugeValue, false},
+	{"/memory/classes/heap/objects:bytes", "runtime_heap_objects_bytes", "Memory occupied by live and unswept heap objects.", prometheus.GaugeValue, false},
+	{"/memory/classes/total:bytes", "runtime_memory_total_bytes", "All memory mapped by the Go runtime.", prometheus.GaugeValue, false},
+	{"/gc/heap/allocs:bytes", "runtime_heap_allocs_bytes_total", "Cumulative bytes allocated to the heap.", prometheus.CounterValue, false},
+	{"/gc/cycles/total:gc-cycles", "runtime_gc_cycles_total", "Count of completed GC cycles.", prometheus.CounterValue, false},
+	{"/gc/pauses:seconds", "runtime_gc_pauses_seconds", "Distribution of individual GC-related stop-the-world pause latencies.", 0, true},
+	{"/sched/latencies:seconds", "runtime_sched_latencies_seconds", "Distribution of the time goroutines have spent in the scheduler in a runnable state.", 0, true},
+}
+
+// NewRuntimeCollector 返回采集goroutine数、堆内存、GC暂停和调度延迟的collector，当前go版本不支持的指标会被忽略
+func NewRuntimeCollector() prometheus.Collector {
+	supported := map[string]bool{}
+	for _, d := range metrics.All() {
+		supported[d.Name] = true
+	}
+	c := &runtimeCollector{}
+	for _, m := range runtimeMetrics {
+		if !supported[m.name] {
+			continue
+		}
+		c.samples = append(c.samples, metrics.Sample{Name: m.name})
+		c.descs = append(c.descs, runtimeDesc{
+			desc:      prometheus.NewDesc(m.metric, m.help, nil, nil),
+			valueType: m.valueType,
+			histogram: m.histogram,
+		})
+	}
+	return c
+}
+
+func (c *runtimeCollector) Describe(ch chan<- *prometheus.Desc) {
+	for _, d := range c.descs {
+		ch <- d.desc
+	}
+}
+
+func (c *runtimeCollector) Collect(ch chan<- prometheus.Metric) {
+	samples := make([]metrics.Sample, len(c.samples))
+	copy(samples, c.samples)
+	metrics.Read(samples)
+	for i, s := range samples {
+		d := c.descs[i]
+		switch s.Value.Kind() {
+		case metrics.KindUint64:
+			ch <- prometheus.MustNewConstMetric(d.desc, d.valueType, float64(s.Value.Uint64()))
+		case metrics.KindFloat64:
+			ch <- prometheus.MustNewConstMetric(d.desc, d.valueType, s.Value.Float64())
+		case metrics.KindFloat64Histogram:
+			count, sum, buckets := convertHistogram(s.Value.Float64Histogram(), latencyBuckets)
+			ch <- prometheus.MustNewConstHistogram(d.desc, count, sum, buckets)
+		}
+	}
+}
+
+// runtime/metrics的直方图有上百个桶，导出时合并为以下分桶
+var latencyBuckets = []float64{1e-6, 1e-5, 5e-5, 1e-4, 5e-4, 1e-3, 5e-3, 1e-2, 5e-2, 0.1, 0.5, 1}
+
+// convertHistogram 将runtime/metrics的直方图合并为prometheus的累计分桶，
+// 跨越分桶边界的桶计入更大的分桶，sum按每个桶的中点估算
+func convertHistogram(h *metrics.Float64Histogram, bounds []float64) (uint64, float64, map[float64]uint64) {
+	buckets := make(map[float64]uint64, len(bounds))
+	var count uint64
+	var sum float64
+	next := 0
+	for i, n := range h.Counts {
+		lower, upper := h.Buckets[i], h.Buckets[i+1]
+		for next < len(bounds) && upper > bounds[next] {
+			buckets[bounds[next]] = count
+			next++
+		}
+		count += n
+		if n == 0 {
+			continue
+		}
+		switch {
+		case math.IsInf(lower, -1):
+			sum += upper * float64(n)
+		case math.IsInf(upper, 1):
+			sum += lower * float64(n)
+		default:
+			sum += (lower + upper) / 2 * float64(n)
+		}
+	}
+	for ; next < len(bounds); next++ {
+		buckets[bounds[next]] = count
+	}
+	return count, sum, buckets
+}
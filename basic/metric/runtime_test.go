@@ -0,0 +1,29 @@
+package metric
+
+import (
+	"math"
+	"runtime/metrics"
+	"testing"
+
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestConvertHistogram(t *testing.T) {
+	h := &metrics.Float64Histogram{
+		Counts:  []uint64{1, 2, 3, 4},
+		Buckets: []float64{math.Inf(-1), 1, 2, 4, math.Inf(1)},
+	}
+	count, sum, buckets := convertHistogram(h, []float64{1, 3, 4, 10})
+	assert.Equal(t, uint64(10), count)
+	assert.Equal(t, 1*1+2*1.5+3*3+4*4.0, sum)
+	// (2,4]跨越了3，计入4
+	assert.Equal(t, map[float64]uint64{1: 1, 3: 3, 4: 6, 10: 6}, buckets)
+}
+
+func TestRuntimeCollector(t *testing.T) {
+	problems, err := testutil.CollectAndLint(NewRuntimeCollector())
+	assert.Nil(t, err)
+	assert.Empty(t, problems)
+	assert.Equal(t, len(runtimeMetrics), testutil.CollectAndCount(NewRuntimeCollector()))
+}
@@ -0,0 +1,133 @@
+package metric
+
+import (
+	"bytes"
+	"runtime"
+	"runtime/metrics"
+	"runtime/pprof"
+	"sync"
+	"time"
+
+	log "github.com/sirupsen/logrus"
+)
+
+const (
+	defaultWatchdogInterval = 10 * time.Second
+	defaultWatchdogCooldown = 10 * time.Minute
+	// 日志中goroutine dump的最大长度
+	maxGoroutineDumpLen = 256 << 10
+)
+
+type WatchdogConfig struct {
+	// 检查周期，默认10s
+	Interval time.Duration
+	// goroutine数超过该值时告警，0表示不检查
+	MaxGoroutines int
+	// 堆内存超过该值(字节)时告警，0表示不检查
+	MaxHeapBytes uint64
+	// 两次告警的最小间隔，避免持续超过阈值时刷屏，默认10min
+	Cooldown time.Duration
+	// 告警回调，在打印日志之后调用
+	OnAlert func(WatchdogEvent)
+}
+
+type WatchdogEvent struct {
+	Reason     string // goroutines或heap
+	Goroutines int
+	HeapBytes  uint64
+	Time       time.Time
+}
+
+// Watchdog 定期检查goroutine数和堆内存，超过阈值时打印按调用栈聚合的goroutine dump，用于发现goroutine泄漏
+type Watchdog struct {
+	cfg WatchdogConfig
+
+	lastAlert time.Time
+	stopOnce  sync.Once
+	stop      chan struct{}
+	done      chan struct{}
+}
+
+func NewWatchdog(cfg WatchdogConfig) *Watchdog {
+	if cfg.Interval <= 0 {
+		cfg.Interval = defaultWatchdogInterval
+	}
+	if cfg.Cooldown <= 0 {
+		cfg.Cooldown = defaultWatchdogCooldown
+	}
+	return &Watchdog{
+		cfg:  cfg,
+		stop: make(chan struct{}),
+		done: make(chan struct{}),
+	}
+}
+
+// Start 在后台开始检查
+func (w *Watchdog) Start() {
+	go func() {
+		defer close(w.done)
+		ticker := time.NewTicker(w.cfg.Interval)
+		defer ticker.Stop()
+		for {
+			select {
+			case now := <-ticker.C:
+				w.check(now)
+			case <-w.stop:
+				return
+			}
+		}
+	}()
+}
+
+// Stop 停止检查，需要在Start之后调用
+func (w *Watchdog) Stop() {
+	w.stopOnce.Do(func() {
+		close(w.stop)
+		<-w.done
+	})
+}
+
+func (w *Watchdog) check(now time.Time) {
+	event := WatchdogEvent{
+		Goroutines: runtime.NumGoroutine(),
+		HeapBytes:  heapObjectBytes(),
+		Time:       now,
+	}
+	switch {
+	case w.cfg.MaxGoroutines > 0 && event.Goroutines > w.cfg.MaxGoroutines:
+		event.Reason = "goroutines"
+	case w.cfg.MaxHeapBytes > 0 && event.HeapBytes > w.cfg.MaxHeapBytes:
+		event.Reason = "heap"
+	default:
+		return
+	}
+	if !w.lastAlert.IsZero() && now.Sub(w.lastAlert) < w.cfg.Cooldown {
+		return
+	}
+	w.lastAlert = now
+
+	log.Warnf("watchdog: %s exceeds threshold, goroutines: %d/%d, heap: %d/%d bytes, goroutine dump:\n%s",
+		event.Reason, event.Goroutines, w.cfg.MaxGoroutines, event.HeapBytes, w.cfg.MaxHeapBytes, goroutineDump())
+	if w.cfg.OnAlert != nil {
+		w.cfg.OnAlert(event)
+	}
+}
+
+func heapObjectBytes() uint64 {
+	sample := []metrics.Sample{{Name: "/memory/classes/heap/objects:bytes"}}
+	metrics.Read(sample)
+	if sample[0].Value.Kind() != metrics.KindUint64 {
+		return 0
+	}
+	return sample[0].Value.Uint64()
+}
+
+// goroutineDump 返回按调用栈聚合的goroutine dump，相同调用栈的goroutine只输出一次并带有数量
+func goroutineDump() string {
+	var buf bytes.Buffer
+	pprof.Lookup("goroutine").WriteTo(&buf, 1)
+	if buf.Len() > maxGoroutineDumpLen {
+		return string(buf.Bytes()[:maxGoroutineDumpLen]) + "\n...(truncated)"
+	}
+	return buf.String()
+}
@@ -0,0 +1,33 @@
+package metric
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestWatchdog(t *testing.T) {
+	var events []WatchdogEvent
+	w := NewWatchdog(WatchdogConfig{
+		MaxGoroutines: 1,
+		Cooldown:      time.Minute,
+		OnAlert: func(e WatchdogEvent) {
+			events = append(events, e)
+		},
+	})
+	now := time.Now()
+	w.check(now)
+	// 冷却时间内不重复告警
+	w.check(now.Add(time.Second))
+	w.check(now.Add(2 * time.Minute))
+	assert.Len(t, events, 2)
+	assert.Equal(t, "goroutines", events[0].Reason)
+	assert.True(t, events[0].Goroutines > 1)
+
+	// 未超过阈值
+	w = NewWatchdog(WatchdogConfig{MaxGoroutines: 1 << 20, MaxHeapBytes: 1 << 40, OnAlert: func(WatchdogEvent) {
+		t.Fatal("unexpected alert")
+	}})
+	w.check(now)
+}
@@ -1,6 +1,7 @@
 package nsq
 
 import (
+	"context"
 	"fmt"
 
 	"github.com/nsqio/go-nsq"
@@ -16,6 +17,7 @@ type Consumer interface {
 	SetMap(options map[string]interface{})
 	Set(option string, value interface{})
 	Start(handler nsq.Handler) error
+	StartWithContext(handler ContextHandler) error
 	Stop() error
 	NsqConsumer() *nsq.Consumer
 }
@@ -114,7 +116,29 @@ func (c *consumer) Set(option string, value interface{}) {
 }
 
 // Start consumer with `handler`.
+// 消息信封会被去掉，handler收到的message.Body总是原始body
 func (c *consumer) Start(handler nsq.Handler) error {
+	return c.start(nsq.HandlerFunc(func(message *nsq.Message) error {
+		_, body, err := Decode(message.Body)
+		if err != nil {
+			logrus.Warnf("decode nsq message %s failed: %v", message.ID, err)
+		}
+		message.Body = body
+		return handler.HandleMessage(message)
+	}))
+}
+
+// StartWithContext 启动消费者，handler收到的ctx中带有consumer span和requestid
+func (c *consumer) StartWithContext(handler ContextHandler) error {
+	return c.start(nsq.HandlerFunc(func(message *nsq.Message) error {
+		ctx, span := extractMessage(context.Background(), c.topic, message)
+		err := handler.HandleMessage(ctx, message)
+		finishSpan(span, err)
+		return err
+	}))
+}
+
+func (c *consumer) start(handler nsq.Handler) error {
 	if c.err != nil {
 		return c.err
 	}
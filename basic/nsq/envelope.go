@@ -0,0 +1,78 @@
+package nsq
+
+import (
+	"bytes"
+	"encoding/binary"
+	"encoding/json"
+	"errors"
+)
+
+// 消息信封格式：magic(4) + version(1) + header长度(4, 大端) + header(json) + body
+// 没有magic前缀的消息视为旧格式，整个消息就是body，因此新旧生产者、消费者可以共存。
+var envelopeMagic = []byte{0x00, 'S', 'G', 'H'}
+
+const (
+	envelopeVersion   byte = 1
+	envelopeHeaderLen      = 4 + 1 + 4
+	maxHeaderSize          = 64 << 10
+)
+
+var ErrInvalidEnvelope = errors.New("nsq: invalid message envelope")
+
+// Header 随消息传递的元数据，例如调用链上下文、requestid和baggage
+type Header map[string]string
+
+// Set 实现opentracing.TextMapWriter
+func (h Header) Set(key, val string) {
+	h[key] = val
+}
+
+// ForeachKey 实现opentracing.TextMapReader
+func (h Header) ForeachKey(handler func(key, val string) error) error {
+	for k, v := range h {
+		if err := handler(k, v); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Encode 将header和body编码为信封格式，header为空时直接返回body
+func Encode(header Header, body []byte) ([]byte, error) {
+	if len(header) == 0 {
+		return body, nil
+	}
+	h, err := json.Marshal(header)
+	if err != nil {
+		return nil, err
+	}
+	if len(h) > maxHeaderSize {
+		return nil, errors.New("nsq: message header too large")
+	}
+	buf := make([]byte, envelopeHeaderLen, envelopeHeaderLen+len(h)+len(body))
+	copy(buf, envelopeMagic)
+	buf[4] = envelopeVersion
+	binary.BigEndian.PutUint32(buf[5:], uint32(len(h)))
+	buf = append(buf, h...)
+	buf = append(buf, body...)
+	return buf, nil
+}
+
+// Decode 解析信封格式的消息，旧格式的消息返回nil header和原始数据
+func Decode(data []byte) (Header, []byte, error) {
+	if !bytes.HasPrefix(data, envelopeMagic) {
+		return nil, data, nil
+	}
+	if len(data) < envelopeHeaderLen || data[4] != envelopeVersion {
+		return nil, data, ErrInvalidEnvelope
+	}
+	n := binary.BigEndian.Uint32(data[5:])
+	if n > maxHeaderSize || uint64(len(data)-envelopeHeaderLen) < uint64(n) {
+		return nil, data, ErrInvalidEnvelope
+	}
+	header := Header{}
+	if err := json.Unmarshal(data[envelopeHeaderLen:envelopeHeaderLen+int(n)], &header); err != nil {
+		return nil, data, ErrInvalidEnvelope
+	}
+	return header, data[envelopeHeaderLen+int(n):], nil
+}
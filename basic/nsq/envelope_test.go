@@ -0,0 +1,79 @@
+package nsq
+
+import (
+	"context"
+	"testing"
+
+	"github.com/nsqio/go-nsq"
+	"github.com/opentracing/opentracing-go"
+	"github.com/opentracing/opentracing-go/mocktracer"
+	"github.com/stretchr/testify/assert"
+	"google.golang.org/grpc/metadata"
+	"singer.com/basic/meta"
+)
+
+func TestEnvelope(t *testing.T) {
+	data, err := Encode(Header{"requestid": "req-1"}, []byte("hello"))
+	assert.Nil(t, err)
+	header, body, err := Decode(data)
+	assert.Nil(t, err)
+	assert.Equal(t, "req-1", header["requestid"])
+	assert.Equal(t, "hello", string(body))
+
+	// 旧格式的消息原样返回
+	header, body, err = Decode([]byte("plain message"))
+	assert.Nil(t, err)
+	assert.Nil(t, header)
+	assert.Equal(t, "plain message", string(body))
+
+	data, err = Encode(nil, []byte("no header"))
+	assert.Nil(t, err)
+	assert.Equal(t, "no header", string(data))
+
+	_, _, err = Decode(append(append([]byte{}, envelopeMagic...), 1, 0, 0, 1, 0))
+	assert.Equal(t, ErrInvalidEnvelope, err)
+}
+
+func TestMessageTracePropagation(t *testing.T) {
+	tracer := mocktracer.New()
+	old := opentracing.GlobalTracer()
+	opentracing.SetGlobalTracer(tracer)
+	defer opentracing.SetGlobalTracer(old)
+
+	parent := tracer.StartSpan("rpc")
+	ctx := opentracing.ContextWithSpan(context.Background(), parent)
+	ctx = metadata.NewIncomingContext(ctx, metadata.Pairs("requestid", "req-nsq", "authorization", "token"))
+	ctx, _ = meta.SetTenant(ctx, "tenant-1")
+
+	span, data, err := injectMessage(ctx, "test-topic", []byte("hello"))
+	assert.Nil(t, err)
+	span.Finish()
+
+	message := nsq.NewMessage(nsq.MessageID{}, data)
+	cctx, cspan := extractMessage(context.Background(), "test-topic", message)
+	cspan.Finish()
+	parent.Finish()
+
+	assert.Equal(t, "hello", string(message.Body))
+	assert.Equal(t, "req-nsq", meta.GetRequestId(cctx))
+	assert.Equal(t, "tenant-1", meta.Tenant(cctx))
+	md, _ := metadata.FromIncomingContext(cctx)
+	assert.Empty(t, md.Get("authorization"))
+	assert.Equal(t, cspan, opentracing.SpanFromContext(cctx))
+
+	spans := tracer.FinishedSpans()
+	assert.Len(t, spans, 3)
+	producer, consumer := spans[0], spans[1]
+	assert.Equal(t, "nsq.publish test-topic", producer.OperationName)
+	assert.Equal(t, "nsq.consume test-topic", consumer.OperationName)
+	assert.Equal(t, parent.(*mocktracer.MockSpan).SpanContext.TraceID, consumer.SpanContext.TraceID)
+	assert.Equal(t, producer.SpanContext.SpanID, consumer.ParentID)
+}
+
+func TestMessageWithoutEnvelope(t *testing.T) {
+	message := nsq.NewMessage(nsq.MessageID{}, []byte("legacy"))
+	ctx, span := extractMessage(context.Background(), "test-topic", message)
+	span.Finish()
+	assert.Equal(t, "legacy", string(message.Body))
+	assert.NotEmpty(t, meta.GetRequestId(ctx))
+}
@@ -1,6 +1,7 @@
 package nsq
 
 import (
+	"context"
 	"time"
 
 	"github.com/nsqio/go-nsq"
@@ -10,6 +11,9 @@ import (
 type Producer interface {
 	Publish(topic string, body []byte) error
 	DeferredPublish(topic string, delay time.Duration, body []byte) error
+	// PublishCtx 发布带有调用链上下文和requestid的消息，消费者需要使用Consumer.Start或StartWithContext解析
+	PublishCtx(ctx context.Context, topic string, body []byte) error
+	DeferredPublishCtx(ctx context.Context, topic string, delay time.Duration, body []byte) error
 	Stop()
 	NsqProduer() *nsq.Producer
 }
@@ -47,6 +51,26 @@ func (p *producer) DeferredPublish(topic string, delay time.Duration, body []byt
 	return p.server.DeferredPublish(topic, delay, body)
 }
 
+func (p *producer) PublishCtx(ctx context.Context, topic string, body []byte) error {
+	span, data, err := injectMessage(ctx, topic, body)
+	if err != nil {
+		return err
+	}
+	err = p.server.Publish(topic, data)
+	finishSpan(span, err)
+	return err
+}
+
+func (p *producer) DeferredPublishCtx(ctx context.Context, topic string, delay time.Duration, body []byte) error {
+	span, data, err := injectMessage(ctx, topic, body)
+	if err != nil {
+		return err
+	}
+	err = p.server.DeferredPublish(topic, delay, data)
+	finishSpan(span, err)
+	return err
+}
+
 func (p *producer) NsqProduer() *nsq.Producer {
 	return p.server
 }
@@ -0,0 +1,99 @@
+package nsq
+
+import (
+	"context"
+
+	"github.com/nsqio/go-nsq"
+	"github.com/opentracing/opentracing-go"
+	"github.com/opentracing/opentracing-go/ext"
+	opentracing_log "github.com/opentracing/opentracing-go/log"
+	"github.com/sirupsen/logrus"
+	"google.golang.org/grpc/metadata"
+	"singer.com/basic/log"
+	"singer.com/basic/meta"
+	"singer.com/basic/trace"
+)
+
+const (
+	componentTag = "nsq"
+)
+
+// ContextHandler 接收恢复了调用链和requestid的ctx
+type ContextHandler interface {
+	HandleMessage(ctx context.Context, message *nsq.Message) error
+}
+
+type ContextHandlerFunc func(ctx context.Context, message *nsq.Message) error
+
+func (f ContextHandlerFunc) HandleMessage(ctx context.Context, message *nsq.Message) error {
+	return f(ctx, message)
+}
+
+// injectMessage 创建producer span，并把调用链上下文、requestid和baggage写入消息头
+func injectMessage(ctx context.Context, topic string, body []byte) (opentracing.Span, []byte, error) {
+	tracer := trace.GlobalTracer()
+	var opts []opentracing.StartSpanOption
+	if parent := opentracing.SpanFromContext(ctx); parent != nil {
+		opts = append(opts, opentracing.ChildOf(parent.Context()))
+	}
+	opts = append(opts, ext.SpanKindProducer, opentracing.Tag{Key: string(ext.Component), Value: componentTag},
+		opentracing.Tag{Key: string(ext.MessageBusDestination), Value: topic})
+	span := tracer.StartSpan("nsq.publish "+topic, opts...)
+
+	header := Header{}
+	if err := tracer.Inject(span.Context(), opentracing.TextMap, header); err != nil && err != opentracing.ErrUnsupportedFormat {
+		logrus.Debugf("inject trace context into nsq message failed: %v", err)
+	}
+	// requestid、调试标记和baggage
+	for k, vals := range meta.PropagatedMetadata(ctx) {
+		header[k] = vals[0]
+	}
+	data, err := Encode(header, body)
+	if err != nil {
+		finishSpan(span, err)
+		return nil, nil, err
+	}
+	return span, data, nil
+}
+
+// extractMessage 解析消息头，把message.Body替换为原始body，
+// 返回带有consumer span、requestid和baggage的ctx，consumer span通过FollowsFrom关联producer span
+func extractMessage(ctx context.Context, topic string, message *nsq.Message) (context.Context, opentracing.Span) {
+	header, body, err := Decode(message.Body)
+	if err != nil {
+		logrus.Warnf("decode nsq message %s failed: %v", message.ID, err)
+	}
+	message.Body = body
+
+	tracer := trace.GlobalTracer()
+	opts := []opentracing.StartSpanOption{ext.SpanKindConsumer,
+		opentracing.Tag{Key: string(ext.Component), Value: componentTag},
+		opentracing.Tag{Key: string(ext.MessageBusDestination), Value: topic}}
+	if len(header) > 0 {
+		if sc, err := tracer.Extract(opentracing.TextMap, header); err == nil {
+			opts = append(opts, opentracing.FollowsFrom(sc))
+		}
+	}
+	span := tracer.StartSpan("nsq.consume "+topic, opts...)
+	ctx = opentracing.ContextWithSpan(ctx, span)
+
+	// 只恢复允许传递的key，调用链相关的header已经由tracer解析
+	md := metadata.MD{}
+	for k, v := range header {
+		md.Set(k, v)
+	}
+	ctx = metadata.NewIncomingContext(ctx, meta.PropagatedMetadata(metadata.NewIncomingContext(ctx, md)))
+	if meta.GetRequestId(ctx) == "" {
+		ctx = meta.GenerateContextTraceMetadata(ctx, topic)
+	}
+	ctx = log.NewContext(ctx, "nsq.consume "+topic)
+	return ctx, span
+}
+
+func finishSpan(span opentracing.Span, err error) {
+	if err != nil {
+		ext.Error.Set(span, true)
+		span.LogFields(opentracing_log.String("event", "error"), opentracing_log.String("message", err.Error()))
+	}
+	span.Finish()
+}
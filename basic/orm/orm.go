@@ -11,6 +11,7 @@ import (
 	"gorm.io/gorm/schema"
 	"gorm.io/plugin/dbresolver"
 	gormopentracing "gorm.io/plugin/opentracing"
+	"singer.com/basic/trace"
 )
 
 func OpenDB(opt ...Option) (*gorm.DB, error) {
@@ -96,7 +97,7 @@ func OpenDB(opt ...Option) (*gorm.DB, error) {
 	sqlDB.SetMaxOpenConns(opts.maxOpenConn)
 	sqlDB.SetMaxIdleConns(opts.maxIdleConns)
 	sqlDB.SetConnMaxLifetime(opts.maxLifetime)
-	err = db.Use(gormopentracing.New())
+	err = db.Use(gormopentracing.New(gormopentracing.WithTracer(trace.GlobalTracer())))
 	if err != nil {
 		logrus.Error("gormopentracing err ", err)
 	}
@@ -0,0 +1,380 @@
+package pprof
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"runtime"
+	"runtime/pprof"
+	"sort"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	log "github.com/sirupsen/logrus"
+	"singer.com/basic/metric"
+)
+
+const (
+	ProfileCPU       = "cpu"
+	ProfileHeap      = "heap"
+	ProfileGoroutine = "goroutine"
+	ProfileMutex     = "mutex"
+
+	profileSuffix     = ".pb.gz"
+	profileTimeFormat = "20060102T150405.000"
+	// 旧版本生成的文件名只精确到秒
+	legacyProfileTimeFormat = "20060102T150405"
+
+	defaultCPUDuration          = 10 * time.Second
+	defaultCheckInterval        = 10 * time.Second
+	defaultCaptureCooldown      = 5 * time.Minute
+	defaultMutexProfileFraction = 100
+)
+
+var (
+	ErrCaptureInProgress = errors.New("pprof: another capture is in progress")
+	ErrProfileNotFound   = errors.New("pprof: profile not found")
+)
+
+type CaptureConfig struct {
+	// 保存profile的目录
+	Dir string
+	// 采集的profile，默认cpu、heap、goroutine和mutex
+	Profiles []string
+	// cpu profile的采集时长，默认10s
+	CPUDuration time.Duration
+	// 最多保留的文件数，0表示不限制
+	MaxFiles int
+	// 文件保留时间，0表示不限制
+	MaxAge time.Duration
+
+	// 检查阈值的周期，默认10s
+	CheckInterval time.Duration
+	// cgroup CPU使用率超过该比例(如0.8)时采集，没有CPU限制时按核数计算，0表示不检查
+	CPUThreshold float64
+	// cgroup内存使用率超过该比例时采集，0表示不检查
+	MemoryThreshold float64
+	// 一个检查周期内的慢请求数超过该值时采集，0表示不检查
+	SlowRequestThreshold int
+	// 两次自动采集的最小间隔，默认5min
+	Cooldown time.Duration
+
+	// 定期采集的周期，0表示不定期采集。定期采集只采集时长为PeriodicCPUDuration的cpu profile和heap profile
+	PeriodicInterval time.Duration
+	// 定期采集的cpu profile时长，默认1s
+	PeriodicCPUDuration time.Duration
+}
+
+// Profile 已采集的profile文件
+type Profile struct {
+	Name    string    `json:"name"`
+	Type    string    `json:"type"`
+	Reason  string    `json:"reason"`
+	Size    int64     `json:"size"`
+	Created time.Time `json:"created"`
+}
+
+// Capturer 将profile保存到本地目录，支持按阈值自动采集、定期采集和手动采集
+type Capturer struct {
+	cfg CaptureConfig
+
+	capturing   int32
+	lastName    time.Time // 上一次采集文件名中的时间，保证文件名不重复
+	lastCapture time.Time
+	lastCPU     time.Duration
+	lastCheck   time.Time
+	readCgroup  func() (metric.CgroupStats, error)
+
+	stopOnce sync.Once
+	stop     chan struct{}
+	done     sync.WaitGroup
+}
+
+var slowRequests int64
+
+// RecordSlowRequest 记录一次慢请求，由服务端慢日志拦截器调用
+func RecordSlowRequest() {
+	atomic.AddInt64(&slowRequests, 1)
+}
+
+func NewCapturer(cfg CaptureConfig) (*Capturer, error) {
+	if cfg.Dir == "" {
+		return nil, errors.New("pprof: empty profile dir")
+	}
+	if err := os.MkdirAll(cfg.Dir, 0755); err != nil {
+		return nil, err
+	}
+	if len(cfg.Profiles) == 0 {
+		cfg.Profiles = []string{ProfileCPU, ProfileHeap, ProfileGoroutine, ProfileMutex}
+	}
+	for _, p := range cfg.Profiles {
+		if p != ProfileCPU && pprof.Lookup(p) == nil {
+			return nil, fmt.Errorf("pprof: unknown profile %q", p)
+		}
+		if p == ProfileMutex && runtime.SetMutexProfileFraction(-1) == 0 {
+			runtime.SetMutexProfileFraction(defaultMutexProfileFraction)
+		}
+	}
+	if cfg.CPUDuration <= 0 {
+		cfg.CPUDuration = defaultCPUDuration
+	}
+	if cfg.CheckInterval <= 0 {
+		cfg.CheckInterval = defaultCheckInterval
+	}
+	if cfg.Cooldown <= 0 {
+		cfg.Cooldown = defaultCaptureCooldown
+	}
+	if cfg.PeriodicCPUDuration <= 0 {
+		cfg.PeriodicCPUDuration = time.Second
+	}
+	return &Capturer{
+		cfg:        cfg,
+		readCgroup: metric.ReadCgroup,
+		stop:       make(chan struct{}),
+	}, nil
+}
+
+// Capture 立即采集配置的所有profile，cpu profile会阻塞CPUDuration
+func (c *Capturer) Capture(reason string) ([]Profile, error) {
+	return c.capture(reason, c.cfg.Profiles, c.cfg.CPUDuration)
+}
+
+func (c *Capturer) capture(reason string, profiles []string, cpuDuration time.Duration) ([]Profile, error) {
+	if !atomic.CompareAndSwapInt32(&c.capturing, 0, 1) {
+		return nil, ErrCaptureInProgress
+	}
+	defer atomic.StoreInt32(&c.capturing, 0)
+
+	reason = sanitizeReason(reason)
+	// 同一毫秒内多次采集时顺延1ms，避免覆盖之前的文件
+	now := time.Now().Truncate(time.Millisecond)
+	if !now.After(c.lastName) {
+		now = c.lastName.Add(time.Millisecond)
+	}
+	c.lastName = now
+	var captured []Profile
+	var firstErr error
+	for _, typ := range profiles {
+		name := fmt.Sprintf("%s-%s-%s%s", now.Format(profileTimeFormat), reason, typ, profileSuffix)
+		if err := c.writeProfile(filepath.Join(c.cfg.Dir, name), typ, cpuDuration); err != nil {
+			if firstErr == nil {
+				firstErr = fmt.Errorf("capture %s profile failed: %w", typ, err)
+			}
+			continue
+		}
+		if p, ok := c.stat(name); ok {
+			captured = append(captured, p)
+		}
+	}
+	if err := c.cleanup(); err != nil {
+		log.Errorf("clean up profiles failed, err: %v", err)
+	}
+	return captured, firstErr
+}
+
+func (c *Capturer) writeProfile(path, typ string, cpuDuration time.Duration) (err error) {
+	f, err := os.OpenFile(path, os.O_WRONLY|os.O_CREATE|os.O_EXCL, 0644)
+	if err != nil {
+		return err
+	}
+	defer func() {
+		if cerr := f.Close(); err == nil {
+			err = cerr
+		}
+		if err != nil {
+			os.Remove(path)
+		}
+	}()
+
+	if typ != ProfileCPU {
+		return pprof.Lookup(typ).WriteTo(f, 0)
+	}
+	if err := pprof.StartCPUProfile(f); err != nil {
+		return err
+	}
+	select {
+	case <-time.After(cpuDuration):
+	case <-c.stop:
+	}
+	pprof.StopCPUProfile()
+	return nil
+}
+
+// List 返回已采集的profile，按时间从新到旧排序
+func (c *Capturer) List() ([]Profile, error) {
+	entries, err := os.ReadDir(c.cfg.Dir)
+	if err != nil {
+		return nil, err
+	}
+	var profiles []Profile
+	for _, e := range entries {
+		if p, ok := c.stat(e.Name()); ok {
+			profiles = append(profiles, p)
+		}
+	}
+	sort.Slice(profiles, func(i, j int) bool {
+		if profiles[i].Created.Equal(profiles[j].Created) {
+			return profiles[i].Name > profiles[j].Name
+		}
+		return profiles[i].Created.After(profiles[j].Created)
+	})
+	return profiles, nil
+}
+
+// Open 打开已采集的profile，name必须是List返回的文件名
+func (c *Capturer) Open(name string) (*os.File, error) {
+	if _, ok := parseProfileName(name); !ok || filepath.Base(name) != name {
+		return nil, ErrProfileNotFound
+	}
+	f, err := os.Open(filepath.Join(c.cfg.Dir, name))
+	if os.IsNotExist(err) {
+		return nil, ErrProfileNotFound
+	}
+	return f, err
+}
+
+func (c *Capturer) stat(name string) (Profile, bool) {
+	p, ok := parseProfileName(name)
+	if !ok {
+		return Profile{}, false
+	}
+	info, err := os.Stat(filepath.Join(c.cfg.Dir, name))
+	if err != nil || info.IsDir() {
+		return Profile{}, false
+	}
+	p.Size = info.Size()
+	return p, true
+}
+
+// parseProfileName 解析 时间-原因-类型.pb.gz 形式的文件名
+func parseProfileName(name string) (Profile, bool) {
+	if !strings.HasSuffix(name, profileSuffix) {
+		return Profile{}, false
+	}
+	parts := strings.Split(strings.TrimSuffix(name, profileSuffix), "-")
+	if len(parts) != 3 {
+		return Profile{}, false
+	}
+	created, err := time.ParseInLocation(profileTimeFormat, parts[0], time.Local)
+	if err != nil {
+		if created, err = time.ParseInLocation(legacyProfileTimeFormat, parts[0], time.Local); err != nil {
+			return Profile{}, false
+		}
+	}
+	return Profile{Name: name, Type: parts[2], Reason: parts[1], Created: created}, true
+}
+
+// sanitizeReason 原因会作为文件名的一部分，只保留字母、数字和下划线
+func sanitizeReason(reason string) string {
+	reason = strings.Map(func(r rune) rune {
+		if r >= 'a' && r <= 'z' || r >= 'A' && r <= 'Z' || r >= '0' && r <= '9' || r == '_' {
+			return r
+		}
+		return '_'
+	}, reason)
+	if reason == "" {
+		return "manual"
+	}
+	return reason
+}
+
+// cleanup 按保留时间和数量删除旧的profile
+func (c *Capturer) cleanup() error {
+	if c.cfg.MaxFiles <= 0 && c.cfg.MaxAge <= 0 {
+		return nil
+	}
+	profiles, err := c.List()
+	if err != nil {
+		return err
+	}
+	cutoff := time.Now().Add(-c.cfg.MaxAge)
+	for i, p := range profiles {
+		if (c.cfg.MaxFiles > 0 && i >= c.cfg.MaxFiles) || (c.cfg.MaxAge > 0 && p.Created.Before(cutoff)) {
+			if err := os.Remove(filepath.Join(c.cfg.Dir, p.Name)); err != nil && !os.IsNotExist(err) {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// Start 在后台按阈值自动采集，并按PeriodicInterval定期采集
+func (c *Capturer) Start() {
+	if c.cfg.CPUThreshold > 0 || c.cfg.MemoryThreshold > 0 || c.cfg.SlowRequestThreshold > 0 {
+		c.loop(c.cfg.CheckInterval, func(now time.Time) {
+			if reason := c.check(now); reason != "" {
+				c.lastCapture = now
+				log.Warnf("pprof: %s exceeds threshold, capturing profiles", reason)
+				if _, err := c.Capture(reason); err != nil {
+					log.Errorf("pprof: capture profiles failed, err: %v", err)
+				}
+			}
+		})
+	}
+	if c.cfg.PeriodicInterval > 0 {
+		c.loop(c.cfg.PeriodicInterval, func(time.Time) {
+			_, err := c.capture("periodic", []string{ProfileCPU, ProfileHeap}, c.cfg.PeriodicCPUDuration)
+			if err != nil && err != ErrCaptureInProgress {
+				log.Errorf("pprof: periodic capture failed, err: %v", err)
+			}
+		})
+	}
+}
+
+func (c *Capturer) loop(interval time.Duration, fn func(time.Time)) {
+	c.done.Add(1)
+	go func() {
+		defer c.done.Done()
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+		for {
+			select {
+			case now := <-ticker.C:
+				fn(now)
+			case <-c.stop:
+				return
+			}
+		}
+	}()
+}
+
+// Stop 停止后台采集，正在进行的cpu profile会提前结束
+func (c *Capturer) Stop() {
+	c.stopOnce.Do(func() {
+		close(c.stop)
+		c.done.Wait()
+	})
+}
+
+// check 返回超过阈值的原因，没有超过或在冷却时间内时返回空字符串
+func (c *Capturer) check(now time.Time) string {
+	slow := atomic.SwapInt64(&slowRequests, 0)
+	stats, err := c.readCgroup()
+	var cpuRatio float64
+	if err == nil {
+		if !c.lastCheck.IsZero() && now.After(c.lastCheck) {
+			limit := stats.CPULimit
+			if limit <= 0 {
+				limit = float64(runtime.NumCPU())
+			}
+			cpuRatio = float64(stats.CPUUsage-c.lastCPU) / float64(now.Sub(c.lastCheck)) / limit
+		}
+		c.lastCPU, c.lastCheck = stats.CPUUsage, now
+	}
+
+	if !c.lastCapture.IsZero() && now.Sub(c.lastCapture) < c.cfg.Cooldown {
+		return ""
+	}
+	switch {
+	case c.cfg.CPUThreshold > 0 && cpuRatio > c.cfg.CPUThreshold:
+		return "cpu"
+	case c.cfg.MemoryThreshold > 0 && err == nil && stats.MemoryRatio() > c.cfg.MemoryThreshold:
+		return "memory"
+	case c.cfg.SlowRequestThreshold > 0 && slow > int64(c.cfg.SlowRequestThreshold):
+		return "slow_requests"
+	}
+	return ""
+}
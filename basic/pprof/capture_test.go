@@ -0,0 +1,123 @@
+package pprof
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"singer.com/basic/metric"
+)
+
+func TestCapture(t *testing.T) {
+	c, err := NewCapturer(CaptureConfig{
+		Dir:         t.TempDir(),
+		Profiles:    []string{ProfileCPU, ProfileHeap, ProfileGoroutine, ProfileMutex},
+		CPUDuration: 50 * time.Millisecond,
+	})
+	assert.Nil(t, err)
+
+	profiles, err := c.Capture("manual test/..")
+	assert.Nil(t, err)
+	assert.Len(t, profiles, 4)
+	for _, p := range profiles {
+		assert.Equal(t, "manual_test___", p.Reason)
+		assert.True(t, p.Size > 0)
+	}
+
+	listed, err := c.List()
+	assert.Nil(t, err)
+	assert.ElementsMatch(t, profiles, listed)
+
+	f, err := c.Open(profiles[0].Name)
+	assert.Nil(t, err)
+	f.Close()
+	_, err = c.Open("../" + profiles[0].Name)
+	assert.Equal(t, ErrProfileNotFound, err)
+	_, err = c.Open("20220101T000000-manual-cpu.pb.gz")
+	assert.Equal(t, ErrProfileNotFound, err)
+
+	_, err = NewCapturer(CaptureConfig{Dir: t.TempDir(), Profiles: []string{"unknown"}})
+	assert.NotNil(t, err)
+}
+
+func TestCaptureSameSecond(t *testing.T) {
+	c, err := NewCapturer(CaptureConfig{Dir: t.TempDir(), Profiles: []string{ProfileHeap}})
+	assert.Nil(t, err)
+	names := map[string]bool{}
+	for i := 0; i < 3; i++ {
+		profiles, err := c.Capture("manual")
+		assert.Nil(t, err)
+		assert.Len(t, profiles, 1)
+		names[profiles[0].Name] = true
+	}
+	// 连续的采集不会覆盖之前的文件
+	assert.Len(t, names, 3)
+	listed, err := c.List()
+	assert.Nil(t, err)
+	assert.Len(t, listed, 3)
+}
+
+func TestCaptureRetention(t *testing.T) {
+	dir := t.TempDir()
+	old := []string{
+		"20220101T000000-cpu-heap.pb.gz",
+		"20220102T000000-cpu-heap.pb.gz",
+		"not-a-profile.txt",
+	}
+	for _, name := range old {
+		assert.Nil(t, os.WriteFile(filepath.Join(dir, name), []byte("x"), 0644))
+	}
+	c, err := NewCapturer(CaptureConfig{Dir: dir, Profiles: []string{ProfileHeap}, MaxFiles: 2})
+	assert.Nil(t, err)
+	_, err = c.Capture("manual")
+	assert.Nil(t, err)
+
+	listed, err := c.List()
+	assert.Nil(t, err)
+	assert.Len(t, listed, 2)
+	assert.Equal(t, "manual", listed[0].Reason)
+	assert.Equal(t, "20220102T000000-cpu-heap.pb.gz", listed[1].Name)
+	_, err = os.Stat(filepath.Join(dir, "not-a-profile.txt"))
+	assert.Nil(t, err)
+}
+
+func TestCaptureCheck(t *testing.T) {
+	c, err := NewCapturer(CaptureConfig{
+		Dir:                  t.TempDir(),
+		CPUThreshold:         0.8,
+		MemoryThreshold:      0.9,
+		SlowRequestThreshold: 2,
+		Cooldown:             time.Minute,
+	})
+	assert.Nil(t, err)
+	stats := metric.CgroupStats{CPULimit: 2, MemoryLimit: 100}
+	c.readCgroup = func() (metric.CgroupStats, error) { return stats, nil }
+
+	now := time.Now()
+	assert.Equal(t, "", c.check(now))
+
+	// 10s内使用了18s CPU时间，2核限制下使用率为90%
+	now = now.Add(10 * time.Second)
+	stats.CPUUsage += 18 * time.Second
+	assert.Equal(t, "cpu", c.check(now))
+
+	now = now.Add(10 * time.Second)
+	stats.MemoryUsage = 95
+	assert.Equal(t, "memory", c.check(now))
+
+	// 冷却时间内不再采集
+	c.lastCapture = now
+	now = now.Add(10 * time.Second)
+	assert.Equal(t, "", c.check(now))
+
+	now = now.Add(time.Minute)
+	stats.MemoryUsage = 10
+	for i := 0; i < 3; i++ {
+		RecordSlowRequest()
+	}
+	assert.Equal(t, "slow_requests", c.check(now))
+	// 慢请求数每个周期重新计数
+	assert.Equal(t, "", c.check(now.Add(10*time.Second)))
+}
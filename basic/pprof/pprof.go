@@ -1,24 +1,129 @@
 package pprof
 
 import (
+	"crypto/subtle"
+	"encoding/json"
+	"io"
 	"net/http"
 	"net/http/pprof"
+	"strings"
 )
 
+type options struct {
+	token    string
+	capturer *Capturer
+}
+
+type Option func(*options)
+
+// WithToken 所有接口都需要携带 Authorization: Bearer token
+func WithToken(token string) Option {
+	return func(o *options) {
+		o.token = token
+	}
+}
+
+// WithCapturer 提供查看、下载和手动采集profile的接口
+func WithCapturer(c *Capturer) Option {
+	return func(o *options) {
+		o.capturer = c
+	}
+}
+
 // NewHandler new a pprof handler.
-func NewPprofHandler() http.Handler {
+func NewPprofHandler(opts ...Option) http.Handler {
+	var o options
+	for _, opt := range opts {
+		opt(&o)
+	}
+
 	mux := http.NewServeMux()
 	mux.HandleFunc("/debug/pprof/", pprof.Index)
 	mux.HandleFunc("/debug/pprof/cmdline", pprof.Cmdline)
 	mux.HandleFunc("/debug/pprof/profile", pprof.Profile)
 	mux.HandleFunc("/debug/pprof/symbol", pprof.Symbol)
 	mux.HandleFunc("/debug/pprof/trace", pprof.Trace)
-	return mux
+	if o.capturer != nil {
+		// GET /debug/profiles 列出已采集的profile
+		// GET /debug/profiles/{name} 下载profile
+		// POST /debug/profiles?reason=xxx 立即采集
+		mux.Handle("/debug/profiles", profilesHandler(o.capturer))
+		mux.Handle("/debug/profiles/", downloadHandler(o.capturer))
+	}
+	if o.token == "" {
+		return mux
+	}
+	return authHandler(o.token, mux)
 }
 
-func Serve(addr string) {
+func Serve(addr string, opts ...Option) {
 	go func() {
-		handler := NewPprofHandler()
+		handler := NewPprofHandler(opts...)
 		http.ListenAndServe(addr, handler)
 	}()
 }
+
+func authHandler(token string, next http.Handler) http.Handler {
+	expected := []byte("Bearer " + token)
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if subtle.ConstantTimeCompare([]byte(r.Header.Get("Authorization")), expected) != 1 {
+			http.Error(w, "unauthorized", http.StatusUnauthorized)
+			return
+		}
+		next.ServeHTTP(w, r)
+	})
+}
+
+func profilesHandler(c *Capturer) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var (
+			profiles []Profile
+			err      error
+		)
+		switch r.Method {
+		case http.MethodGet:
+			profiles, err = c.List()
+		case http.MethodPost:
+			profiles, err = c.Capture(r.URL.Query().Get("reason"))
+			if err == ErrCaptureInProgress {
+				http.Error(w, err.Error(), http.StatusConflict)
+				return
+			}
+		default:
+			http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+			return
+		}
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		if profiles == nil {
+			profiles = []Profile{}
+		}
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(profiles)
+	})
+}
+
+func downloadHandler(c *Capturer) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodGet {
+			http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+			return
+		}
+		name := strings.TrimPrefix(r.URL.Path, "/debug/profiles/")
+		f, err := c.Open(name)
+		if err == ErrProfileNotFound {
+			http.NotFound(w, r)
+			return
+		}
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		defer f.Close()
+		w.Header().Set("Content-Type", "application/octet-stream")
+		w.Header().Set("Content-Disposition", `attachment; filename="`+name+`"`)
+		io.Copy(w, f)
+	})
+}
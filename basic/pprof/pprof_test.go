@@ -0,0 +1,48 @@
+package pprof
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestPprofHandler(t *testing.T) {
+	c, err := NewCapturer(CaptureConfig{Dir: t.TempDir(), Profiles: []string{ProfileHeap}})
+	assert.Nil(t, err)
+	handler := NewPprofHandler(WithToken("secret"), WithCapturer(c))
+
+	do := func(method, url, token string) *httptest.ResponseRecorder {
+		r := httptest.NewRequest(method, url, nil)
+		if token != "" {
+			r.Header.Set("Authorization", "Bearer "+token)
+		}
+		w := httptest.NewRecorder()
+		handler.ServeHTTP(w, r)
+		return w
+	}
+
+	assert.Equal(t, http.StatusUnauthorized, do(http.MethodGet, "/debug/pprof/", "").Code)
+	assert.Equal(t, http.StatusUnauthorized, do(http.MethodGet, "/debug/profiles", "wrong").Code)
+	assert.Equal(t, http.StatusOK, do(http.MethodGet, "/debug/pprof/", "secret").Code)
+
+	w := do(http.MethodPost, "/debug/profiles?reason=oncall", "secret")
+	assert.Equal(t, http.StatusOK, w.Code)
+	var captured []Profile
+	assert.Nil(t, json.Unmarshal(w.Body.Bytes(), &captured))
+	assert.Len(t, captured, 1)
+	assert.Equal(t, "oncall", captured[0].Reason)
+
+	w = do(http.MethodGet, "/debug/profiles", "secret")
+	var listed []Profile
+	assert.Nil(t, json.Unmarshal(w.Body.Bytes(), &listed))
+	assert.Equal(t, captured[0].Name, listed[0].Name)
+
+	w = do(http.MethodGet, "/debug/profiles/"+captured[0].Name, "secret")
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Equal(t, int(captured[0].Size), w.Body.Len())
+
+	assert.Equal(t, http.StatusNotFound, do(http.MethodGet, "/debug/profiles/passwd", "secret").Code)
+}
@@ -0,0 +1,134 @@
+package redis
+
+import (
+	"context"
+	"hash/fnv"
+	"math"
+	"strconv"
+
+	"github.com/go-redis/redis/v8"
+)
+
+const (
+	// redis字符串最大512MB
+	maxBloomBits = 1 << 32
+
+	defaultExpectedItems     = 1000000
+	defaultFalsePositiveRate = 0.01
+)
+
+var (
+	bloomAddScript = redis.NewScript(`
+	local added = 0
+	for i = 1, #ARGV do
+		if redis.call("SETBIT", KEYS[1], ARGV[i], 1) == 0 then
+			added = 1
+		end
+	end
+	return added
+`)
+	bloomExistsScript = redis.NewScript(`
+	for i = 1, #ARGV do
+		if redis.call("GETBIT", KEYS[1], ARGV[i]) == 0 then
+			return 0
+		end
+	end
+	return 1
+`)
+)
+
+type BloomConfig struct {
+	// 预计保存的元素个数，默认100万
+	ExpectedItems uint64
+	// 期望的误判率，默认0.01
+	FalsePositiveRate float64
+	// 哈希函数个数，默认按ExpectedItems和FalsePositiveRate计算
+	Hashes uint
+}
+
+func (c BloomConfig) WithDefaults() BloomConfig {
+	if c.ExpectedItems == 0 {
+		c.ExpectedItems = defaultExpectedItems
+	}
+	if c.FalsePositiveRate <= 0 || c.FalsePositiveRate >= 1 {
+		c.FalsePositiveRate = defaultFalsePositiveRate
+	}
+	return c
+}
+
+// Bloom 基于redis bitmap的布隆过滤器，通过lua脚本保证多个bit的读写是原子的，不依赖RedisBloom模块
+type Bloom struct {
+	client *redis.Client
+	key    string
+	bits   uint64
+	hashes uint
+}
+
+func NewBloom(client *redis.Client, key string, cfg BloomConfig) *Bloom {
+	cfg = cfg.WithDefaults()
+	bits, hashes := bloomParams(cfg.ExpectedItems, cfg.FalsePositiveRate)
+	if cfg.Hashes > 0 {
+		hashes = cfg.Hashes
+	}
+	return &Bloom{client: client, key: key, bits: bits, hashes: hashes}
+}
+
+// bloomParams m = -n*ln(p)/(ln2)^2, k = m/n*ln2
+func bloomParams(n uint64, p float64) (bits uint64, hashes uint) {
+	m := math.Ceil(-float64(n) * math.Log(p) / (math.Ln2 * math.Ln2))
+	if m > maxBloomBits {
+		m = maxBloomBits
+	}
+	k := math.Round(m / float64(n) * math.Ln2)
+	if k < 1 {
+		k = 1
+	}
+	return uint64(m), uint(k)
+}
+
+// Bits bitmap的位数
+func (b *Bloom) Bits() uint64 {
+	return b.bits
+}
+
+// Hashes 哈希函数个数
+func (b *Bloom) Hashes() uint {
+	return b.hashes
+}
+
+// Add 添加元素
+func (b *Bloom) Add(ctx context.Context, item string) error {
+	_, err := b.AddNew(ctx, item)
+	return err
+}
+
+// AddNew 添加元素，返回添加前元素是否一定不存在
+func (b *Bloom) AddNew(ctx context.Context, item string) (bool, error) {
+	added, err := bloomAddScript.Run(ctx, b.client, []string{b.key}, b.offsets(item)...).Int()
+	return added == 1, err
+}
+
+// Exists 返回false时元素一定不存在，返回true时元素可能存在
+func (b *Bloom) Exists(ctx context.Context, item string) (bool, error) {
+	exists, err := bloomExistsScript.Run(ctx, b.client, []string{b.key}, b.offsets(item)...).Int()
+	return exists == 1, err
+}
+
+// Delete 删除整个过滤器
+func (b *Bloom) Delete(ctx context.Context) error {
+	return b.client.Del(ctx, b.key).Err()
+}
+
+// offsets 双重哈希 h1 + i*h2 计算每个哈希函数对应的bit
+func (b *Bloom) offsets(item string) []interface{} {
+	h := fnv.New64a()
+	h.Write([]byte(item))
+	h1 := h.Sum64()
+	h2 := h1>>33 | h1<<31
+	h2 = h2*0x9e3779b97f4a7c15 | 1
+	offsets := make([]interface{}, b.hashes)
+	for i := range offsets {
+		offsets[i] = strconv.FormatUint((h1+uint64(i)*h2)%b.bits, 10)
+	}
+	return offsets
+}
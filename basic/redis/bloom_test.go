@@ -0,0 +1,54 @@
+package redis
+
+import (
+	"context"
+	"strconv"
+	"testing"
+
+	"github.com/alicebob/miniredis/v2"
+	"github.com/go-redis/redis/v8"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestBloomParams(t *testing.T) {
+	bits, hashes := bloomParams(1000000, 0.01)
+	assert.Equal(t, uint64(9585059), bits)
+	assert.Equal(t, uint(7), hashes)
+
+	b := NewBloom(nil, "bloom", BloomConfig{ExpectedItems: 1000, Hashes: 3})
+	assert.Equal(t, uint(3), b.Hashes())
+	assert.Equal(t, uint64(9586), b.Bits())
+}
+
+func TestBloom(t *testing.T) {
+	ctx := context.Background()
+	mr := miniredis.RunT(t)
+	client := redis.NewClient(&redis.Options{Addr: mr.Addr()})
+
+	const n = 1000
+	b := NewBloom(client, "bloom", BloomConfig{ExpectedItems: n, FalsePositiveRate: 0.01})
+	for i := 0; i < n; i++ {
+		assert.Nil(t, b.Add(ctx, "item"+strconv.Itoa(i)))
+	}
+	added, err := b.AddNew(ctx, "item0")
+	assert.Nil(t, err)
+	assert.False(t, added)
+
+	for i := 0; i < n; i++ {
+		exists, err := b.Exists(ctx, "item"+strconv.Itoa(i))
+		assert.Nil(t, err)
+		assert.True(t, exists)
+	}
+	falsePositives := 0
+	for i := 0; i < n; i++ {
+		if exists, _ := b.Exists(ctx, "other"+strconv.Itoa(i)); exists {
+			falsePositives++
+		}
+	}
+	assert.Less(t, falsePositives, n*3/100)
+
+	assert.Nil(t, b.Delete(ctx))
+	exists, err := b.Exists(ctx, "item0")
+	assert.Nil(t, err)
+	assert.False(t, exists)
+}
@@ -0,0 +1,188 @@
+package redis
+
+import (
+	"context"
+	"math"
+
+	"github.com/go-redis/redis/v8"
+)
+
+type GeoUnit string
+
+const (
+	Meters     GeoUnit = "m"
+	Kilometers GeoUnit = "km"
+	Miles      GeoUnit = "mi"
+	Feet       GeoUnit = "ft"
+)
+
+// 与redis计算距离使用的地球半径相同
+const earthRadiusMeters = 6372797.560856
+
+func (u GeoUnit) meters() float64 {
+	switch u {
+	case Kilometers:
+		return 1000
+	case Miles:
+		return 1609.34
+	case Feet:
+		return 0.3048
+	}
+	return 1
+}
+
+type GeoLocation struct {
+	Name      string
+	Longitude float64
+	Latitude  float64
+}
+
+type GeoResult struct {
+	GeoLocation
+	// 到搜索中心的距离，单位与查询的Unit相同
+	Distance float64
+}
+
+// GeoQuery 搜索中心和结果限制
+type GeoQuery struct {
+	Longitude float64
+	Latitude  float64
+	// 距离单位，默认米
+	Unit GeoUnit
+	// 最多返回的条数，0表示不限制
+	Count int
+	// 默认按距离由近到远排序
+	Desc bool
+}
+
+func (q GeoQuery) unit() GeoUnit {
+	if q.Unit == "" {
+		return Meters
+	}
+	return q.Unit
+}
+
+// GeoIndex 基于redis GEO命令的位置索引
+type GeoIndex struct {
+	client *redis.Client
+	key    string
+}
+
+func NewGeoIndex(client *redis.Client, key string) *GeoIndex {
+	return &GeoIndex{client: client, key: key}
+}
+
+// Add 添加或更新位置
+func (g *GeoIndex) Add(ctx context.Context, locations ...GeoLocation) error {
+	locs := make([]*redis.GeoLocation, len(locations))
+	for i, l := range locations {
+		locs[i] = &redis.GeoLocation{Name: l.Name, Longitude: l.Longitude, Latitude: l.Latitude}
+	}
+	return g.client.GeoAdd(ctx, g.key, locs...).Err()
+}
+
+// Remove 删除位置
+func (g *GeoIndex) Remove(ctx context.Context, names ...string) error {
+	members := make([]interface{}, len(names))
+	for i, name := range names {
+		members[i] = name
+	}
+	return g.client.ZRem(ctx, g.key, members...).Err()
+}
+
+// Position 返回位置，不存在的为nil
+func (g *GeoIndex) Position(ctx context.Context, names ...string) ([]*GeoLocation, error) {
+	positions, err := g.client.GeoPos(ctx, g.key, names...).Result()
+	if err != nil {
+		return nil, err
+	}
+	locations := make([]*GeoLocation, len(positions))
+	for i, p := range positions {
+		if p != nil {
+			locations[i] = &GeoLocation{Name: names[i], Longitude: p.Longitude, Latitude: p.Latitude}
+		}
+	}
+	return locations, nil
+}
+
+// Distance 两个位置之间的距离，任意一个不存在时返回redis.Nil
+func (g *GeoIndex) Distance(ctx context.Context, from, to string, unit GeoUnit) (float64, error) {
+	if unit == "" {
+		unit = Meters
+	}
+	return g.client.GeoDist(ctx, g.key, from, to, string(unit)).Result()
+}
+
+// SearchRadius 返回以q为中心、半径radius内的位置
+func (g *GeoIndex) SearchRadius(ctx context.Context, q GeoQuery, radius float64) ([]GeoResult, error) {
+	locations, err := g.client.GeoRadius(ctx, g.key, q.Longitude, q.Latitude, g.radiusQuery(q, radius, q.Count)).Result()
+	if err != nil {
+		return nil, err
+	}
+	results := make([]GeoResult, len(locations))
+	for i, l := range locations {
+		results[i] = toGeoResult(l)
+	}
+	return results, nil
+}
+
+// SearchBox 返回以q为中心、宽width高height的矩形内的位置。
+// 为了兼容不支持GEOSEARCH的redis(6.2以下)，先按外接圆搜索再过滤，判断方式与GEOSEARCH BYBOX相同
+func (g *GeoIndex) SearchBox(ctx context.Context, q GeoQuery, width, height float64) ([]GeoResult, error) {
+	unit := q.unit().meters()
+	halfWidth, halfHeight := width*unit/2, height*unit/2
+	// 经度方向的距离与纬度有关，外接圆留一点余量
+	radius := math.Hypot(halfWidth, halfHeight) * 1.01 / unit
+	locations, err := g.client.GeoRadius(ctx, g.key, q.Longitude, q.Latitude, g.radiusQuery(q, radius, 0)).Result()
+	if err != nil {
+		return nil, err
+	}
+
+	results := make([]GeoResult, 0, len(locations))
+	for _, l := range locations {
+		if haversine(l.Longitude, l.Latitude, l.Longitude, q.Latitude) > halfHeight ||
+			haversine(l.Longitude, l.Latitude, q.Longitude, l.Latitude) > halfWidth {
+			continue
+		}
+		results = append(results, toGeoResult(l))
+	}
+	if q.Count > 0 && len(results) > q.Count {
+		results = results[:q.Count]
+	}
+	return results, nil
+}
+
+// Delete 删除整个索引
+func (g *GeoIndex) Delete(ctx context.Context) error {
+	return g.client.Del(ctx, g.key).Err()
+}
+
+func (g *GeoIndex) radiusQuery(q GeoQuery, radius float64, count int) *redis.GeoRadiusQuery {
+	order := "ASC"
+	if q.Desc {
+		order = "DESC"
+	}
+	return &redis.GeoRadiusQuery{
+		Radius:    radius,
+		Unit:      string(q.unit()),
+		WithCoord: true,
+		WithDist:  true,
+		Count:     count,
+		Sort:      order,
+	}
+}
+
+func toGeoResult(l redis.GeoLocation) GeoResult {
+	return GeoResult{
+		GeoLocation: GeoLocation{Name: l.Name, Longitude: l.Longitude, Latitude: l.Latitude},
+		Distance:    l.Dist,
+	}
+}
+
+// haversine 两点之间的球面距离，单位米
+func haversine(lon1, lat1, lon2, lat2 float64) float64 {
+	lat1r, lat2r := lat1*math.Pi/180, lat2*math.Pi/180
+	u := math.Sin((lat2r - lat1r) / 2)
+	v := math.Sin((lon2 - lon1) * math.Pi / 180 / 2)
+	return 2 * earthRadiusMeters * math.Asin(math.Sqrt(u*u+math.Cos(lat1r)*math.Cos(lat2r)*v*v))
+}
@@ -0,0 +1,64 @@
+package redis
+
+import (
+	"context"
+	"testing"
+
+	"github.com/alicebob/miniredis/v2"
+	"github.com/go-redis/redis/v8"
+	"github.com/stretchr/testify/assert"
+)
+
+func names(results []GeoResult) []string {
+	var s []string
+	for _, r := range results {
+		s = append(s, r.Name)
+	}
+	return s
+}
+
+func TestGeoIndex(t *testing.T) {
+	ctx := context.Background()
+	mr := miniredis.RunT(t)
+	g := NewGeoIndex(redis.NewClient(&redis.Options{Addr: mr.Addr()}), "shops")
+
+	assert.Nil(t, g.Add(ctx,
+		GeoLocation{Name: "center", Longitude: 116.397, Latitude: 39.908},
+		GeoLocation{Name: "east", Longitude: 116.420, Latitude: 39.908},  // 向东约2km
+		GeoLocation{Name: "north", Longitude: 116.397, Latitude: 39.935}, // 向北约3km
+		GeoLocation{Name: "far", Longitude: 121.473, Latitude: 31.230},
+	))
+
+	positions, err := g.Position(ctx, "east", "missing")
+	assert.Nil(t, err)
+	assert.Equal(t, "east", positions[0].Name)
+	assert.InDelta(t, 116.420, positions[0].Longitude, 0.0001)
+	assert.Nil(t, positions[1])
+
+	d, err := g.Distance(ctx, "center", "east", Kilometers)
+	assert.Nil(t, err)
+	assert.InDelta(t, 1.96, d, 0.05)
+
+	results, err := g.SearchRadius(ctx, GeoQuery{Longitude: 116.397, Latitude: 39.908, Unit: Kilometers}, 5)
+	assert.Nil(t, err)
+	assert.Equal(t, []string{"center", "east", "north"}, names(results))
+	assert.InDelta(t, 1.96, results[1].Distance, 0.05)
+
+	results, err = g.SearchRadius(ctx, GeoQuery{Longitude: 116.397, Latitude: 39.908, Count: 2, Desc: true}, 5000)
+	assert.Nil(t, err)
+	assert.Equal(t, []string{"north", "east"}, names(results))
+
+	// 宽5km高4km，north在纬度方向超出
+	results, err = g.SearchBox(ctx, GeoQuery{Longitude: 116.397, Latitude: 39.908, Unit: Kilometers}, 5, 4)
+	assert.Nil(t, err)
+	assert.Equal(t, []string{"center", "east"}, names(results))
+
+	results, err = g.SearchBox(ctx, GeoQuery{Longitude: 116.397, Latitude: 39.908, Count: 1}, 5000, 8000)
+	assert.Nil(t, err)
+	assert.Equal(t, []string{"center"}, names(results))
+
+	assert.Nil(t, g.Remove(ctx, "east"))
+	positions, err = g.Position(ctx, "east")
+	assert.Nil(t, err)
+	assert.Nil(t, positions[0])
+}
@@ -0,0 +1,188 @@
+package redis
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/go-redis/redis/v8"
+	"github.com/opentracing/opentracing-go"
+	"github.com/opentracing/opentracing-go/ext"
+	opentracing_log "github.com/opentracing/opentracing-go/log"
+	"github.com/prometheus/client_golang/prometheus"
+	"singer.com/basic/log"
+	"singer.com/basic/metric"
+	"singer.com/basic/trace"
+)
+
+const (
+	componentTag      = "go-redis"
+	pipelineName      = "pipeline"
+	maxStatementLen   = 256
+	maxPipelineStmts  = 10
+	redactedArgument  = "?"
+	pipelineCmdNumTag = "db.redis.num_cmd"
+)
+
+var (
+	commandDuration = metric.NewHistogramVec(prometheus.HistogramOpts{
+		Name:    "redis_command_duration_seconds",
+		Help:    "Latency of redis commands and pipelines.",
+		Buckets: []float64{.0005, .001, .0025, .005, .01, .025, .05, .1, .25, .5, 1},
+	}, "command")
+	commandErrors = metric.NewCounterVec(prometheus.CounterOpts{
+		Name: "redis_command_errors_total",
+		Help: "Total number of failed redis commands, redis.Nil excluded.",
+	}, "command")
+)
+
+// 参数全部是敏感信息的命令
+var redactAllArgs = map[string]bool{
+	"auth":    true,
+	"eval":    true,
+	"evalsha": true,
+	"hello":   true,
+	"migrate": true,
+}
+
+type hookStateKey struct{}
+
+type hookState struct {
+	start time.Time
+	span  opentracing.Span
+}
+
+type hook struct {
+	opts redisOption
+}
+
+// NewHook 返回为命令创建span、统计耗时和错误数、打印慢日志的redis.Hook，
+// Client和FailoverClient已默认添加，自行创建的客户端可以通过AddHook使用
+func NewHook(opts ...Option) redis.Hook {
+	return &hook{opts: newRedisOptions(opts...)}
+}
+
+func (h *hook) BeforeProcess(ctx context.Context, cmd redis.Cmder) (context.Context, error) {
+	return h.before(ctx, cmd.FullName(), func(span opentracing.Span) {
+		ext.DBStatement.Set(span, sanitizeCmd(cmd))
+	}), nil
+}
+
+func (h *hook) AfterProcess(ctx context.Context, cmd redis.Cmder) error {
+	h.after(ctx, cmd.FullName(), []redis.Cmder{cmd})
+	return nil
+}
+
+func (h *hook) BeforeProcessPipeline(ctx context.Context, cmds []redis.Cmder) (context.Context, error) {
+	return h.before(ctx, pipelineName, func(span opentracing.Span) {
+		span.SetTag(pipelineCmdNumTag, len(cmds))
+		ext.DBStatement.Set(span, sanitizePipeline(cmds))
+	}), nil
+}
+
+func (h *hook) AfterProcessPipeline(ctx context.Context, cmds []redis.Cmder) error {
+	h.after(ctx, pipelineName, cmds)
+	return nil
+}
+
+func (h *hook) before(ctx context.Context, name string, decorate func(opentracing.Span)) context.Context {
+	state := &hookState{start: time.Now()}
+	if h.opts.enableTracing {
+		var span opentracing.Span
+		span, ctx = opentracing.StartSpanFromContextWithTracer(ctx, trace.GlobalTracer(), "redis "+name,
+			ext.SpanKindRPCClient,
+			opentracing.Tag{Key: string(ext.DBType), Value: "redis"},
+			opentracing.Tag{Key: string(ext.Component), Value: componentTag})
+		decorate(span)
+		state.span = span
+	}
+	return context.WithValue(ctx, hookStateKey{}, state)
+}
+
+func (h *hook) after(ctx context.Context, name string, cmds []redis.Cmder) {
+	state, ok := ctx.Value(hookStateKey{}).(*hookState)
+	if !ok {
+		return
+	}
+	cost := time.Since(state.start)
+
+	var firstErr error
+	for _, cmd := range cmds {
+		err := cmd.Err()
+		if err == nil || err == redis.Nil {
+			continue
+		}
+		if firstErr == nil {
+			firstErr = err
+		}
+		if h.opts.enableMetrics {
+			commandErrors.WithLabelValues(cmd.FullName()).Inc()
+		}
+	}
+
+	if h.opts.enableMetrics {
+		commandDuration.WithLabelValues(name).Observe(cost.Seconds())
+	}
+
+	if state.span != nil {
+		if firstErr != nil {
+			ext.Error.Set(state.span, true)
+			state.span.LogFields(opentracing_log.String("event", "error"), opentracing_log.String("message", firstErr.Error()))
+		}
+		state.span.Finish()
+	}
+
+	if h.opts.slowThreshold > 0 && cost > h.opts.slowThreshold {
+		var stmt string
+		if len(cmds) == 1 && name != pipelineName {
+			stmt = sanitizeCmd(cmds[0])
+		} else {
+			stmt = sanitizePipeline(cmds)
+		}
+		log.RedisSlowf(ctx, cost, "%s", stmt)
+	}
+}
+
+// sanitizeCmd 只保留命令名和key，其余参数替换为?，例如: set user:1 ?
+func sanitizeCmd(cmd redis.Cmder) string {
+	args := cmd.Args()
+	if len(args) == 0 {
+		return ""
+	}
+	var b strings.Builder
+	b.WriteString(cmd.FullName())
+	first := 1
+	if cmd.FullName() != cmd.Name() {
+		// 子命令已经包含在FullName中，例如: cluster info
+		first = 2
+	}
+	for i := first; i < len(args) && b.Len() <= maxStatementLen; i++ {
+		b.WriteByte(' ')
+		if i == 1 && !redactAllArgs[cmd.Name()] {
+			b.WriteString(fmt.Sprint(args[i]))
+		} else {
+			b.WriteString(redactedArgument)
+		}
+	}
+	return truncate(b.String(), maxStatementLen)
+}
+
+func sanitizePipeline(cmds []redis.Cmder) string {
+	stmts := make([]string, 0, maxPipelineStmts+1)
+	for i, cmd := range cmds {
+		if i == maxPipelineStmts {
+			stmts = append(stmts, fmt.Sprintf("...(%d more)", len(cmds)-maxPipelineStmts))
+			break
+		}
+		stmts = append(stmts, sanitizeCmd(cmd))
+	}
+	return strings.Join(stmts, "\n")
+}
+
+func truncate(s string, n int) string {
+	if len(s) <= n {
+		return s
+	}
+	return s[:n] + "..."
+}
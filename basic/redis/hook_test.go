@@ -0,0 +1,68 @@
+package redis
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/go-redis/redis/v8"
+	"github.com/opentracing/opentracing-go"
+	"github.com/opentracing/opentracing-go/mocktracer"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestSanitizeCmd(t *testing.T) {
+	ctx := context.Background()
+	assert.Equal(t, "set user:1 ? ? ?", sanitizeCmd(redis.NewStatusCmd(ctx, "set", "user:1", "secret", "ex", 10)))
+	assert.Equal(t, "get user:1", sanitizeCmd(redis.NewStringCmd(ctx, "get", "user:1")))
+	assert.Equal(t, "auth ?", sanitizeCmd(redis.NewStatusCmd(ctx, "auth", "password")))
+	assert.Equal(t, "eval ? ? ?", sanitizeCmd(redis.NewCmd(ctx, "eval", "return 1", 1, "key")))
+	assert.Equal(t, "cluster info", sanitizeCmd(redis.NewStringCmd(ctx, "cluster", "info")))
+	assert.Equal(t, "ping", sanitizeCmd(redis.NewStatusCmd(ctx, "ping")))
+
+	args := []interface{}{"mset"}
+	for i := 0; i < 200; i++ {
+		args = append(args, "key", "value")
+	}
+	assert.LessOrEqual(t, len(sanitizeCmd(redis.NewStatusCmd(ctx, args...))), maxStatementLen+3)
+}
+
+func TestHook(t *testing.T) {
+	tracer := mocktracer.New()
+	old := opentracing.GlobalTracer()
+	opentracing.SetGlobalTracer(tracer)
+	defer opentracing.SetGlobalTracer(old)
+
+	h := NewHook()
+	parent := tracer.StartSpan("rpc")
+	ctx := opentracing.ContextWithSpan(context.Background(), parent)
+
+	cmd := redis.NewStringCmd(ctx, "get", "user:1")
+	cctx, err := h.BeforeProcess(ctx, cmd)
+	assert.Nil(t, err)
+	cmd.SetErr(redis.Nil)
+	assert.Nil(t, h.AfterProcess(cctx, cmd))
+
+	cmds := []redis.Cmder{redis.NewStatusCmd(ctx, "set", "a", "1"), redis.NewStringCmd(ctx, "get", "b")}
+	cctx, err = h.BeforeProcessPipeline(ctx, cmds)
+	assert.Nil(t, err)
+	cmds[1].SetErr(errors.New("broken"))
+	assert.Nil(t, h.AfterProcessPipeline(cctx, cmds))
+
+	spans := tracer.FinishedSpans()
+	assert.Len(t, spans, 2)
+	assert.Equal(t, "redis get", spans[0].OperationName)
+	assert.Equal(t, "get user:1", spans[0].Tag("db.statement"))
+	assert.Nil(t, spans[0].Tag("error")) // redis.Nil不是错误
+	assert.Equal(t, parent.(*mocktracer.MockSpan).SpanContext.SpanID, spans[0].ParentID)
+
+	assert.Equal(t, "redis pipeline", spans[1].OperationName)
+	assert.Equal(t, "set a ?\nget b", spans[1].Tag("db.statement"))
+	assert.Equal(t, true, spans[1].Tag("error"))
+
+	// 关闭tracing时不会结束ctx中已有的span
+	h = NewHook(DisableTracing())
+	cctx, _ = h.BeforeProcess(ctx, cmd)
+	h.AfterProcess(cctx, cmd)
+	assert.Len(t, tracer.FinishedSpans(), 2)
+}
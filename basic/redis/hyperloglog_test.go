@@ -0,0 +1,46 @@
+package redis
+
+import (
+	"context"
+	"testing"
+
+	"github.com/alicebob/miniredis/v2"
+	"github.com/go-redis/redis/v8"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestHyperLogLog(t *testing.T) {
+	ctx := context.Background()
+	mr := miniredis.RunT(t)
+	client := redis.NewClient(&redis.Options{Addr: mr.Addr()})
+
+	mon := NewHyperLogLog(client, "uv:mon")
+	tue := NewHyperLogLog(client, "uv:tue")
+
+	changed, err := mon.Add(ctx, "a", "b", "c")
+	assert.Nil(t, err)
+	assert.True(t, changed)
+	changed, err = mon.Add(ctx, "a")
+	assert.Nil(t, err)
+	assert.False(t, changed)
+	_, err = tue.Add(ctx, "d")
+	assert.Nil(t, err)
+
+	count, err := mon.Count(ctx)
+	assert.Nil(t, err)
+	assert.Equal(t, int64(3), count)
+	count, err = mon.Count(ctx, tue)
+	assert.Nil(t, err)
+	assert.Equal(t, int64(4), count)
+
+	week := NewHyperLogLog(client, "uv:week")
+	assert.Nil(t, week.Merge(ctx, mon, tue))
+	count, err = week.Count(ctx)
+	assert.Nil(t, err)
+	assert.Equal(t, int64(4), count)
+
+	assert.Nil(t, week.Delete(ctx))
+	count, err = week.Count(ctx)
+	assert.Nil(t, err)
+	assert.Equal(t, int64(0), count)
+}
@@ -0,0 +1,46 @@
+package redis
+
+import "time"
+
+type redisOption struct {
+	enableTracing bool
+	enableMetrics bool
+	slowThreshold time.Duration
+}
+
+type Option func(o *redisOption)
+
+var defaultRedisOptions = redisOption{
+	enableTracing: true,
+	enableMetrics: true,
+	slowThreshold: 100 * time.Millisecond,
+}
+
+func newRedisOptions(opts ...Option) redisOption {
+	o := defaultRedisOptions
+	for _, opt := range opts {
+		opt(&o)
+	}
+	return o
+}
+
+// DisableTracing 不为redis命令创建span
+func DisableTracing() Option {
+	return func(o *redisOption) {
+		o.enableTracing = false
+	}
+}
+
+// DisableMetrics 不统计redis命令的耗时和错误数
+func DisableMetrics() Option {
+	return func(o *redisOption) {
+		o.enableMetrics = false
+	}
+}
+
+// SlowThreshold 慢命令日志阈值，小于等于0时不打印慢日志
+func SlowThreshold(d time.Duration) Option {
+	return func(o *redisOption) {
+		o.slowThreshold = d
+	}
+}
@@ -9,21 +9,27 @@ const (
 	idleConns  = 4
 )
 
-func Client(addr string) (*redis.Client, error) {
+func Client(addr string, opts ...Option) (*redis.Client, error) {
 	store := redis.NewClient(&redis.Options{
 		Addr:         addr,
 		MaxRetries:   maxRetries,
 		MinIdleConns: idleConns,
 	})
+	store.AddHook(NewHook(opts...))
 	return store, nil
 }
 
 func FailoverClient(masterName string, sentinelAddr ...string) (*redis.Client, error) {
+	return FailoverClientWithOptions(masterName, sentinelAddr)
+}
+
+func FailoverClientWithOptions(masterName string, sentinelAddrs []string, opts ...Option) (*redis.Client, error) {
 	store := redis.NewFailoverClient(&redis.FailoverOptions{
 		MasterName:    masterName,
-		SentinelAddrs: sentinelAddr,
+		SentinelAddrs: sentinelAddrs,
 		MaxRetries:    maxRetries,
 		MinIdleConns:  idleConns,
 	})
+	store.AddHook(NewHook(opts...))
 	return store, nil
 }
@@ -0,0 +1,110 @@
+package respcache
+
+import (
+	"crypto/sha256"
+	"encoding/binary"
+	"encoding/hex"
+	"errors"
+	"time"
+
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/types/known/anypb"
+)
+
+const defaultRefreshTimeout = 10 * time.Second
+
+// MethodConfig 单个方法的缓存配置
+type MethodConfig struct {
+	// 响应的新鲜时间
+	TTL time.Duration
+	// 过期后仍然可以返回旧响应的时间，返回旧响应的同时在后台刷新，0表示过期后同步刷新
+	StaleTTL time.Duration
+	// 参与计算缓存key的metadata，如accept-language
+	MetadataKeys []string
+	// 响应与调用方身份无关，所有调用方共享缓存。默认缓存key包含认证拦截器保存的调用方身份
+	Shared bool
+}
+
+type Config struct {
+	Store Store
+	// key为方法全名，如/api.Order/Get，只缓存配置的方法
+	Methods map[string]MethodConfig
+	// 合并加载和后台刷新的超时时间，默认10s
+	RefreshTimeout time.Duration
+}
+
+// WithDefaults 返回填充了默认值的配置
+func (c Config) WithDefaults() Config {
+	if c.RefreshTimeout <= 0 {
+		c.RefreshTimeout = defaultRefreshTimeout
+	}
+	return c
+}
+
+// Key 由方法名、调用方身份、请求的确定性序列化结果和指定的metadata计算缓存key，principal为空表示所有调用方共享
+func Key(fullMethod, principal string, req proto.Message, md metadata.MD, keys []string) (string, error) {
+	data, err := proto.MarshalOptions{Deterministic: true}.Marshal(req)
+	if err != nil {
+		return "", err
+	}
+	h := sha256.New()
+	var n [8]byte
+	binary.BigEndian.PutUint64(n[:], uint64(len(principal)))
+	h.Write(n[:])
+	h.Write([]byte(principal))
+	h.Write(data)
+	for _, k := range keys {
+		for _, v := range md.Get(k) {
+			h.Write([]byte{0})
+			h.Write([]byte(k))
+			h.Write([]byte{0})
+			h.Write([]byte(v))
+		}
+	}
+	return fullMethod + ":" + hex.EncodeToString(h.Sum(nil)), nil
+}
+
+// Entry 缓存的响应
+type Entry struct {
+	Response   proto.Message
+	FreshUntil time.Time
+}
+
+// Fresh 判断响应是否仍然新鲜
+func (e *Entry) Fresh(now time.Time) bool {
+	return now.Before(e.FreshUntil)
+}
+
+// Marshal 序列化为 8字节的新鲜截止时间 + Any
+func (e *Entry) Marshal() ([]byte, error) {
+	a, err := anypb.New(e.Response)
+	if err != nil {
+		return nil, err
+	}
+	data, err := proto.Marshal(a)
+	if err != nil {
+		return nil, err
+	}
+	buf := make([]byte, 8, 8+len(data))
+	binary.BigEndian.PutUint64(buf, uint64(e.FreshUntil.UnixNano()))
+	return append(buf, data...), nil
+}
+
+func UnmarshalEntry(data []byte) (*Entry, error) {
+	if len(data) < 8 {
+		return nil, errors.New("respcache: invalid entry")
+	}
+	a := &anypb.Any{}
+	if err := proto.Unmarshal(data[8:], a); err != nil {
+		return nil, err
+	}
+	resp, err := a.UnmarshalNew()
+	if err != nil {
+		return nil, err
+	}
+	return &Entry{
+		Response:   resp,
+		FreshUntil: time.Unix(0, int64(binary.BigEndian.Uint64(data))),
+	}, nil
+}
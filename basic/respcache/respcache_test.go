@@ -0,0 +1,99 @@
+package respcache
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/alicebob/miniredis/v2"
+	"github.com/go-redis/redis/v8"
+	"github.com/stretchr/testify/assert"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/types/known/structpb"
+	"google.golang.org/protobuf/types/known/wrapperspb"
+)
+
+func TestKey(t *testing.T) {
+	req, _ := structpb.NewStruct(map[string]interface{}{"a": 1, "b": "x", "c": true})
+	same, _ := structpb.NewStruct(map[string]interface{}{"c": true, "b": "x", "a": 1})
+	md := metadata.Pairs("accept-language", "zh-CN", "requestid", "1")
+
+	k1, err := Key("/api.Order/Get", "", req, md, []string{"accept-language"})
+	assert.Nil(t, err)
+	// map字段的序列化顺序固定
+	k2, _ := Key("/api.Order/Get", "", same, metadata.Pairs("accept-language", "zh-CN", "requestid", "2"), []string{"accept-language"})
+	assert.Equal(t, k1, k2)
+
+	k3, _ := Key("/api.Order/Get", "", req, metadata.Pairs("accept-language", "en-US"), []string{"accept-language"})
+	assert.NotEqual(t, k1, k3)
+	k4, _ := Key("/api.Order/List", "", req, md, []string{"accept-language"})
+	assert.NotEqual(t, k1, k4)
+
+	// 不同调用方的key不同
+	k5, _ := Key("/api.Order/Get", "jwt:u1", req, md, []string{"accept-language"})
+	k6, _ := Key("/api.Order/Get", "jwt:u2", req, md, []string{"accept-language"})
+	assert.NotEqual(t, k1, k5)
+	assert.NotEqual(t, k5, k6)
+}
+
+func TestEntry(t *testing.T) {
+	freshUntil := time.Now().Add(time.Minute)
+	e := &Entry{Response: wrapperspb.String("order-1"), FreshUntil: freshUntil}
+	data, err := e.Marshal()
+	assert.Nil(t, err)
+
+	got, err := UnmarshalEntry(data)
+	assert.Nil(t, err)
+	assert.True(t, proto.Equal(e.Response, got.Response))
+	assert.True(t, got.Fresh(time.Now()))
+	assert.False(t, got.Fresh(freshUntil))
+
+	_, err = UnmarshalEntry([]byte("bad"))
+	assert.NotNil(t, err)
+}
+
+type fakeClock struct {
+	now time.Time
+}
+
+func (c *fakeClock) Now() time.Time                  { return c.now }
+func (c *fakeClock) Since(t time.Time) time.Duration { return c.now.Sub(t) }
+
+func TestLRUStore(t *testing.T) {
+	ctx := context.Background()
+	clk := &fakeClock{now: time.Now()}
+	s := newLRUStore(2, clk)
+
+	s.Set(ctx, "a", []byte("1"), time.Minute)
+	s.Set(ctx, "b", []byte("2"), time.Minute)
+	s.Get(ctx, "a") // a最近使用
+	s.Set(ctx, "c", []byte("3"), 2*time.Minute)
+
+	v, _ := s.Get(ctx, "b")
+	assert.Nil(t, v)
+	v, _ = s.Get(ctx, "a")
+	assert.Equal(t, []byte("1"), v)
+
+	clk.now = clk.now.Add(time.Minute)
+	v, _ = s.Get(ctx, "a")
+	assert.Nil(t, v)
+	v, _ = s.Get(ctx, "c")
+	assert.Equal(t, []byte("3"), v)
+}
+
+func TestRedisStore(t *testing.T) {
+	ctx := context.Background()
+	mr := miniredis.RunT(t)
+	s := NewRedisStore(redis.NewClient(&redis.Options{Addr: mr.Addr()}), "cache:")
+
+	v, err := s.Get(ctx, "a")
+	assert.Nil(t, err)
+	assert.Nil(t, v)
+
+	assert.Nil(t, s.Set(ctx, "a", []byte("1"), time.Minute))
+	v, err = s.Get(ctx, "a")
+	assert.Nil(t, err)
+	assert.Equal(t, []byte("1"), v)
+	assert.Equal(t, time.Minute, mr.TTL("cache:a"))
+}
@@ -0,0 +1,70 @@
+package respcache
+
+import (
+	"context"
+	"time"
+
+	"github.com/go-redis/redis/v8"
+
+	"singer.com/basic/cache"
+	"singer.com/util/clock"
+)
+
+// Store 保存序列化后的响应，实现需要支持并发调用
+type Store interface {
+	// Get 返回缓存的值，不存在或已过期时返回nil
+	Get(ctx context.Context, key string) ([]byte, error)
+	Set(ctx context.Context, key string, value []byte, ttl time.Duration) error
+}
+
+type lruStore struct {
+	cache *cache.Cache[string, []byte]
+}
+
+// NewLRUStore 本地LRU缓存，最多保存size个响应
+func NewLRUStore(size int) Store {
+	return newLRUStore(size, clock.RealClock{})
+}
+
+func newLRUStore(size int, clk clock.PassiveClock) *lruStore {
+	if size <= 0 {
+		size = 1
+	}
+	return &lruStore{cache: cache.New(cache.Config[string, []byte]{
+		Policy:     cache.LRU,
+		MaxEntries: size,
+		Clock:      clk,
+	})}
+}
+
+func (s *lruStore) Get(ctx context.Context, key string) ([]byte, error) {
+	value, _ := s.cache.Get(key)
+	return value, nil
+}
+
+func (s *lruStore) Set(ctx context.Context, key string, value []byte, ttl time.Duration) error {
+	s.cache.SetWithTTL(key, value, ttl)
+	return nil
+}
+
+type redisStore struct {
+	client *redis.Client
+	prefix string
+}
+
+// NewRedisStore 使用redis缓存响应，多个实例共享
+func NewRedisStore(client *redis.Client, prefix string) Store {
+	return &redisStore{client: client, prefix: prefix}
+}
+
+func (s *redisStore) Get(ctx context.Context, key string) ([]byte, error) {
+	value, err := s.client.Get(ctx, s.prefix+key).Bytes()
+	if err == redis.Nil {
+		return nil, nil
+	}
+	return value, err
+}
+
+func (s *redisStore) Set(ctx context.Context, key string, value []byte, ttl time.Duration) error {
+	return s.client.Set(ctx, s.prefix+key, value, ttl).Err()
+}
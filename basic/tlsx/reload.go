@@ -0,0 +1,272 @@
+package tlsx
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"os"
+	"sync"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	log "github.com/sirupsen/logrus"
+	"google.golang.org/grpc/credentials"
+	"singer.com/basic/metric"
+)
+
+// 证书文件修改检查的最小间隔
+const defaultCheckInterval = 10 * time.Second
+
+var certExpiry = metric.NewGaugeVec(prometheus.GaugeOpts{
+	Name: "tls_cert_expiry_timestamp_seconds",
+	Help: "Unix timestamp at which the certificate expires. For a CA file it is the earliest expiry in the bundle.",
+}, "file")
+
+type Config struct {
+	// 证书和私钥，服务端必须配置，客户端配置时用于mTLS
+	CertFile string
+	KeyFile  string
+	// CA证书。服务端配置时校验客户端证书(mTLS)，客户端配置时用于校验服务端证书，不配置时使用系统根证书
+	CAFile string
+	// 客户端校验的服务端证书域名，默认为连接地址中的host
+	ServerName string
+	// 检查文件修改的最小间隔，默认10s
+	CheckInterval time.Duration
+}
+
+// Reloader 在握手时检查证书文件是否修改，修改后重新加载，不需要重启服务。
+// 重新加载失败时继续使用之前的证书
+type Reloader struct {
+	cfg Config
+
+	mtx       sync.RWMutex
+	cert      *tls.Certificate
+	pool      *x509.CertPool
+	modTimes  map[string]time.Time
+	lastCheck time.Time
+}
+
+func NewReloader(cfg Config) (*Reloader, error) {
+	if (cfg.CertFile == "") != (cfg.KeyFile == "") {
+		return nil, errors.New("tlsx: cert file and key file must be configured together")
+	}
+	if cfg.CheckInterval <= 0 {
+		cfg.CheckInterval = defaultCheckInterval
+	}
+	r := &Reloader{cfg: cfg}
+	if err := r.load(); err != nil {
+		return nil, err
+	}
+	return r, nil
+}
+
+func (r *Reloader) files() []string {
+	var files []string
+	for _, f := range []string{r.cfg.CertFile, r.cfg.KeyFile, r.cfg.CAFile} {
+		if f != "" {
+			files = append(files, f)
+		}
+	}
+	return files
+}
+
+func (r *Reloader) load() error {
+	modTimes := make(map[string]time.Time)
+	for _, f := range r.files() {
+		info, err := os.Stat(f)
+		if err != nil {
+			return err
+		}
+		modTimes[f] = info.ModTime()
+	}
+
+	var cert *tls.Certificate
+	if r.cfg.CertFile != "" {
+		c, err := tls.LoadX509KeyPair(r.cfg.CertFile, r.cfg.KeyFile)
+		if err != nil {
+			return fmt.Errorf("tlsx: load key pair failed: %w", err)
+		}
+		leaf, err := x509.ParseCertificate(c.Certificate[0])
+		if err != nil {
+			return fmt.Errorf("tlsx: parse certificate failed: %w", err)
+		}
+		c.Leaf = leaf
+		cert = &c
+	}
+
+	var pool *x509.CertPool
+	var caExpiry time.Time
+	if r.cfg.CAFile != "" {
+		data, err := os.ReadFile(r.cfg.CAFile)
+		if err != nil {
+			return err
+		}
+		pool, caExpiry, err = parseCAs(data)
+		if err != nil {
+			return err
+		}
+	}
+
+	r.mtx.Lock()
+	r.cert, r.pool, r.modTimes, r.lastCheck = cert, pool, modTimes, time.Now()
+	r.mtx.Unlock()
+
+	if cert != nil {
+		certExpiry.WithLabelValues(r.cfg.CertFile).Set(float64(cert.Leaf.NotAfter.Unix()))
+	}
+	if pool != nil {
+		certExpiry.WithLabelValues(r.cfg.CAFile).Set(float64(caExpiry.Unix()))
+	}
+	return nil
+}
+
+// parseCAs 解析PEM格式的CA证书，返回证书池和最早的过期时间
+func parseCAs(data []byte) (*x509.CertPool, time.Time, error) {
+	pool := x509.NewCertPool()
+	var expiry time.Time
+	for {
+		var block *pem.Block
+		block, data = pem.Decode(data)
+		if block == nil {
+			break
+		}
+		if block.Type != "CERTIFICATE" {
+			continue
+		}
+		c, err := x509.ParseCertificate(block.Bytes)
+		if err != nil {
+			return nil, expiry, fmt.Errorf("tlsx: parse ca certificate failed: %w", err)
+		}
+		pool.AddCert(c)
+		if expiry.IsZero() || c.NotAfter.Before(expiry) {
+			expiry = c.NotAfter
+		}
+	}
+	if expiry.IsZero() {
+		return nil, expiry, errors.New("tlsx: no certificate found in ca file")
+	}
+	return pool, expiry, nil
+}
+
+func (r *Reloader) reloadIfModified() {
+	r.mtx.Lock()
+	if time.Since(r.lastCheck) < r.cfg.CheckInterval {
+		r.mtx.Unlock()
+		return
+	}
+	r.lastCheck = time.Now()
+	modTimes := r.modTimes
+	r.mtx.Unlock()
+
+	for _, f := range r.files() {
+		info, err := os.Stat(f)
+		if err != nil || info.ModTime().Equal(modTimes[f]) {
+			continue
+		}
+		if err := r.load(); err != nil {
+			log.Errorf("reload tls certificate failed, err: %v", err)
+		} else {
+			log.Infof("tls certificate %s reloaded", f)
+		}
+		return
+	}
+}
+
+// Certificate 返回当前的证书，没有配置证书时返回nil
+func (r *Reloader) Certificate() *tls.Certificate {
+	r.reloadIfModified()
+	r.mtx.RLock()
+	defer r.mtx.RUnlock()
+	return r.cert
+}
+
+func (r *Reloader) certPool() *x509.CertPool {
+	r.reloadIfModified()
+	r.mtx.RLock()
+	defer r.mtx.RUnlock()
+	return r.pool
+}
+
+// ServerConfig 返回服务端tls配置，配置了CAFile时要求并校验客户端证书
+func (r *Reloader) ServerConfig() *tls.Config {
+	return &tls.Config{
+		MinVersion: tls.VersionTLS12,
+		GetConfigForClient: func(*tls.ClientHelloInfo) (*tls.Config, error) {
+			cert := r.Certificate()
+			if cert == nil {
+				return nil, errors.New("tlsx: server certificate not configured")
+			}
+			cfg := &tls.Config{
+				MinVersion:   tls.VersionTLS12,
+				Certificates: []tls.Certificate{*cert},
+				NextProtos:   []string{"h2"},
+			}
+			if pool := r.certPool(); pool != nil {
+				cfg.ClientCAs = pool
+				cfg.ClientAuth = tls.RequireAndVerifyClientCert
+			}
+			return cfg, nil
+		},
+	}
+}
+
+// ClientConfig 返回客户端tls配置，配置了证书时用于mTLS
+func (r *Reloader) ClientConfig() *tls.Config {
+	cfg := &tls.Config{
+		MinVersion: tls.VersionTLS12,
+		ServerName: r.cfg.ServerName,
+	}
+	if r.cfg.CertFile != "" {
+		cfg.GetClientCertificate = func(*tls.CertificateRequestInfo) (*tls.Certificate, error) {
+			return r.Certificate(), nil
+		}
+	}
+	if r.cfg.CAFile != "" {
+		// RootCAs不能在握手时替换，由VerifyConnection使用当前的CA校验服务端证书
+		cfg.InsecureSkipVerify = true
+		cfg.VerifyConnection = r.verifyServer
+	}
+	return cfg
+}
+
+func (r *Reloader) verifyServer(cs tls.ConnectionState) error {
+	if len(cs.PeerCertificates) == 0 {
+		return errors.New("tlsx: no server certificate")
+	}
+	if cs.ServerName == "" {
+		return errors.New("tlsx: empty server name")
+	}
+	opts := x509.VerifyOptions{
+		Roots:         r.certPool(),
+		DNSName:       cs.ServerName,
+		Intermediates: x509.NewCertPool(),
+	}
+	for _, c := range cs.PeerCertificates[1:] {
+		opts.Intermediates.AddCert(c)
+	}
+	_, err := cs.PeerCertificates[0].Verify(opts)
+	return err
+}
+
+// NewServerCredentials 返回证书自动重新加载的服务端grpc证书
+func NewServerCredentials(cfg Config) (credentials.TransportCredentials, error) {
+	if cfg.CertFile == "" {
+		return nil, errors.New("tlsx: server requires a cert file and a key file")
+	}
+	r, err := NewReloader(cfg)
+	if err != nil {
+		return nil, err
+	}
+	return credentials.NewTLS(r.ServerConfig()), nil
+}
+
+// NewClientCredentials 返回证书自动重新加载的客户端grpc证书
+func NewClientCredentials(cfg Config) (credentials.TransportCredentials, error) {
+	r, err := NewReloader(cfg)
+	if err != nil {
+		return nil, err
+	}
+	return credentials.NewTLS(r.ClientConfig()), nil
+}
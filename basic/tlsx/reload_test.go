@@ -0,0 +1,172 @@
+package tlsx
+
+import (
+	"context"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"math/big"
+	"net"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"github.com/stretchr/testify/assert"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/credentials"
+	"google.golang.org/grpc/health"
+	"google.golang.org/grpc/health/grpc_health_v1"
+	"google.golang.org/grpc/peer"
+)
+
+type testCA struct {
+	cert *x509.Certificate
+	key  *ecdsa.PrivateKey
+}
+
+func newTestCA(t *testing.T) *testCA {
+	key, _ := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	tmpl := &x509.Certificate{
+		SerialNumber:          big.NewInt(1),
+		Subject:               pkix.Name{CommonName: "test ca"},
+		NotBefore:             time.Now().Add(-time.Hour),
+		NotAfter:              time.Now().Add(24 * time.Hour),
+		IsCA:                  true,
+		KeyUsage:              x509.KeyUsageCertSign,
+		BasicConstraintsValid: true,
+	}
+	der, err := x509.CreateCertificate(rand.Reader, tmpl, tmpl, &key.PublicKey, key)
+	assert.Nil(t, err)
+	cert, _ := x509.ParseCertificate(der)
+	return &testCA{cert: cert, key: key}
+}
+
+func (ca *testCA) writeCA(t *testing.T, path string) {
+	assert.Nil(t, os.WriteFile(path, pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: ca.cert.Raw}), 0644))
+}
+
+// issue 签发证书并写入certFile和keyFile，mtime为文件修改时间
+func (ca *testCA) issue(t *testing.T, cn string, serial int64, notAfter time.Time, certFile, keyFile string, mtime time.Time) {
+	key, _ := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	tmpl := &x509.Certificate{
+		SerialNumber: big.NewInt(serial),
+		Subject:      pkix.Name{CommonName: cn},
+		DNSNames:     []string{"localhost"},
+		NotBefore:    time.Now().Add(-time.Hour),
+		NotAfter:     notAfter,
+		KeyUsage:     x509.KeyUsageDigitalSignature,
+		ExtKeyUsage:  []x509.ExtKeyUsage{x509.ExtKeyUsageServerAuth, x509.ExtKeyUsageClientAuth},
+	}
+	der, err := x509.CreateCertificate(rand.Reader, tmpl, ca.cert, &key.PublicKey, ca.key)
+	assert.Nil(t, err)
+	keyDer, _ := x509.MarshalECPrivateKey(key)
+	assert.Nil(t, os.WriteFile(certFile, pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der}), 0644))
+	assert.Nil(t, os.WriteFile(keyFile, pem.EncodeToMemory(&pem.Block{Type: "EC PRIVATE KEY", Bytes: keyDer}), 0600))
+	assert.Nil(t, os.Chtimes(certFile, mtime, mtime))
+	assert.Nil(t, os.Chtimes(keyFile, mtime, mtime))
+}
+
+func startServer(t *testing.T, creds credentials.TransportCredentials) string {
+	lis, err := net.Listen("tcp", "localhost:0")
+	assert.Nil(t, err)
+	s := grpc.NewServer(grpc.Creds(creds))
+	grpc_health_v1.RegisterHealthServer(s, health.NewServer())
+	go s.Serve(lis)
+	t.Cleanup(s.Stop)
+	_, port, _ := net.SplitHostPort(lis.Addr().String())
+	return "localhost:" + port
+}
+
+// check 使用新连接做一次健康检查，返回服务端证书的序列号
+func check(t *testing.T, addr string, creds credentials.TransportCredentials) (int64, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+	conn, err := grpc.DialContext(ctx, addr, grpc.WithTransportCredentials(creds))
+	if err != nil {
+		return 0, err
+	}
+	defer conn.Close()
+	var p peer.Peer
+	_, err = grpc_health_v1.NewHealthClient(conn).Check(ctx, &grpc_health_v1.HealthCheckRequest{}, grpc.Peer(&p))
+	if err != nil {
+		return 0, err
+	}
+	info := p.AuthInfo.(credentials.TLSInfo)
+	return info.State.PeerCertificates[0].SerialNumber.Int64(), nil
+}
+
+func TestMutualTLSReload(t *testing.T) {
+	dir := t.TempDir()
+	ca := newTestCA(t)
+	caFile := filepath.Join(dir, "ca.pem")
+	ca.writeCA(t, caFile)
+	serverCert, serverKey := filepath.Join(dir, "server.pem"), filepath.Join(dir, "server.key")
+	clientCert, clientKey := filepath.Join(dir, "client.pem"), filepath.Join(dir, "client.key")
+	notAfter := time.Now().Add(time.Hour).Truncate(time.Second)
+	ca.issue(t, "server", 10, notAfter, serverCert, serverKey, time.Now().Add(-time.Minute))
+	ca.issue(t, "client", 20, notAfter, clientCert, clientKey, time.Now().Add(-time.Minute))
+
+	serverCreds, err := NewServerCredentials(Config{CertFile: serverCert, KeyFile: serverKey, CAFile: caFile, CheckInterval: time.Millisecond})
+	assert.Nil(t, err)
+	addr := startServer(t, serverCreds)
+	assert.Equal(t, float64(notAfter.Unix()), testutil.ToFloat64(certExpiry.WithLabelValues(serverCert)))
+
+	clientCreds, err := NewClientCredentials(Config{CertFile: clientCert, KeyFile: clientKey, CAFile: caFile})
+	assert.Nil(t, err)
+	serial, err := check(t, addr, clientCreds)
+	assert.Nil(t, err)
+	assert.Equal(t, int64(10), serial)
+
+	// 没有客户端证书
+	noCertCreds, err := NewClientCredentials(Config{CAFile: caFile})
+	assert.Nil(t, err)
+	_, err = check(t, addr, noCertCreds)
+	assert.NotNil(t, err)
+
+	// 替换服务端证书后新连接使用新证书
+	ca.issue(t, "server", 11, notAfter.Add(time.Hour), serverCert, serverKey, time.Now())
+	time.Sleep(5 * time.Millisecond)
+	serial, err = check(t, addr, clientCreds)
+	assert.Nil(t, err)
+	assert.Equal(t, int64(11), serial)
+	assert.Equal(t, float64(notAfter.Add(time.Hour).Unix()), testutil.ToFloat64(certExpiry.WithLabelValues(serverCert)))
+
+	// 证书文件损坏时继续使用之前的证书
+	assert.Nil(t, os.WriteFile(serverKey, []byte("broken"), 0600))
+	time.Sleep(5 * time.Millisecond)
+	serial, err = check(t, addr, clientCreds)
+	assert.Nil(t, err)
+	assert.Equal(t, int64(11), serial)
+}
+
+func TestClientRejectsUnknownCA(t *testing.T) {
+	dir := t.TempDir()
+	ca, other := newTestCA(t), newTestCA(t)
+	serverCert, serverKey := filepath.Join(dir, "server.pem"), filepath.Join(dir, "server.key")
+	ca.issue(t, "server", 10, time.Now().Add(time.Hour), serverCert, serverKey, time.Now())
+	otherCA := filepath.Join(dir, "other.pem")
+	other.writeCA(t, otherCA)
+
+	serverCreds, err := NewServerCredentials(Config{CertFile: serverCert, KeyFile: serverKey})
+	assert.Nil(t, err)
+	addr := startServer(t, serverCreds)
+
+	clientCreds, err := NewClientCredentials(Config{CAFile: otherCA})
+	assert.Nil(t, err)
+	_, err = check(t, addr, clientCreds)
+	assert.NotNil(t, err)
+}
+
+func TestNewReloaderErrors(t *testing.T) {
+	_, err := NewReloader(Config{CertFile: "cert.pem"})
+	assert.NotNil(t, err)
+	_, err = NewServerCredentials(Config{CAFile: "ca.pem"})
+	assert.NotNil(t, err)
+	_, err = NewReloader(Config{CAFile: filepath.Join(t.TempDir(), "missing.pem")})
+	assert.NotNil(t, err)
+}
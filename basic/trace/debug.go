@@ -0,0 +1,57 @@
+package trace
+
+import (
+	"context"
+	"net/http"
+	"strings"
+
+	"github.com/opentracing/opentracing-go"
+	"github.com/opentracing/opentracing-go/ext"
+	"github.com/uber/jaeger-client-go"
+	"google.golang.org/grpc/metadata"
+)
+
+// 常见的调用链传播头，存在时由上游决定是否采样
+var parentHeaders = []string{jaeger.TraceContextHeaderName, "traceparent", "x-b3-traceid", "b3"}
+
+// HasRemoteParent 判断incoming metadata中是否携带了上游的调用链信息
+func HasRemoteParent(md metadata.MD) bool {
+	for _, h := range parentHeaders {
+		if len(md.Get(h)) > 0 {
+			return true
+		}
+	}
+	return false
+}
+
+// StartDebugSpan 创建一个强制采样的span，并写入incoming metadata，
+// 之后的tracing拦截器会把它当作父span，从而整条调用链都被采样。
+// 有上游调用链时作为上游span的子span，上游没有采样时也会强制采样。
+// jaeger和OTEL都通过sampling.priority tag强制采样。
+func StartDebugSpan(ctx context.Context, method string) (opentracing.Span, context.Context) {
+	opts := []opentracing.StartSpanOption{opentracing.Tag{Key: string(ext.SamplingPriority), Value: uint16(1)}}
+	md, _ := metadata.FromIncomingContext(ctx)
+	if HasRemoteParent(md) {
+		carrier := http.Header{}
+		for k, vs := range md {
+			for _, v := range vs {
+				carrier.Add(k, v)
+			}
+		}
+		if parent, err := GlobalTracer().Extract(opentracing.HTTPHeaders, opentracing.HTTPHeadersCarrier(carrier)); err == nil {
+			opts = append(opts, opentracing.ChildOf(parent))
+		}
+	}
+	span := GlobalTracer().StartSpan("debug "+method, opts...)
+
+	header := http.Header{}
+	if err := span.Tracer().Inject(span.Context(), opentracing.HTTPHeaders, opentracing.HTTPHeadersCarrier(header)); err != nil {
+		return span, opentracing.ContextWithSpan(ctx, span)
+	}
+	md = md.Copy()
+	for k, vs := range header {
+		md.Set(strings.ToLower(k), vs...)
+	}
+	ctx = metadata.NewIncomingContext(ctx, md)
+	return span, opentracing.ContextWithSpan(ctx, span)
+}
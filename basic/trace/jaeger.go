@@ -0,0 +1,35 @@
+package trace
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	"github.com/uber/jaeger-client-go"
+	jaegercfg "github.com/uber/jaeger-client-go/config"
+)
+
+func initJaeger(cfg Config) (ShutdownFunc, error) {
+	if cfg.TailSampling != nil {
+		return nil, errors.New("tail sampling is only supported by OTEL")
+	}
+	sampler, err := NewSampler(cfg.Sampler)
+	if err != nil {
+		return nil, err
+	}
+	jcfg := jaegercfg.Configuration{
+		Reporter: &jaegercfg.ReporterConfig{
+			LogSpans: false,
+			// 将 span 发往 jaeger-collector 的服务地址
+			CollectorEndpoint: fmt.Sprintf("http://%s/api/traces", cfg.Endpoint),
+		},
+	}
+	closer, err := jcfg.InitGlobalTracer(cfg.ServiceName, jaegercfg.Logger(jaeger.StdLogger),
+		jaegercfg.Sampler(jaegerSampler{s: sampler}))
+	if err != nil {
+		return nil, err
+	}
+	return func(context.Context) error {
+		return closer.Close()
+	}, nil
+}
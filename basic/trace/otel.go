@@ -0,0 +1,120 @@
+package trace
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"os"
+
+	"github.com/opentracing/opentracing-go"
+	"github.com/sirupsen/logrus"
+	"go.opentelemetry.io/otel"
+	otbridge "go.opentelemetry.io/otel/bridge/opentracing"
+	"go.opentelemetry.io/otel/exporters/otlp/otlptrace/otlptracegrpc"
+	"go.opentelemetry.io/otel/exporters/stdout/stdouttrace"
+	"go.opentelemetry.io/otel/propagation"
+	"go.opentelemetry.io/otel/sdk/resource"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	semconv "go.opentelemetry.io/otel/semconv/v1.12.0"
+	oteltrace "go.opentelemetry.io/otel/trace"
+)
+
+// w3c traceparent + baggage
+var propagator = propagation.NewCompositeTextMapPropagator(propagation.TraceContext{}, propagation.Baggage{})
+
+// bridgeTracer otgrpc使用HTTPHeaders格式传入metadata作为carrier，
+// 而bridge的HTTPHeaders格式只接受HTTPHeadersCarrier，这里转换为同样基于ForeachKey/Set的TextMap格式
+type bridgeTracer struct {
+	*otbridge.BridgeTracer
+}
+
+func newBridgeTracer(tracer oteltrace.Tracer) (opentracing.Tracer, oteltrace.TracerProvider) {
+	bt, provider := otbridge.NewTracerPair(tracer)
+	bt.SetTextMapPropagator(propagator)
+	bt.SetWarningHandler(func(msg string) {
+		logrus.Debugf("opentracing bridge: %s", msg)
+	})
+	return bridgeTracer{bt}, provider
+}
+
+func (b bridgeTracer) Inject(sm opentracing.SpanContext, format interface{}, carrier interface{}) error {
+	return b.BridgeTracer.Inject(sm, textMapFormat(format, carrier), carrier)
+}
+
+func (b bridgeTracer) Extract(format interface{}, carrier interface{}) (opentracing.SpanContext, error) {
+	return b.BridgeTracer.Extract(textMapFormat(format, carrier), carrier)
+}
+
+func textMapFormat(format interface{}, carrier interface{}) interface{} {
+	if format == opentracing.HTTPHeaders {
+		if _, ok := carrier.(opentracing.HTTPHeadersCarrier); !ok {
+			return opentracing.TextMap
+		}
+	}
+	return format
+}
+
+func initOtel(cfg Config) (ShutdownFunc, error) {
+	sampler, err := NewSampler(cfg.Sampler)
+	if err != nil {
+		return nil, err
+	}
+	exporter, closer, err := newOtelExporter(cfg)
+	if err != nil {
+		return nil, err
+	}
+
+	var processor sdktrace.SpanProcessor = sdktrace.NewBatchSpanProcessor(exporter)
+	if cfg.TailSampling != nil {
+		processor = NewTailSamplingProcessor(processor, exporter, *cfg.TailSampling)
+	}
+	res := resource.NewWithAttributes(semconv.SchemaURL, semconv.ServiceNameKey.String(cfg.ServiceName))
+	provider := sdktrace.NewTracerProvider(
+		sdktrace.WithSampler(newOtelSampler(sampler, cfg.TailSampling != nil)),
+		sdktrace.WithSpanProcessor(processor),
+		sdktrace.WithResource(res),
+	)
+
+	tracer, wrapperProvider := newBridgeTracer(provider.Tracer(cfg.ServiceName))
+	otel.SetTextMapPropagator(propagator)
+	otel.SetTracerProvider(wrapperProvider)
+	opentracing.SetGlobalTracer(tracer)
+
+	return func(ctx context.Context) error {
+		err := provider.Shutdown(ctx)
+		if closer != nil {
+			if cerr := closer.Close(); err == nil {
+				err = cerr
+			}
+		}
+		return err
+	}, nil
+}
+
+func newOtelExporter(cfg Config) (sdktrace.SpanExporter, io.Closer, error) {
+	switch cfg.Exporter {
+	case ExporterOTLP, "":
+		opts := []otlptracegrpc.Option{otlptracegrpc.WithEndpoint(cfg.Endpoint)}
+		if cfg.Insecure {
+			opts = append(opts, otlptracegrpc.WithInsecure())
+		}
+		exporter, err := otlptracegrpc.New(context.Background(), opts...)
+		return exporter, nil, err
+	case ExporterStdout:
+		exporter, err := stdouttrace.New(stdouttrace.WithPrettyPrint())
+		return exporter, nil, err
+	case ExporterFile:
+		f, err := os.OpenFile(cfg.FilePath, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0644)
+		if err != nil {
+			return nil, nil, err
+		}
+		exporter, err := stdouttrace.New(stdouttrace.WithWriter(f))
+		if err != nil {
+			f.Close()
+			return nil, nil, err
+		}
+		return exporter, f, nil
+	default:
+		return nil, nil, fmt.Errorf("unsupported otel exporter: %s", cfg.Exporter)
+	}
+}
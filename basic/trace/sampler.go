@@ -0,0 +1,215 @@
+package trace
+
+import (
+	"fmt"
+	"math/rand"
+	"sort"
+	"strings"
+
+	"github.com/opentracing/opentracing-go/ext"
+	"github.com/uber/jaeger-client-go"
+	"go.opentelemetry.io/otel/attribute"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	oteltrace "go.opentelemetry.io/otel/trace"
+	"golang.org/x/time/rate"
+)
+
+// Sampler 对新的调用链(根span)做采样决策，非根span跟随父span的决策
+type Sampler interface {
+	Sample(method string) bool
+}
+
+type SamplerType string
+
+const (
+	SamplerConst         SamplerType = "const"         // Param为1时全部采样，为0时全部不采样
+	SamplerProbabilistic SamplerType = "probabilistic" // Param为采样概率，取值[0,1]
+	SamplerRateLimiting  SamplerType = "ratelimiting"  // Param为每秒最多采样的调用链数
+)
+
+type SamplerConfig struct {
+	// 为空时等同于const 1，即全部采样
+	Type  SamplerType
+	Param float64
+	// 按方法前缀覆盖默认策略，例如"/pb.User/"，最长前缀优先
+	Methods map[string]SamplerConfig
+}
+
+// NewSampler 根据配置创建采样器，SpanInclusionFunc过滤掉的方法(如健康检查)始终不采样
+func NewSampler(cfg SamplerConfig) (Sampler, error) {
+	def, err := newSimpleSampler(cfg)
+	if err != nil {
+		return nil, err
+	}
+	ms := &methodSampler{def: def}
+	for prefix, c := range cfg.Methods {
+		s, err := newSimpleSampler(c)
+		if err != nil {
+			return nil, fmt.Errorf("method %s: %w", prefix, err)
+		}
+		ms.rules = append(ms.rules, methodRule{prefix: prefix, sampler: s})
+	}
+	sort.Slice(ms.rules, func(i, j int) bool {
+		return len(ms.rules[i].prefix) > len(ms.rules[j].prefix)
+	})
+	return ms, nil
+}
+
+func newSimpleSampler(cfg SamplerConfig) (Sampler, error) {
+	switch cfg.Type {
+	case "":
+		return constSampler(true), nil
+	case SamplerConst:
+		return constSampler(cfg.Param != 0), nil
+	case SamplerProbabilistic:
+		if cfg.Param < 0 || cfg.Param > 1 {
+			return nil, fmt.Errorf("probabilistic sampler param must be in [0, 1], got %v", cfg.Param)
+		}
+		return probabilisticSampler(cfg.Param), nil
+	case SamplerRateLimiting:
+		if cfg.Param < 0 {
+			return nil, fmt.Errorf("ratelimiting sampler param must be >= 0, got %v", cfg.Param)
+		}
+		burst := int(cfg.Param)
+		if burst < 1 {
+			burst = 1
+		}
+		return &rateLimitingSampler{limiter: rate.NewLimiter(rate.Limit(cfg.Param), burst)}, nil
+	default:
+		return nil, fmt.Errorf("unsupported sampler type: %s", cfg.Type)
+	}
+}
+
+type constSampler bool
+
+func (s constSampler) Sample(string) bool {
+	return bool(s)
+}
+
+type probabilisticSampler float64
+
+func (s probabilisticSampler) Sample(string) bool {
+	return rand.Float64() < float64(s)
+}
+
+type rateLimitingSampler struct {
+	limiter *rate.Limiter
+}
+
+func (s *rateLimitingSampler) Sample(string) bool {
+	return s.limiter.Allow()
+}
+
+type methodRule struct {
+	prefix  string
+	sampler Sampler
+}
+
+type methodSampler struct {
+	def   Sampler
+	rules []methodRule
+}
+
+func (s *methodSampler) Sample(method string) bool {
+	if !SpanInclusionFunc(nil, method, nil, nil) {
+		return false
+	}
+	for _, r := range s.rules {
+		if strings.HasPrefix(method, r.prefix) {
+			return r.sampler.Sample(method)
+		}
+	}
+	return s.def.Sample(method)
+}
+
+// jaegerSampler 适配jaeger.Sampler，jaeger-debug-id和sampling.priority由jaeger自己处理
+type jaegerSampler struct {
+	s Sampler
+}
+
+func (j jaegerSampler) IsSampled(_ jaeger.TraceID, operation string) (bool, []jaeger.Tag) {
+	return j.s.Sample(operation), nil
+}
+
+func (j jaegerSampler) Close() {}
+
+func (j jaegerSampler) Equal(other jaeger.Sampler) bool {
+	o, ok := other.(jaegerSampler)
+	return ok && o.s == j.s
+}
+
+// otelSampler 适配sdktrace.Sampler，只对根span生效，需要配合sdktrace.ParentBased使用
+type otelSampler struct {
+	s Sampler
+	// 未被采样时的决策，开启尾部采样时为RecordOnly，由tailSamplingProcessor决定是否导出
+	notSampled sdktrace.SamplingDecision
+}
+
+func (o otelSampler) ShouldSample(p sdktrace.SamplingParameters) sdktrace.SamplingResult {
+	decision := o.notSampled
+	if forceSampled(p.Attributes) || o.s.Sample(p.Name) {
+		decision = sdktrace.RecordAndSample
+	}
+	return sdktrace.SamplingResult{
+		Decision:   decision,
+		Tracestate: oteltrace.SpanContextFromContext(p.ParentContext).TraceState(),
+	}
+}
+
+func (o otelSampler) Description() string {
+	return "singer.com/basic/trace.Sampler"
+}
+
+// recordOnlySampler 用于尾部采样时父span未被采样的情况
+type recordOnlySampler struct{}
+
+func (recordOnlySampler) ShouldSample(p sdktrace.SamplingParameters) sdktrace.SamplingResult {
+	return sdktrace.SamplingResult{
+		Decision:   sdktrace.RecordOnly,
+		Tracestate: oteltrace.SpanContextFromContext(p.ParentContext).TraceState(),
+	}
+}
+
+func (recordOnlySampler) Description() string {
+	return "RecordOnly"
+}
+
+// debugSampler 父span未被采样时使用，带有sampling.priority > 0的tag时强制采样(见StartDebugSpan)，否则由s决定
+type debugSampler struct {
+	s sdktrace.Sampler
+}
+
+func (d debugSampler) ShouldSample(p sdktrace.SamplingParameters) sdktrace.SamplingResult {
+	if forceSampled(p.Attributes) {
+		return sdktrace.SamplingResult{
+			Decision:   sdktrace.RecordAndSample,
+			Tracestate: oteltrace.SpanContextFromContext(p.ParentContext).TraceState(),
+		}
+	}
+	return d.s.ShouldSample(p)
+}
+
+func (d debugSampler) Description() string {
+	return "Debug{" + d.s.Description() + "}"
+}
+
+func newOtelSampler(s Sampler, tail bool) sdktrace.Sampler {
+	var notSampled sdktrace.Sampler = sdktrace.NeverSample()
+	decision := sdktrace.Drop
+	if tail {
+		notSampled, decision = recordOnlySampler{}, sdktrace.RecordOnly
+	}
+	return sdktrace.ParentBased(otelSampler{s: s, notSampled: decision},
+		sdktrace.WithRemoteParentNotSampled(debugSampler{notSampled}),
+		sdktrace.WithLocalParentNotSampled(debugSampler{notSampled}))
+}
+
+// forceSampled 与jaeger一致，span带有sampling.priority > 0的tag时强制采样
+func forceSampled(attrs []attribute.KeyValue) bool {
+	for _, kv := range attrs {
+		if string(kv.Key) == string(ext.SamplingPriority) {
+			return kv.Value.Type() == attribute.INT64 && kv.Value.AsInt64() > 0
+		}
+	}
+	return false
+}
@@ -0,0 +1,175 @@
+package trace
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/opentracing/opentracing-go"
+	"github.com/stretchr/testify/assert"
+	"go.opentelemetry.io/otel/codes"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+	oteltrace "go.opentelemetry.io/otel/trace"
+	"google.golang.org/grpc/metadata"
+)
+
+func TestNewSampler(t *testing.T) {
+	s, err := NewSampler(SamplerConfig{})
+	assert.Nil(t, err)
+	assert.True(t, s.Sample("/pb.User/Get"))
+	assert.False(t, s.Sample(healthCheck))
+
+	s, err = NewSampler(SamplerConfig{
+		Type:  SamplerConst,
+		Param: 0,
+		Methods: map[string]SamplerConfig{
+			"/pb.User/":       {Type: SamplerConst, Param: 1},
+			"/pb.User/Delete": {Type: SamplerProbabilistic, Param: 0},
+		},
+	})
+	assert.Nil(t, err)
+	assert.False(t, s.Sample("/pb.Order/Get"))
+	assert.True(t, s.Sample("/pb.User/Get"))
+	assert.False(t, s.Sample("/pb.User/Delete"))
+
+	s, err = NewSampler(SamplerConfig{Type: SamplerRateLimiting, Param: 2})
+	assert.Nil(t, err)
+	sampled := 0
+	for i := 0; i < 10; i++ {
+		if s.Sample("/pb.User/Get") {
+			sampled++
+		}
+	}
+	assert.Equal(t, 2, sampled)
+
+	_, err = NewSampler(SamplerConfig{Type: SamplerProbabilistic, Param: 2})
+	assert.NotNil(t, err)
+	_, err = NewSampler(SamplerConfig{Type: "unknown"})
+	assert.NotNil(t, err)
+}
+
+func newTestBridge(sampler sdktrace.Sampler, processor sdktrace.SpanProcessor) (opentracing.Tracer, *sdktrace.TracerProvider) {
+	provider := sdktrace.NewTracerProvider(sdktrace.WithSampler(sampler), sdktrace.WithSpanProcessor(processor))
+	tracer, _ := newBridgeTracer(provider.Tracer("test"))
+	return tracer, provider
+}
+
+// mdCarrier 与otgrpc中的metadataReaderWriter一致
+type mdCarrier struct {
+	metadata.MD
+}
+
+func (c mdCarrier) Set(key, val string) {
+	c.MD.Append(key, val)
+}
+
+func (c mdCarrier) ForeachKey(handler func(key, val string) error) error {
+	for k, vals := range c.MD {
+		for _, v := range vals {
+			if err := handler(k, v); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+func TestStartDebugSpan(t *testing.T) {
+	exporter := tracetest.NewInMemoryExporter()
+	tracer, provider := newTestBridge(newOtelSampler(constSampler(false), false), sdktrace.NewSimpleSpanProcessor(exporter))
+	old := opentracing.GlobalTracer()
+	opentracing.SetGlobalTracer(tracer)
+	defer opentracing.SetGlobalTracer(old)
+
+	ctx := metadata.NewIncomingContext(context.Background(), metadata.Pairs("x-debug", "1"))
+	span, ctx := StartDebugSpan(ctx, "/pb.User/Get")
+	md, _ := metadata.FromIncomingContext(ctx)
+	assert.True(t, HasRemoteParent(md))
+
+	// 下游根据metadata中的父span继续采样
+	parent, err := tracer.Extract(opentracing.HTTPHeaders, mdCarrier{md})
+	assert.Nil(t, err)
+	tracer.StartSpan("/pb.User/Get", opentracing.ChildOf(parent)).Finish()
+	span.Finish()
+	tracer.StartSpan("/pb.User/Get").Finish()
+
+	provider.ForceFlush(context.Background())
+	assert.Len(t, exporter.GetSpans(), 2)
+}
+
+func TestStartDebugSpanUnsampledParent(t *testing.T) {
+	exporter := tracetest.NewInMemoryExporter()
+	tracer, provider := newTestBridge(newOtelSampler(constSampler(false), false), sdktrace.NewSimpleSpanProcessor(exporter))
+	old := opentracing.GlobalTracer()
+	opentracing.SetGlobalTracer(tracer)
+	defer opentracing.SetGlobalTracer(old)
+
+	// 上游网关没有采样
+	ctx := metadata.NewIncomingContext(context.Background(), metadata.Pairs("x-debug", "1",
+		"traceparent", "00-0af7651916cd43dd8448eb211c80319c-b7ad6b7169203331-00"))
+	span, ctx := StartDebugSpan(ctx, "/pb.User/Get")
+	md, _ := metadata.FromIncomingContext(ctx)
+	parent, err := tracer.Extract(opentracing.HTTPHeaders, mdCarrier{md})
+	assert.Nil(t, err)
+	tracer.StartSpan("/pb.User/Get", opentracing.ChildOf(parent)).Finish()
+	span.Finish()
+
+	// 调试span及其子span被采样，并且属于上游的调用链
+	provider.ForceFlush(context.Background())
+	spans := exporter.GetSpans()
+	assert.Len(t, spans, 2)
+	for _, s := range spans {
+		assert.Equal(t, "0af7651916cd43dd8448eb211c80319c", s.SpanContext.TraceID().String())
+	}
+
+	// 没有调试标记时仍然由上游决定
+	unsampled := metadata.Pairs("traceparent", "00-0af7651916cd43dd8448eb211c80319c-b7ad6b7169203331-00")
+	parent, err = tracer.Extract(opentracing.HTTPHeaders, mdCarrier{unsampled})
+	assert.Nil(t, err)
+	tracer.StartSpan("/pb.User/Get", opentracing.ChildOf(parent)).Finish()
+	provider.ForceFlush(context.Background())
+	assert.Len(t, exporter.GetSpans(), 2)
+}
+
+// keepExporter 关闭时不清空已导出的span
+type keepExporter struct {
+	*tracetest.InMemoryExporter
+}
+
+func (keepExporter) Shutdown(context.Context) error { return nil }
+
+func TestTailSamplingProcessor(t *testing.T) {
+	exporter := keepExporter{tracetest.NewInMemoryExporter()}
+	processor := NewTailSamplingProcessor(sdktrace.NewSimpleSpanProcessor(exporter), exporter,
+		TailSamplingConfig{SlowThreshold: time.Hour})
+	provider := sdktrace.NewTracerProvider(
+		sdktrace.WithSampler(newOtelSampler(constSampler(false), true)),
+		sdktrace.WithSpanProcessor(processor))
+	tracer := provider.Tracer("test")
+
+	// 正常的调用链被丢弃
+	ctx, root := tracer.Start(context.Background(), "ok")
+	_, child := tracer.Start(ctx, "ok-child")
+	child.End()
+	root.End()
+
+	// 出错的调用链整条保留
+	ctx, root = tracer.Start(context.Background(), "failed")
+	_, child = tracer.Start(ctx, "failed-child")
+	child.SetStatus(codes.Error, "boom")
+	child.End()
+	root.End()
+
+	// 慢调用保留
+	start := time.Now().Add(-2 * time.Hour)
+	_, root = tracer.Start(context.Background(), "slow", oteltrace.WithTimestamp(start))
+	root.End()
+
+	assert.Nil(t, provider.Shutdown(context.Background()))
+	names := []string{}
+	for _, s := range exporter.GetSpans() {
+		names = append(names, s.Name)
+	}
+	assert.ElementsMatch(t, []string{"failed", "failed-child", "slow"}, names)
+}
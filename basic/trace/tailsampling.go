@@ -0,0 +1,185 @@
+package trace
+
+import (
+	"context"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/sirupsen/logrus"
+	"go.opentelemetry.io/otel/codes"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	oteltrace "go.opentelemetry.io/otel/trace"
+)
+
+// TailSamplingConfig 本地尾部采样，仅OTEL支持。
+// 头部采样未命中的调用链会先在本地缓存，本地根span结束时，
+// 只有出错或耗时超过SlowThreshold的调用链才会被导出。
+type TailSamplingConfig struct {
+	SlowThreshold    time.Duration // 0表示不按耗时保留
+	MaxTraces        int           // 最多缓存的调用链数，默认10000
+	MaxSpansPerTrace int           // 每条调用链最多缓存的span数，默认1000
+	MaxTraceAge      time.Duration // 本地根span迟迟不结束的调用链在缓存满时被清理，默认1分钟
+	QueueSize        int           // 待导出的调用链队列长度，默认1000
+}
+
+func (c *TailSamplingConfig) withDefaults() TailSamplingConfig {
+	cfg := *c
+	if cfg.MaxTraces <= 0 {
+		cfg.MaxTraces = 10000
+	}
+	if cfg.MaxSpansPerTrace <= 0 {
+		cfg.MaxSpansPerTrace = 1000
+	}
+	if cfg.MaxTraceAge <= 0 {
+		cfg.MaxTraceAge = time.Minute
+	}
+	if cfg.QueueSize <= 0 {
+		cfg.QueueSize = 1000
+	}
+	return cfg
+}
+
+type bufferedTrace struct {
+	firstSeen time.Time
+	spans     []sdktrace.ReadOnlySpan
+	hasError  bool
+}
+
+type tailSamplingProcessor struct {
+	cfg      TailSamplingConfig
+	next     sdktrace.SpanProcessor // 处理头部采样命中的span
+	exporter sdktrace.SpanExporter
+
+	mtx     sync.Mutex
+	traces  map[oteltrace.TraceID]*bufferedTrace
+	stopped bool
+
+	queue   chan []sdktrace.ReadOnlySpan
+	wg      sync.WaitGroup
+	dropped int64
+}
+
+// NewTailSamplingProcessor 头部采样命中的span直接交给next，
+// 未命中但被记录(RecordOnly)的span按调用链缓存，由本地根span结束时决定是否通过exporter导出
+func NewTailSamplingProcessor(next sdktrace.SpanProcessor, exporter sdktrace.SpanExporter, cfg TailSamplingConfig) sdktrace.SpanProcessor {
+	t := &tailSamplingProcessor{
+		cfg:      cfg.withDefaults(),
+		next:     next,
+		exporter: exporter,
+		traces:   make(map[oteltrace.TraceID]*bufferedTrace),
+	}
+	t.queue = make(chan []sdktrace.ReadOnlySpan, t.cfg.QueueSize)
+	t.wg.Add(1)
+	go t.export()
+	return t
+}
+
+func (t *tailSamplingProcessor) OnStart(parent context.Context, s sdktrace.ReadWriteSpan) {
+	t.next.OnStart(parent, s)
+}
+
+func (t *tailSamplingProcessor) OnEnd(s sdktrace.ReadOnlySpan) {
+	sc := s.SpanContext()
+	if sc.IsSampled() {
+		t.next.OnEnd(s)
+		return
+	}
+
+	isLocalRoot := !s.Parent().IsValid() || s.Parent().IsRemote()
+	t.mtx.Lock()
+	if t.stopped {
+		t.mtx.Unlock()
+		return
+	}
+	bt, ok := t.traces[sc.TraceID()]
+	if !ok {
+		if len(t.traces) >= t.cfg.MaxTraces && !t.evictExpired(s.EndTime()) && !isLocalRoot {
+			t.mtx.Unlock()
+			atomic.AddInt64(&t.dropped, 1)
+			return
+		}
+		bt = &bufferedTrace{firstSeen: s.StartTime()}
+		t.traces[sc.TraceID()] = bt
+	}
+	if len(bt.spans) < t.cfg.MaxSpansPerTrace {
+		bt.spans = append(bt.spans, s)
+	}
+	bt.hasError = bt.hasError || isErrorSpan(s)
+	if !isLocalRoot {
+		t.mtx.Unlock()
+		return
+	}
+	delete(t.traces, sc.TraceID())
+	defer t.mtx.Unlock()
+
+	slow := t.cfg.SlowThreshold > 0 && s.EndTime().Sub(s.StartTime()) >= t.cfg.SlowThreshold
+	if !bt.hasError && !slow {
+		return
+	}
+	select {
+	case t.queue <- bt.spans:
+	default:
+		atomic.AddInt64(&t.dropped, 1)
+	}
+}
+
+// evictExpired 清理超过MaxTraceAge的调用链，返回是否腾出了空间，调用方需持有锁
+func (t *tailSamplingProcessor) evictExpired(now time.Time) bool {
+	for id, bt := range t.traces {
+		if now.Sub(bt.firstSeen) > t.cfg.MaxTraceAge {
+			delete(t.traces, id)
+		}
+	}
+	return len(t.traces) < t.cfg.MaxTraces
+}
+
+func isErrorSpan(s sdktrace.ReadOnlySpan) bool {
+	if s.Status().Code == codes.Error {
+		return true
+	}
+	for _, kv := range s.Attributes() {
+		if kv.Key == "error" && kv.Value.AsBool() {
+			return true
+		}
+	}
+	return false
+}
+
+func (t *tailSamplingProcessor) export() {
+	defer t.wg.Done()
+	for spans := range t.queue {
+		ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+		if err := t.exporter.ExportSpans(ctx, spans); err != nil {
+			logrus.Errorf("export tail sampled spans failed, err: %v", err)
+		}
+		cancel()
+	}
+}
+
+func (t *tailSamplingProcessor) Shutdown(ctx context.Context) error {
+	t.mtx.Lock()
+	if !t.stopped {
+		t.stopped = true
+		close(t.queue)
+	}
+	t.mtx.Unlock()
+	if dropped := atomic.LoadInt64(&t.dropped); dropped > 0 {
+		logrus.Warnf("tail sampling dropped %d spans or traces because the buffer was full", dropped)
+	}
+	done := make(chan struct{})
+	go func() {
+		t.wg.Wait()
+		close(done)
+	}()
+	select {
+	case <-done:
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+	return t.next.Shutdown(ctx)
+}
+
+func (t *tailSamplingProcessor) ForceFlush(ctx context.Context) error {
+	return t.next.ForceFlush(ctx)
+}
@@ -2,10 +2,14 @@ package trace
 
 import (
 	"context"
+	"net/http"
+	"net/url"
+	"strings"
 
 	"github.com/google/uuid"
 	"github.com/opentracing/opentracing-go"
 	"github.com/uber/jaeger-client-go"
+	oteltrace "go.opentelemetry.io/otel/trace"
 )
 
 type TraceID interface {
@@ -15,6 +19,17 @@ type TraceID interface {
 	String() string
 }
 
+// hexID 从传播头中解析出的16进制id
+type hexID string
+
+func (id hexID) IsValid() bool {
+	return strings.Trim(string(id), "0") != ""
+}
+
+func (id hexID) String() string {
+	return string(id)
+}
+
 func traceIDConversionRequestID(traceID TraceID) string {
 	if !traceID.IsValid() {
 		return uuid.New().String()
@@ -22,14 +37,64 @@ func traceIDConversionRequestID(traceID TraceID) string {
 	return traceID.String()
 }
 
+// TraceIDFromContext 返回ctx中span的traceID，不依赖具体的tracer实现
 func TraceIDFromContext(ctx context.Context) TraceID {
-	return jaegerTraceIDFromContext(ctx)
+	traceID, _ := idsFromContext(ctx)
+	return traceID
+}
+
+// SpanIDFromContext 返回ctx中span的spanID，不依赖具体的tracer实现
+func SpanIDFromContext(ctx context.Context) string {
+	_, spanID := idsFromContext(ctx)
+	return spanID
 }
 
-func jaegerTraceIDFromContext(ctx context.Context) TraceID {
+func idsFromContext(ctx context.Context) (TraceID, string) {
 	sp := opentracing.SpanFromContext(ctx)
 	if sp == nil {
-		return jaeger.TraceID{}
+		// 直接使用OpenTelemetry API创建的span
+		if sc := oteltrace.SpanContextFromContext(ctx); sc.IsValid() {
+			return sc.TraceID(), sc.SpanID().String()
+		}
+		return hexID(""), ""
+	}
+	if sc, ok := sp.Context().(jaeger.SpanContext); ok {
+		return sc.TraceID(), sc.SpanID().String()
+	}
+	return idsFromSpanContext(sp.Tracer(), sp.Context())
+}
+
+// idsFromSpanContext 将span context注入到header中，再按照常见的传播格式解析
+func idsFromSpanContext(tracer opentracing.Tracer, sc opentracing.SpanContext) (TraceID, string) {
+	header := http.Header{}
+	if err := tracer.Inject(sc, opentracing.HTTPHeaders, opentracing.HTTPHeadersCarrier(header)); err != nil {
+		return hexID(""), ""
+	}
+
+	// w3c: version-traceid-spanid-flags
+	if v := header.Get("traceparent"); v != "" {
+		if parts := strings.Split(v, "-"); len(parts) == 4 {
+			return hexID(parts[1]), parts[2]
+		}
+	}
+	// jaeger: traceid:spanid:parentid:flags
+	if v := header.Get(jaeger.TraceContextHeaderName); v != "" {
+		if unescaped, err := url.QueryUnescape(v); err == nil {
+			v = unescaped
+		}
+		if parts := strings.Split(v, ":"); len(parts) == 4 {
+			return hexID(parts[0]), parts[1]
+		}
+	}
+	// zipkin b3 multi header
+	if v := header.Get("x-b3-traceid"); v != "" {
+		return hexID(v), header.Get("x-b3-spanid")
+	}
+	// zipkin b3 single header: traceid-spanid-sampled-parentid
+	if v := header.Get("b3"); v != "" {
+		if parts := strings.Split(v, "-"); len(parts) >= 2 {
+			return hexID(parts[0]), parts[1]
+		}
 	}
-	return sp.Context().(jaeger.SpanContext).TraceID()
+	return hexID(""), ""
 }
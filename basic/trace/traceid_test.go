@@ -0,0 +1,67 @@
+package trace
+
+import (
+	"context"
+	"testing"
+
+	"github.com/opentracing/opentracing-go"
+	"github.com/opentracing/opentracing-go/mocktracer"
+	"github.com/stretchr/testify/assert"
+	"github.com/uber/jaeger-client-go"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+)
+
+func TestTraceIDFromContextNoSpan(t *testing.T) {
+	assert.False(t, TraceIDFromContext(context.Background()).IsValid())
+	assert.Equal(t, "", SpanIDFromContext(context.Background()))
+}
+
+func TestTraceIDFromContextJaeger(t *testing.T) {
+	tracer, closer := jaeger.NewTracer("test", jaeger.NewConstSampler(true), jaeger.NewNullReporter())
+	defer closer.Close()
+
+	sp := tracer.StartSpan("op")
+	defer sp.Finish()
+	ctx := opentracing.ContextWithSpan(context.Background(), sp)
+
+	sc := sp.Context().(jaeger.SpanContext)
+	traceID := TraceIDFromContext(ctx)
+	assert.True(t, traceID.IsValid())
+	assert.Equal(t, sc.TraceID().String(), traceID.String())
+	assert.Equal(t, sc.SpanID().String(), SpanIDFromContext(ctx))
+}
+
+func TestTraceIDFromContextOtelBridge(t *testing.T) {
+	provider := sdktrace.NewTracerProvider()
+	defer provider.Shutdown(context.Background())
+	tracer, _ := newBridgeTracer(provider.Tracer("test"))
+
+	sp := tracer.StartSpan("op")
+	defer sp.Finish()
+	ctx := opentracing.ContextWithSpan(context.Background(), sp)
+
+	traceID := TraceIDFromContext(ctx)
+	assert.True(t, traceID.IsValid())
+	assert.Len(t, traceID.String(), 32)
+	assert.Len(t, SpanIDFromContext(ctx), 16)
+}
+
+func TestTraceIDFromContextUnknownTracer(t *testing.T) {
+	tracer := mocktracer.New()
+	sp := tracer.StartSpan("op")
+	defer sp.Finish()
+	ctx := opentracing.ContextWithSpan(context.Background(), sp)
+
+	// 无法识别的传播格式不会panic
+	assert.False(t, TraceIDFromContext(ctx).IsValid())
+}
+
+func TestInitNone(t *testing.T) {
+	shutdown, err := Init(Config{Service: NONE})
+	assert.Nil(t, err)
+	assert.Nil(t, shutdown(context.Background()))
+	assert.IsType(t, opentracing.NoopTracer{}, opentracing.GlobalTracer())
+
+	_, err = Init(Config{Service: OTEL, Exporter: "unknown"})
+	assert.NotNil(t, err)
+}
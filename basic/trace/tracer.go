@@ -1,37 +1,107 @@
 package trace
 
 import (
+	"context"
 	"fmt"
 
-	"github.com/uber/jaeger-client-go"
-	jaegercfg "github.com/uber/jaeger-client-go/config"
+	"github.com/opentracing/opentracing-go"
 )
 
-//目前仅支持jeager
+// 服务端、客户端拦截器以及gorm插件都基于opentracing.GlobalTracer，
+// 选择OTEL时通过opentracing bridge把span转交给OpenTelemetry SDK，因此调用方无需关心具体后端。
 
 type TraceService int32
 
 const (
 	NONE TraceService = iota
 	JAEGER
+	OTEL
 )
 
-func InitOpentracing(serviceName, endpoint string) {
-	cfg := jaegercfg.Configuration{
-		// 将采样频率设置为 1，每一个 span 都记录，方便查看测试结果
-		Sampler: &jaegercfg.SamplerConfig{
-			Type:  jaeger.SamplerTypeConst,
-			Param: 1,
-		},
-		Reporter: &jaegercfg.ReporterConfig{
-			LogSpans: false,
-			// 将 span 发往 jaeger-collector 的服务地址
-			CollectorEndpoint: fmt.Sprintf("http://%s/api/traces", endpoint),
-		},
+func (s TraceService) String() string {
+	switch s {
+	case NONE:
+		return "NONE"
+	case JAEGER:
+		return "JAEGER"
+	case OTEL:
+		return "OTEL"
+	default:
+		return fmt.Sprintf("TraceService(%d)", int32(s))
+	}
+}
+
+// Exporter OpenTelemetry span导出方式
+type Exporter string
+
+const (
+	ExporterOTLP   Exporter = "otlp"   // 通过grpc导出到OTLP collector
+	ExporterStdout Exporter = "stdout" // 输出到标准输出，用于调试
+	ExporterFile   Exporter = "file"   // 以json格式写入本地文件
+)
+
+type Config struct {
+	Service     TraceService
+	ServiceName string
+	// JAEGER: collector地址(host:port)；OTEL + ExporterOTLP: OTLP grpc地址(host:port)
+	Endpoint string
+	// 仅OTEL使用，默认ExporterOTLP
+	Exporter Exporter
+	// ExporterFile的文件路径
+	FilePath string
+	// OTLP是否使用明文连接
+	Insecure bool
+	// 头部采样策略，默认全部采样
+	Sampler SamplerConfig
+	// 本地尾部采样，仅OTEL支持，为nil时不开启
+	TailSampling *TailSamplingConfig
+}
+
+// ShutdownFunc 刷新尚未上报的span并释放资源
+type ShutdownFunc func(ctx context.Context) error
+
+func noopShutdown(context.Context) error { return nil }
+
+// Init 按照配置初始化全局tracer，并返回用于退出时刷新span的函数
+func Init(cfg Config) (ShutdownFunc, error) {
+	switch cfg.Service {
+	case NONE:
+		opentracing.SetGlobalTracer(opentracing.NoopTracer{})
+		return noopShutdown, nil
+	case JAEGER:
+		return initJaeger(cfg)
+	case OTEL:
+		return initOtel(cfg)
+	default:
+		return nil, fmt.Errorf("unsupported trace service: %v", cfg.Service)
 	}
-	_, err := cfg.InitGlobalTracer(serviceName, jaegercfg.Logger(jaeger.StdLogger))
+}
+
+// InitOpentracing 使用jaeger初始化全局tracer，失败时panic
+func InitOpentracing(serviceName, endpoint string) {
+	_, err := Init(Config{Service: JAEGER, ServiceName: serviceName, Endpoint: endpoint})
 	if err != nil {
 		panic(fmt.Sprintf("ERROR: cannot init Jaeger: %v\n", err))
 	}
-	return
+}
+
+// globalTracer 每次调用时才读取opentracing.GlobalTracer，
+// 使得在Init之前创建的拦截器、gorm插件也能使用最终选择的后端
+type globalTracer struct{}
+
+// GlobalTracer 返回始终代理到当前全局tracer的opentracing.Tracer
+func GlobalTracer() opentracing.Tracer {
+	return globalTracer{}
+}
+
+func (globalTracer) StartSpan(operationName string, opts ...opentracing.StartSpanOption) opentracing.Span {
+	return opentracing.GlobalTracer().StartSpan(operationName, opts...)
+}
+
+func (globalTracer) Inject(sm opentracing.SpanContext, format interface{}, carrier interface{}) error {
+	return opentracing.GlobalTracer().Inject(sm, format, carrier)
+}
+
+func (globalTracer) Extract(format interface{}, carrier interface{}) (opentracing.SpanContext, error) {
+	return opentracing.GlobalTracer().Extract(format, carrier)
 }
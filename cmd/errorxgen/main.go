@@ -0,0 +1,54 @@
+// errorxgen 根据错误码目录(yaml/json)生成Go常量文件和markdown错误码表
+//
+//	go run ./cmd/errorxgen -catalog user/errors.yaml -pkg user -go user/errcode_gen.go -md user/errcode.md
+package main
+
+import (
+	"bytes"
+	"flag"
+	"os"
+
+	"github.com/sirupsen/logrus"
+	"singer.com/basic/errorx"
+)
+
+func main() {
+	catalog := flag.String("catalog", "", "error code catalog file, .yaml/.yml/.json")
+	pkg := flag.String("pkg", "", "package name of the generated go file")
+	goOut := flag.String("go", "", "output path of the generated go file")
+	mdOut := flag.String("md", "", "output path of the generated markdown table")
+	flag.Parse()
+
+	if *catalog == "" || (*goOut == "" && *mdOut == "") {
+		flag.Usage()
+		os.Exit(2)
+	}
+
+	c, err := errorx.LoadCatalog(*catalog)
+	if err != nil {
+		logrus.Fatalf("load catalog %s failed: %v", *catalog, err)
+	}
+
+	if *goOut != "" {
+		if *pkg == "" {
+			logrus.Fatal("-pkg is required when generating go file")
+		}
+		var buf bytes.Buffer
+		if err := errorx.GenerateGo(&buf, *pkg, c); err != nil {
+			logrus.Fatalf("generate go failed: %v", err)
+		}
+		if err := os.WriteFile(*goOut, buf.Bytes(), 0644); err != nil {
+			logrus.Fatalf("write %s failed: %v", *goOut, err)
+		}
+	}
+
+	if *mdOut != "" {
+		var buf bytes.Buffer
+		if err := errorx.GenerateMarkdown(&buf, c); err != nil {
+			logrus.Fatalf("generate markdown failed: %v", err)
+		}
+		if err := os.WriteFile(*mdOut, buf.Bytes(), 0644); err != nil {
+			logrus.Fatalf("write %s failed: %v", *mdOut, err)
+		}
+	}
+}
@@ -4,8 +4,8 @@ import (
 	"context"
 
 	"github.com/grpc-ecosystem/grpc-opentracing/go/otgrpc"
-	"github.com/opentracing/opentracing-go"
 	"google.golang.org/grpc"
+	"singer.com/basic/tlsx"
 	"singer.com/basic/trace"
 	"singer.com/rpc/clientinterceptor"
 )
@@ -23,7 +23,10 @@ type Client interface {
 
 func NewClient(target string, opts ...ClientOption) (Client, error) {
 	var c client
-	options := c.dialOptions(opts...)
+	options, err := c.dialOptions(opts...)
+	if err != nil {
+		return nil, err
+	}
 	conn, err := grpc.DialContext(context.Background(), target, options...)
 	if err != nil {
 		return nil, err
@@ -36,17 +39,27 @@ func (c *client) Conn() *grpc.ClientConn {
 	return c.conn
 }
 
-func (c *client) dialOptions(opts ...ClientOption) []grpc.DialOption {
+func (c *client) dialOptions(opts ...ClientOption) ([]grpc.DialOption, error) {
 	opt := newDefaultClientOptions()
 	for _, o := range opts {
 		o(&opt)
 	}
+	if opt.tlsConfig != nil {
+		creds, err := tlsx.NewClientCredentials(*opt.tlsConfig)
+		if err != nil {
+			return nil, err
+		}
+		opt.creds = creds
+	}
 
 	gOptions := make([]grpc.DialOption, 0)
 	gOptions = append(gOptions, grpc.WithTransportCredentials(opt.creds))
+	if opt.perRPCCreds != nil {
+		gOptions = append(gOptions, grpc.WithPerRPCCredentials(opt.perRPCCreds))
+	}
 	if opt.enableTrace {
 		gOptions = append(gOptions, grpc.WithUnaryInterceptor(
-			otgrpc.OpenTracingClientInterceptor(opentracing.GlobalTracer(),
+			otgrpc.OpenTracingClientInterceptor(trace.GlobalTracer(),
 				otgrpc.SpanDecorator(otgrpc.SpanDecoratorFunc(trace.SpanDecoratorError)))))
 	}
 	if opt.block {
@@ -64,6 +77,11 @@ func (c *client) dialOptions(opts ...ClientOption) []grpc.DialOption {
 		streamInterceptors = append(streamInterceptors, clientinterceptor.StreamMetaInterceptor)
 	}
 
+	if opt.enableMetrics {
+		unaryInterceptors = append(unaryInterceptors, clientinterceptor.UnaryMetricsInterceptor)
+		streamInterceptors = append(streamInterceptors, clientinterceptor.StreamMetricsInterceptor)
+	}
+
 	if opt.breaker != nil {
 		unaryInterceptors = append(unaryInterceptors, clientinterceptor.UnaryBreakerInterceptor(opt.breaker))
 		streamInterceptors = append(streamInterceptors, clientinterceptor.StreamBreakerInterceptor(opt.breaker))
@@ -81,5 +99,5 @@ func (c *client) dialOptions(opts ...ClientOption) []grpc.DialOption {
 	gOptions = append(gOptions, grpc.WithChainUnaryInterceptor(unaryInterceptors...))
 	gOptions = append(gOptions, grpc.WithChainStreamInterceptor(streamInterceptors...))
 	gOptions = append(gOptions, opt.dialOptions...)
-	return gOptions
+	return gOptions, nil
 }
@@ -7,6 +7,7 @@ import (
 	"google.golang.org/grpc/credentials"
 	"google.golang.org/grpc/credentials/insecure"
 	"singer.com/basic/breaker"
+	"singer.com/basic/tlsx"
 	"singer.com/rpc/clientinterceptor"
 )
 
@@ -15,11 +16,14 @@ type ClientOptions struct {
 	dialOptions   []grpc.DialOption                //grpc 连接options
 	enableTrace   bool                             //分布式调用链追踪
 	enableMeta    bool                             //元数据携带
+	enableMetrics bool                             //调用统计
 	breaker       breaker.Breaker                  //熔断器
 	timeout       time.Duration                    //超时调用
 	slowThreshold time.Duration                    //慢日志阈值
 	maxMsgSize    int                              //最大消息大小
 	creds         credentials.TransportCredentials //连接证书
+	perRPCCreds   credentials.PerRPCCredentials    //每次请求携带的凭证
+	tlsConfig     *tlsx.Config                     //自动重新加载的证书，优先于creds
 	retryConf     *clientinterceptor.RetryConfigs  //重试配置
 }
 
@@ -50,6 +54,13 @@ func EnableMeta() ClientOption {
 	}
 }
 
+// WithMetrics 统计调用的请求数、错误数、耗时、处理中的请求数和消息大小，需要先初始化metric
+func WithMetrics() ClientOption {
+	return func(co *ClientOptions) {
+		co.enableMetrics = true
+	}
+}
+
 func WithBreaker(bkr breaker.Breaker) ClientOption {
 	return func(co *ClientOptions) {
 		co.breaker = bkr
@@ -86,6 +97,21 @@ func WithCreds(creds credentials.TransportCredentials) ClientOption {
 	}
 }
 
+// WithTLS 使用证书文件启用TLS，caFile为空时使用系统根证书校验服务端，certFile和keyFile不为空时使用mTLS，
+// 证书文件修改后自动重新加载
+func WithTLS(certFile, keyFile, caFile string) ClientOption {
+	return func(co *ClientOptions) {
+		co.tlsConfig = &tlsx.Config{CertFile: certFile, KeyFile: keyFile, CAFile: caFile}
+	}
+}
+
+// WithPerRPCCredentials 每次请求自动携带凭证，如auth.NewTokenCredentials、auth.NewAPIKeyCredentials
+func WithPerRPCCredentials(creds credentials.PerRPCCredentials) ClientOption {
+	return func(co *ClientOptions) {
+		co.perRPCCreds = creds
+	}
+}
+
 func WithRetry(retries map[string]clientinterceptor.RetryConfig) ClientOption {
 	return func(co *ClientOptions) {
 		co.retryConf = &clientinterceptor.RetryConfigs{Configs: retries}
@@ -4,19 +4,19 @@ import (
 	"context"
 
 	"google.golang.org/grpc"
-	"google.golang.org/grpc/metadata"
+	"singer.com/basic/meta"
 )
 
+// UnaryMetaInterceptor 将requestid、调试标记、白名单中的key和baggage传递给下游，
+// authorization等逐跳的header不会被透传
 func UnaryMetaInterceptor(ctx context.Context, method string, req, reply interface{},
 	cc *grpc.ClientConn, invoker grpc.UnaryInvoker, opts ...grpc.CallOption) error {
-	md, _ := metadata.FromIncomingContext(ctx)
-	ctx = metadata.NewOutgoingContext(ctx, md)
+	ctx = meta.OutgoingContext(ctx)
 	return invoker(ctx, method, req, reply, cc, opts...)
 }
 
 func StreamMetaInterceptor(ctx context.Context, desc *grpc.StreamDesc, cc *grpc.ClientConn,
 	method string, streamer grpc.Streamer, opts ...grpc.CallOption) (grpc.ClientStream, error) {
-	md, _ := metadata.FromIncomingContext(ctx)
-	ctx = metadata.NewOutgoingContext(ctx, md)
+	ctx = meta.OutgoingContext(ctx)
 	return streamer(ctx, desc, cc, method, opts...)
 }
@@ -0,0 +1,63 @@
+package clientinterceptor
+
+import (
+	"context"
+	"io"
+
+	"google.golang.org/grpc"
+	"singer.com/basic/metric"
+)
+
+// UnaryMetricsInterceptor 记录请求数、错误数、耗时、处理中的请求数和消息大小
+func UnaryMetricsInterceptor(ctx context.Context, method string, req, reply interface{},
+	cc *grpc.ClientConn, invoker grpc.UnaryInvoker, opts ...grpc.CallOption) error {
+	r := metric.StartRPC(metric.RPCClient, method)
+	r.MsgSent(req)
+	err := invoker(ctx, method, req, reply, cc, opts...)
+	if err == nil {
+		r.MsgReceived(reply)
+	}
+	r.Done(err)
+	return err
+}
+
+// StreamMetricsInterceptor 同UnaryMetricsInterceptor，调用在RecvMsg返回错误(包括io.EOF)时结束，
+// 没有读到流结束的调用不会被统计
+func StreamMetricsInterceptor(ctx context.Context, desc *grpc.StreamDesc, cc *grpc.ClientConn, method string,
+	streamer grpc.Streamer, opts ...grpc.CallOption) (grpc.ClientStream, error) {
+	r := metric.StartRPC(metric.RPCClient, method)
+	cs, err := streamer(ctx, desc, cc, method, opts...)
+	if err != nil {
+		r.Done(err)
+		return nil, err
+	}
+	return &metricsClientStream{ClientStream: cs, recorder: r}, nil
+}
+
+type metricsClientStream struct {
+	grpc.ClientStream
+	recorder *metric.RPCRecorder
+}
+
+func (s *metricsClientStream) SendMsg(m interface{}) error {
+	err := s.ClientStream.SendMsg(m)
+	if err == nil {
+		s.recorder.MsgSent(m)
+	} else if err != io.EOF {
+		s.recorder.Done(err)
+	}
+	return err
+}
+
+func (s *metricsClientStream) RecvMsg(m interface{}) error {
+	err := s.ClientStream.RecvMsg(m)
+	switch err {
+	case nil:
+		s.recorder.MsgReceived(m)
+	case io.EOF:
+		s.recorder.Done(nil)
+	default:
+		s.recorder.Done(err)
+	}
+	return err
+}
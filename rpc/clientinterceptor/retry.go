@@ -5,12 +5,14 @@ import (
 	"strconv"
 	"time"
 
+	"github.com/google/uuid"
 	"github.com/sirupsen/logrus"
 	"google.golang.org/grpc"
 	"google.golang.org/grpc/codes"
 	"google.golang.org/grpc/metadata"
 	"google.golang.org/grpc/status"
 	"singer.com/basic/errorx"
+	"singer.com/basic/meta"
 )
 
 const (
@@ -43,13 +45,18 @@ func UnaryRetryInterceptor(rc RetryConfigs) grpc.UnaryClientInterceptor {
 			if config.max == 0 {
 				return invoker(parentCtx, method, req, reply, cc, opts...)
 			}
+			// 重试的请求都携带相同的幂等键，服务端开启幂等后重复的请求不会被重复处理
+			if meta.OutgoingIdempotencyKey(parentCtx) == "" {
+				parentCtx = meta.WithIdempotencyKey(parentCtx, uuid.New().String())
+			}
 			var lastErr error
 			for attempt := uint(0); attempt < config.max; attempt++ {
 				if err := waitRetryBackoff(attempt, parentCtx, &config); err != nil {
 					return err
 				}
-				callCtx := perCallContext(parentCtx, &config, attempt)
+				callCtx, cancel := perCallContext(parentCtx, &config, attempt)
 				lastErr = invoker(callCtx, method, req, reply, cc, opts...)
+				cancel()
 				// TODO: Maybe dial and transport errors should be retriable?
 				if lastErr == nil {
 					return nil
@@ -105,10 +112,10 @@ func contextErrToGrpcErr(err error) error {
 	}
 }
 
-func perCallContext(parentCtx context.Context, rc *RetryConfig, attempt uint) context.Context {
-	ctx := parentCtx
+func perCallContext(parentCtx context.Context, rc *RetryConfig, attempt uint) (context.Context, context.CancelFunc) {
+	ctx, cancel := parentCtx, context.CancelFunc(func() {})
 	if rc.perCallTimeout != 0 {
-		ctx, _ = context.WithTimeout(ctx, rc.perCallTimeout)
+		ctx, cancel = context.WithTimeout(ctx, rc.perCallTimeout)
 	}
 	if attempt > 0 && rc.includeHeader {
 		md, ok := metadata.FromOutgoingContext(ctx)
@@ -118,7 +125,7 @@ func perCallContext(parentCtx context.Context, rc *RetryConfig, attempt uint) co
 		md.Set(kAttemptMetadataKey, strconv.FormatUint(uint64(attempt), 10))
 		ctx = metadata.NewOutgoingContext(ctx, md)
 	}
-	return ctx
+	return ctx, cancel
 }
 
 func isContextError(err error) bool {
@@ -131,9 +138,9 @@ func isRetriable(err error, rc *RetryConfig) bool {
 		// context errors are not retriable based on user settings.
 		return false
 	}
-	errCode := errorx.ErrorCode(status.Code(err))
+	// codes 中既可以配置业务错误码也可以配置grpc code, 兼容直接以业务错误码作为grpc code的旧格式
 	for _, code := range rc.codes {
-		if code == errCode {
+		if errorx.IsCode(err, code) {
 			return true
 		}
 	}
@@ -11,6 +11,7 @@ import (
 	"google.golang.org/grpc/metadata"
 	"google.golang.org/grpc/status"
 	"singer.com/basic/errorx"
+	"singer.com/basic/meta"
 )
 
 func TestWaitRetryBackoff(t *testing.T) {
@@ -52,8 +53,9 @@ func TestPerCallContext(t *testing.T) {
 	pctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
 	defer cancel()
 	for i := 0; i < int(rc.max); i++ {
-		ctx := perCallContext(pctx, rc, 1)
+		ctx, callCancel := perCallContext(pctx, rc, 1)
 		t.Log(ctx)
+		callCancel()
 	}
 }
 
@@ -79,6 +81,10 @@ func TestIsRetriable(t *testing.T) {
 	assert.False(t, isRetriable(status.Error(codes.Canceled, "cancel"), rc))
 	assert.False(t, isRetriable(status.Error(codes.Code(errorx.DB_ERROR), "db error"), rc))
 	assert.False(t, isRetriable(status.Error(codes.DeadlineExceeded, "deadline"), rc))
+
+	// 业务错误码通过ErrorInfo携带
+	assert.True(t, isRetriable(errorx.NewErrCode(errorx.TOKEN_EXPIRE_ERROR).GRPCStatus().Err(), rc))
+	assert.False(t, isRetriable(errorx.NewErrCode(errorx.DB_ERROR).GRPCStatus().Err(), rc))
 }
 
 func TestUnaryRetryInterceptor(t *testing.T) {
@@ -108,3 +114,34 @@ func TestUnaryRetryInterceptor(t *testing.T) {
 	})
 	t.Log(err)
 }
+
+func TestUnaryRetryInterceptorIdempotencyKey(t *testing.T) {
+	interceptor := UnaryRetryInterceptor(RetryConfigs{
+		Configs: map[string]RetryConfig{
+			"/api/Create": {max: 3, codes: []errorx.ErrorCode{errorx.ErrorCode(codes.Unavailable)}},
+		},
+	})
+	var keys []string
+	invoker := func(ctx context.Context, method string, req, reply interface{}, cc *grpc.ClientConn, opts ...grpc.CallOption) error {
+		keys = append(keys, meta.OutgoingIdempotencyKey(ctx))
+		return status.Error(codes.Unavailable, "unavailable")
+	}
+
+	err := interceptor(context.Background(), "/api/Create", nil, nil, nil, invoker)
+	assert.Equal(t, codes.Unavailable, status.Code(err))
+	assert.Len(t, keys, 3)
+	assert.NotEmpty(t, keys[0])
+	assert.Equal(t, keys[0], keys[1])
+	assert.Equal(t, keys[0], keys[2])
+
+	// 使用调用方设置的幂等键
+	keys = nil
+	ctx := meta.WithIdempotencyKey(context.Background(), "order-1")
+	interceptor(ctx, "/api/Create", nil, nil, nil, invoker)
+	assert.Equal(t, []string{"order-1", "order-1", "order-1"}, keys)
+
+	// 没有配置重试的方法不携带幂等键
+	keys = nil
+	interceptor(context.Background(), "/api/Get", nil, nil, nil, invoker)
+	assert.Equal(t, []string{""}, keys)
+}
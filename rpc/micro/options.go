@@ -7,8 +7,17 @@ import (
 	"github.com/spf13/viper"
 	"google.golang.org/grpc/credentials"
 	"google.golang.org/grpc/keepalive"
+	"singer.com/basic/auth"
 	"singer.com/basic/breaker"
+	"singer.com/basic/idempotency"
 	"singer.com/basic/limit"
+	"singer.com/basic/log"
+	"singer.com/basic/metric"
+	"singer.com/basic/pprof"
+	"singer.com/basic/respcache"
+	"singer.com/basic/tlsx"
+	"singer.com/basic/trace"
+	"singer.com/util/recovery"
 )
 
 var defaultKaep = keepalive.EnforcementPolicy{
@@ -28,12 +37,16 @@ type Options struct {
 	metricListenAddr      string                           //统计监听地址
 	metricName            string                           //统计名
 	enableMetric          bool                             //使能统计
+	metricConfig          *metric.Config                   //统计配置，优先于metricName和metricListenAddr
+	watchdog              *metric.WatchdogConfig           //goroutine和堆内存监控
 	logListenAddr         string                           //动态修改日志级别的监听地址
 	enableLogServer       bool                             //使能日志监听
 	slowThreshold         time.Duration                    //慢日志阈值
 	openTraceAddress      string                           //调用链服务地址
 	pprofListenAddr       string                           //pprof监听地址
 	enablePProf           bool                             //使能pprof
+	pprofToken            string                           //pprof接口的访问令牌
+	profileCapture        *pprof.CaptureConfig             //自动采集profile
 	timeout               time.Duration                    //超时退出机制
 	maxRecvMsgSize        int                              //设置rpc所能接受的最大消息长度
 	maxSendMsgSize        int                              //设置rpc所能发送的最大消息长度
@@ -45,6 +58,16 @@ type Options struct {
 	preRunHooks           []func() error                   //服务启动前需要执行的操作
 	preShutdownHooks      []func() error                   //服务停止时需要执行的操作
 	creds                 credentials.TransportCredentials //安全证书
+	tlsConfig             *tlsx.Config                     //自动重新加载的证书，优先于creds
+	crashReporter         recovery.Reporter                //panic上报
+	traceConfig           *trace.Config                    //调用链配置，优先于openTraceAddress
+	logConfig             log.Config                       //日志格式和输出配置
+	authenticator         auth.Authenticator               //请求认证
+	authSkipPrefixes      []string                         //不需要认证的方法前缀
+	policy                *auth.Policy                     //方法级授权策略
+	enableValidation      bool                             //使能请求参数校验
+	idempotency           *idempotency.Config              //幂等键
+	responseCache         *respcache.Config                //只读方法的响应缓存
 }
 
 type Option func(*Options)
@@ -62,6 +85,7 @@ func defaultOption() Options {
 		enableKeepAlivePolicy: false,
 		kaep:                  defaultKaep,
 		kasp:                  deafultKasp,
+		logConfig:             log.DefaultConfig(),
 	}
 }
 
@@ -98,6 +122,21 @@ func EnableMetric(metricName string) Option {
 	}
 }
 
+// MetricConfig 使能统计并设置常量标签、go和进程指标开关以及推送模式，ServiceName为空时使用服务名
+func MetricConfig(cfg metric.Config) Option {
+	return func(o *Options) {
+		o.enableMetric = true
+		o.metricConfig = &cfg
+	}
+}
+
+// Watchdog goroutine数或堆内存超过阈值时打印goroutine dump
+func Watchdog(cfg metric.WatchdogConfig) Option {
+	return func(o *Options) {
+		o.watchdog = &cfg
+	}
+}
+
 func EnablePProf(addr string) Option {
 	return func(o *Options) {
 		o.enablePProf = true
@@ -105,6 +144,20 @@ func EnablePProf(addr string) Option {
 	}
 }
 
+// PProfToken 访问pprof接口需要携带 Authorization: Bearer token
+func PProfToken(token string) Option {
+	return func(o *Options) {
+		o.pprofToken = token
+	}
+}
+
+// ProfileCapture 在CPU、内存或慢请求数超过阈值时自动采集profile，并可以通过pprof端口查看和下载，需要同时EnablePProf
+func ProfileCapture(cfg pprof.CaptureConfig) Option {
+	return func(o *Options) {
+		o.profileCapture = &cfg
+	}
+}
+
 func SetMaxRecvMsgSize(size int) Option {
 	return func(o *Options) {
 		o.maxRecvMsgSize = size
@@ -159,3 +212,73 @@ func PreShutdownHooks(hooks ...func() error) Option {
 		o.preShutdownHooks = hooks
 	}
 }
+
+// LogConfig 设置日志格式、文件切割和异步写入，默认为带颜色的文本输出到标准输出
+func LogConfig(cfg log.Config) Option {
+	return func(o *Options) {
+		o.logConfig = cfg
+	}
+}
+
+// CrashReporter 设置panic上报，通过recovery.NewDefaultReporter包装：相同的panic每分钟只上报一次并限流，
+// 上报在后台队列中异步发送，队列满时丢弃，不会阻塞发生panic的请求
+func CrashReporter(r recovery.Reporter) Option {
+	return func(o *Options) {
+		o.crashReporter = recovery.NewDefaultReporter(r)
+	}
+}
+
+// Tracing 选择调用链后端(JAEGER/OTEL)，ServiceName为空时使用服务名
+func Tracing(cfg trace.Config) Option {
+	return func(o *Options) {
+		o.traceConfig = &cfg
+	}
+}
+
+// Authenticate 认证所有请求，方法名以skipPrefixes中任一前缀开头的请求和健康检查不需要认证，
+// 多种认证方式可以使用auth.Chain组合。同时使用Authorize时忽略skipPrefixes，授权策略中Public的方法不需要认证
+func Authenticate(a auth.Authenticator, skipPrefixes ...string) Option {
+	return func(o *Options) {
+		o.authenticator = a
+		o.authSkipPrefixes = skipPrefixes
+	}
+}
+
+// Authorize 按授权策略检查调用方的角色和scope，需要同时使用Authenticate，Public规则的方法不需要认证，
+// 策略可以通过auth.LoadPolicy从配置文件加载并自动重新加载
+func Authorize(policy *auth.Policy) Option {
+	return func(o *Options) {
+		o.policy = policy
+	}
+}
+
+// WithTLS 使用证书文件启用TLS，caFile不为空时要求并校验客户端证书(mTLS)，
+// 证书文件修改后自动重新加载，不需要重启服务
+func WithTLS(certFile, keyFile, caFile string) Option {
+	return func(o *Options) {
+		o.tlsConfig = &tlsx.Config{CertFile: certFile, KeyFile: keyFile, CAFile: caFile}
+	}
+}
+
+// EnableValidation 请求实现了protoc-gen-validate生成的ValidateAll或Validate方法时自动校验，
+// 校验失败返回REUQEST_PARAM_ERROR及BadRequest字段错误详情
+func EnableValidation() Option {
+	return func(o *Options) {
+		o.enableValidation = true
+	}
+}
+
+// Idempotency 对携带幂等键(x-idempotency-key)的unary请求保存第一次处理的结果，重复的请求直接返回保存的结果，
+// 多实例部署时使用idempotency.NewRedisStore
+func Idempotency(cfg idempotency.Config) Option {
+	return func(o *Options) {
+		o.idempotency = &cfg
+	}
+}
+
+// ResponseCache 缓存配置的只读方法的响应，多实例部署时使用respcache.NewRedisStore共享缓存
+func ResponseCache(cfg respcache.Config) Option {
+	return func(o *Options) {
+		o.responseCache = &cfg
+	}
+}
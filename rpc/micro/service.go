@@ -1,27 +1,37 @@
 package micro
 
 import (
+	"context"
 	"fmt"
 	"net"
+	"os"
+	"time"
 
 	"github.com/sirupsen/logrus"
 	"google.golang.org/grpc"
 	"google.golang.org/grpc/health"
 	"google.golang.org/grpc/health/grpc_health_v1"
+	"singer.com/basic/errorx"
 	"singer.com/basic/log"
 	"singer.com/basic/metric"
 	"singer.com/basic/pprof"
+	"singer.com/basic/tlsx"
 	"singer.com/basic/trace"
 	"singer.com/rpc/serverinterceptor"
+	"singer.com/util/recovery"
 	signalutil "singer.com/util/signal"
 )
 
 type service struct {
-	app        Application
-	opts       Options
-	grpcServer *grpc.Server
-	health     *health.Server
-	stopCh     <-chan struct{}
+	app            Application
+	opts           Options
+	grpcServer     *grpc.Server
+	health         *health.Server
+	stopCh         <-chan struct{}
+	traceShutdown  trace.ShutdownFunc
+	metricShutdown metric.ShutdownFunc
+	watchdog       *metric.Watchdog
+	capturer       *pprof.Capturer
 }
 
 func newService(app Application, opts ...Option) Service {
@@ -30,6 +40,18 @@ func newService(app Application, opts ...Option) Service {
 	health := health.NewServer()
 	health.SetServingStatus(options.serverName, grpc_health_v1.HealthCheckResponse_NOT_SERVING)
 
+	if len(options.serverName) > 0 {
+		errorx.SetDomain(options.serverName)
+	}
+
+	if err := log.Init(options.logConfig); err != nil {
+		panic(fmt.Sprintf("init log failed, err: %v", err))
+	}
+
+	if options.crashReporter != nil {
+		recovery.SetReporter(options.crashReporter)
+	}
+
 	if options.enableLogServer {
 		log.InitLogServer(options.logListenAddr)
 	}
@@ -39,32 +61,112 @@ func newService(app Application, opts ...Option) Service {
 	}
 
 	if options.enableMetric {
-		metric.InitPrometheusMetrics(options.metricName, options.metricListenAddr)
+		cfg := metric.Config{
+			ServiceName:      options.metricName,
+			Addr:             options.metricListenAddr,
+			GoCollector:      true,
+			ProcessCollector: true,
+			RuntimeCollector: true,
+		}
+		if options.metricConfig != nil {
+			cfg = *options.metricConfig
+			if len(cfg.ServiceName) == 0 {
+				cfg.ServiceName = options.serverName
+			}
+		}
+		shutdown, err := metric.Init(cfg)
+		if err != nil {
+			panic(fmt.Sprintf("init metrics failed, err: %v", err))
+		}
+		service.metricShutdown = shutdown
+	}
+
+	if options.watchdog != nil {
+		service.watchdog = metric.NewWatchdog(*options.watchdog)
+		service.watchdog.Start()
 	}
 
 	if options.enablePProf {
-		pprof.Serve(options.pprofListenAddr)
+		pprofOpts := []pprof.Option{pprof.WithToken(options.pprofToken)}
+		if options.profileCapture != nil {
+			capturer, err := pprof.NewCapturer(*options.profileCapture)
+			if err != nil {
+				panic(fmt.Sprintf("init profile capturer failed, err: %v", err))
+			}
+			capturer.Start()
+			service.capturer = capturer
+			pprofOpts = append(pprofOpts, pprof.WithCapturer(capturer))
+		}
+		pprof.Serve(options.pprofListenAddr, pprofOpts...)
 	}
 
 	unaryInterceptors := []grpc.UnaryServerInterceptor{}
 	streamInterceptors := []grpc.StreamServerInterceptor{}
 
-	if len(options.openTraceAddress) > 0 {
+	if options.traceConfig != nil {
+		cfg := *options.traceConfig
+		if len(cfg.ServiceName) == 0 {
+			cfg.ServiceName = options.serverName
+		}
+		shutdown, err := trace.Init(cfg)
+		if err != nil {
+			panic(fmt.Sprintf("init %v tracer failed, err: %v", cfg.Service, err))
+		}
+		service.traceShutdown = shutdown
+		if cfg.Service != trace.NONE {
+			unaryInterceptors = append(unaryInterceptors,
+				serverinterceptor.UnaryDebugSamplingInterceptor,
+				serverinterceptor.UnaryOpentracingInterceptor())
+			streamInterceptors = append(streamInterceptors,
+				serverinterceptor.StreamDebugSamplingInterceptor,
+				serverinterceptor.StreamOpentracingInterceptor())
+		}
+	} else if len(options.openTraceAddress) > 0 {
 		trace.InitOpentracing(options.serverName, options.openTraceAddress)
 		unaryInterceptors = append(unaryInterceptors, serverinterceptor.UnaryOpentracingInterceptor())
 		streamInterceptors = append(streamInterceptors, serverinterceptor.StreamOpentracingInterceptor())
 	}
+	unaryInterceptors = append(unaryInterceptors, serverinterceptor.UnaryGenerateMetadataInterceptor)
+	streamInterceptors = append(streamInterceptors, serverinterceptor.StreamGenerateMetadataInterceptor)
+	if options.enableMetric {
+		unaryInterceptors = append(unaryInterceptors, serverinterceptor.UnaryMetricsInterceptor)
+		streamInterceptors = append(streamInterceptors, serverinterceptor.StreamMetricsInterceptor)
+	}
 	unaryInterceptors = append(unaryInterceptors,
-		serverinterceptor.UnaryGenerateMetadataInterceptor,
 		serverinterceptor.UnaryCrashInterceptor,
 		serverinterceptor.UnaryErrorInterceptor,
 		serverinterceptor.UnarySlowlogInterceptor())
 
 	streamInterceptors = append(streamInterceptors,
-		serverinterceptor.StreamGenerateMetadataInterceptor,
 		serverinterceptor.StreamCrashInterceptor,
 		serverinterceptor.StreamErrorInterceptor)
 
+	if options.authenticator != nil && options.policy != nil {
+		// 跳过认证的方法由授权策略的Public规则决定，避免两处配置不一致
+		if len(options.authSkipPrefixes) > 0 {
+			logrus.Warnf("skip prefixes %v of Authenticate are ignored when Authorize is used, mark them public in the policy instead", options.authSkipPrefixes)
+		}
+		unaryInterceptors = append(unaryInterceptors, serverinterceptor.UnaryPolicyAuthInterceptor(options.authenticator, options.policy))
+		streamInterceptors = append(streamInterceptors, serverinterceptor.StreamPolicyAuthInterceptor(options.authenticator, options.policy))
+	} else if options.authenticator != nil {
+		unaryInterceptors = append(unaryInterceptors, serverinterceptor.UnaryAuthInterceptor(options.authenticator, options.authSkipPrefixes...))
+		streamInterceptors = append(streamInterceptors, serverinterceptor.StreamAuthInterceptor(options.authenticator, options.authSkipPrefixes...))
+	}
+	if options.policy != nil {
+		unaryInterceptors = append(unaryInterceptors, serverinterceptor.UnaryAuthorizeInterceptor(options.policy))
+		streamInterceptors = append(streamInterceptors, serverinterceptor.StreamAuthorizeInterceptor(options.policy))
+	}
+	if options.enableValidation {
+		unaryInterceptors = append(unaryInterceptors, serverinterceptor.UnaryValidateInterceptor)
+		streamInterceptors = append(streamInterceptors, serverinterceptor.StreamValidateInterceptor)
+	}
+	if options.responseCache != nil {
+		unaryInterceptors = append(unaryInterceptors, serverinterceptor.UnaryCacheInterceptor(*options.responseCache))
+	}
+	if options.idempotency != nil {
+		unaryInterceptors = append(unaryInterceptors, serverinterceptor.UnaryIdempotencyInterceptor(*options.idempotency))
+	}
+
 	if options.timeout > 0 {
 		unaryInterceptors = append(unaryInterceptors, serverinterceptor.UnaryTimeoutInterceptor(options.timeout))
 	}
@@ -89,6 +191,13 @@ func newService(app Application, opts ...Option) Service {
 	if options.enableKeepAlivePolicy {
 		grpcOptions = append(grpcOptions, grpc.KeepaliveEnforcementPolicy(options.kaep))
 	}
+	if options.tlsConfig != nil {
+		creds, err := tlsx.NewServerCredentials(*options.tlsConfig)
+		if err != nil {
+			panic(fmt.Sprintf("init tls failed, err: %v", err))
+		}
+		options.creds = creds
+	}
 	if options.creds != nil {
 		grpcOptions = append(grpcOptions, grpc.Creds(options.creds))
 	}
@@ -180,4 +289,34 @@ func (s *service) PreShutdown() error {
 
 func (s *service) Shutdown() {
 	s.grpcServer.Stop() //GracefulStop will not close imediately with stream conn
+	if s.traceShutdown != nil {
+		ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+		defer cancel()
+		if err := s.traceShutdown(ctx); err != nil {
+			logrus.Errorf("flush traces failed, err: %v", err)
+		}
+	}
+	if s.watchdog != nil {
+		s.watchdog.Stop()
+	}
+	if s.capturer != nil {
+		s.capturer.Stop()
+	}
+	if s.opts.crashReporter != nil {
+		ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+		defer cancel()
+		if err := recovery.Flush(ctx); err != nil {
+			logrus.Errorf("flush crash reports failed, err: %v", err)
+		}
+	}
+	if s.metricShutdown != nil {
+		ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+		defer cancel()
+		if err := s.metricShutdown(ctx); err != nil {
+			logrus.Errorf("shutdown metrics failed, err: %v", err)
+		}
+	}
+	if err := log.Close(); err != nil {
+		fmt.Fprintf(os.Stderr, "close log failed, err: %v\n", err)
+	}
 }
@@ -0,0 +1,92 @@
+package serverinterceptor
+
+import (
+	"context"
+	"strings"
+
+	"github.com/sirupsen/logrus"
+	"google.golang.org/grpc"
+	"singer.com/basic/auth"
+	"singer.com/basic/errorx"
+	"singer.com/basic/log"
+	"singer.com/basic/meta"
+)
+
+// 健康检查总是不需要认证
+const healthCheckPrefix = "/grpc.health.v1.Health/"
+
+// UnaryAuthInterceptor 认证请求，并通过auth.FromContext(ctx)获取调用方身份，
+// 方法名以skipPrefixes中任一前缀开头的请求不需要认证
+func UnaryAuthInterceptor(a auth.Authenticator, skipPrefixes ...string) grpc.UnaryServerInterceptor {
+	return unaryAuthInterceptor(a, prefixSkipper(skipPrefixes))
+}
+
+// StreamAuthInterceptor 认证流式请求
+func StreamAuthInterceptor(a auth.Authenticator, skipPrefixes ...string) grpc.StreamServerInterceptor {
+	return streamAuthInterceptor(a, prefixSkipper(skipPrefixes))
+}
+
+// UnaryPolicyAuthInterceptor 同UnaryAuthInterceptor，但跳过授权策略中Public的方法，
+// 与UnaryAuthorizeInterceptor使用同一个策略时不需要另外维护跳过认证的方法
+func UnaryPolicyAuthInterceptor(a auth.Authenticator, policy *auth.Policy) grpc.UnaryServerInterceptor {
+	return unaryAuthInterceptor(a, policy.IsPublic)
+}
+
+// StreamPolicyAuthInterceptor 认证流式请求，跳过授权策略中Public的方法
+func StreamPolicyAuthInterceptor(a auth.Authenticator, policy *auth.Policy) grpc.StreamServerInterceptor {
+	return streamAuthInterceptor(a, policy.IsPublic)
+}
+
+func unaryAuthInterceptor(a auth.Authenticator, skip func(fullMethod string) bool) grpc.UnaryServerInterceptor {
+	return func(ctx context.Context, req interface{}, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (interface{}, error) {
+		if skipAuth(info.FullMethod, skip) {
+			return handler(ctx, req)
+		}
+		ctx, err := authenticate(ctx, a)
+		if err != nil {
+			return nil, err
+		}
+		return handler(ctx, req)
+	}
+}
+
+func streamAuthInterceptor(a auth.Authenticator, skip func(fullMethod string) bool) grpc.StreamServerInterceptor {
+	return func(srv interface{}, ss grpc.ServerStream, info *grpc.StreamServerInfo, handler grpc.StreamHandler) error {
+		if skipAuth(info.FullMethod, skip) {
+			return handler(srv, ss)
+		}
+		ctx, err := authenticate(ss.Context(), a)
+		if err != nil {
+			return err
+		}
+		return handler(srv, meta.NewServerStream(ctx, ss))
+	}
+}
+
+func prefixSkipper(skipPrefixes []string) func(string) bool {
+	return func(fullMethod string) bool {
+		for _, prefix := range skipPrefixes {
+			if strings.HasPrefix(fullMethod, prefix) {
+				return true
+			}
+		}
+		return false
+	}
+}
+
+func skipAuth(fullMethod string, skip func(string) bool) bool {
+	return strings.HasPrefix(fullMethod, healthCheckPrefix) || skip(fullMethod)
+}
+
+func authenticate(ctx context.Context, a auth.Authenticator) (context.Context, error) {
+	p, err := a.Authenticate(ctx)
+	if err == auth.ErrNoCredentials {
+		err = errorx.Wrap(errorx.UNAUTHENTICATED_ERROR, "missing credentials")
+	}
+	if err != nil {
+		log.FromContext(ctx).Warnf("authenticate failed, err: %v", err)
+		return ctx, err
+	}
+	ctx = auth.NewContext(ctx, p)
+	return log.WithFields(ctx, logrus.Fields{"Principal": p.Type + ":" + p.Subject}), nil
+}
@@ -0,0 +1,89 @@
+package serverinterceptor
+
+import (
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/metadata"
+	"singer.com/basic/auth"
+	"singer.com/basic/errorx"
+	"singer.com/basic/log"
+)
+
+func TestUnaryAuthInterceptor(t *testing.T) {
+	a := auth.NewAPIKeyAuthenticator(map[string]auth.Principal{"key1": {Subject: "job"}})
+	interceptor := UnaryAuthInterceptor(a, "/api.Public/")
+
+	var principal *auth.Principal
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		principal, _ = auth.FromContext(ctx)
+		return nil, nil
+	}
+
+	ctx := metadata.NewIncomingContext(context.Background(), metadata.Pairs("x-api-key", "key1"))
+	_, err := interceptor(ctx, nil, &grpc.UnaryServerInfo{FullMethod: "/api.Order/Get"}, handler)
+	assert.Nil(t, err)
+	assert.Equal(t, "job", principal.Subject)
+
+	_, err = interceptor(context.Background(), nil, &grpc.UnaryServerInfo{FullMethod: "/api.Order/Get"}, handler)
+	assert.True(t, errorx.IsCode(err, errorx.UNAUTHENTICATED_ERROR))
+
+	ctx = metadata.NewIncomingContext(context.Background(), metadata.Pairs("x-api-key", "key2"))
+	_, err = interceptor(ctx, nil, &grpc.UnaryServerInfo{FullMethod: "/api.Order/Get"}, handler)
+	assert.True(t, errorx.IsCode(err, errorx.UNAUTHENTICATED_ERROR))
+
+	// 跳过认证
+	principal = nil
+	_, err = interceptor(context.Background(), nil, &grpc.UnaryServerInfo{FullMethod: "/api.Public/Get"}, handler)
+	assert.Nil(t, err)
+	assert.Nil(t, principal)
+	_, err = interceptor(context.Background(), nil, &grpc.UnaryServerInfo{FullMethod: "/grpc.health.v1.Health/Check"}, handler)
+	assert.Nil(t, err)
+}
+
+func TestUnaryPolicyAuthInterceptor(t *testing.T) {
+	a := auth.NewAPIKeyAuthenticator(map[string]auth.Principal{"key1": {Subject: "job"}})
+	policy := auth.NewPolicy(auth.PolicyConfig{Rules: []auth.Rule{
+		{Method: "/api.Order/", Roles: []string{auth.AnyRole}},
+		{Method: "/api.Public/", Public: true},
+	}})
+	interceptor := UnaryPolicyAuthInterceptor(a, policy)
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) { return nil, nil }
+	public := &grpc.UnaryServerInfo{FullMethod: "/api.Public/Get"}
+
+	_, err := interceptor(context.Background(), nil, public, handler)
+	assert.Nil(t, err)
+	_, err = interceptor(context.Background(), nil, &grpc.UnaryServerInfo{FullMethod: "/api.Order/Get"}, handler)
+	assert.True(t, errorx.IsCode(err, errorx.UNAUTHENTICATED_ERROR))
+
+	// 修改策略后立即生效
+	policy.Update(auth.PolicyConfig{Rules: []auth.Rule{{Method: "/api.Order/Get", Public: true}}})
+	_, err = interceptor(context.Background(), nil, &grpc.UnaryServerInfo{FullMethod: "/api.Order/Get"}, handler)
+	assert.Nil(t, err)
+	_, err = interceptor(context.Background(), nil, public, handler)
+	assert.True(t, errorx.IsCode(err, errorx.UNAUTHENTICATED_ERROR))
+}
+
+func TestStreamAuthInterceptor(t *testing.T) {
+	a := auth.NewAPIKeyAuthenticator(map[string]auth.Principal{"key1": {Subject: "job"}})
+	interceptor := StreamAuthInterceptor(a)
+
+	ctx := metadata.NewIncomingContext(context.Background(), metadata.Pairs("x-api-key", "key1"))
+	err := interceptor(nil, mockedStream{ctx: ctx}, &grpc.StreamServerInfo{FullMethod: "/api.Order/Watch"},
+		func(srv interface{}, stream grpc.ServerStream) error {
+			p, ok := auth.FromContext(stream.Context())
+			assert.True(t, ok)
+			assert.Equal(t, "job", p.Subject)
+			assert.Equal(t, "apikey:job", log.FromContext(stream.Context()).Data["Principal"])
+			return nil
+		})
+	assert.Nil(t, err)
+
+	err = interceptor(nil, mockedStream{ctx: context.Background()}, &grpc.StreamServerInfo{FullMethod: "/api.Order/Watch"},
+		func(srv interface{}, stream grpc.ServerStream) error {
+			return nil
+		})
+	assert.True(t, errorx.IsCode(err, errorx.UNAUTHENTICATED_ERROR))
+}
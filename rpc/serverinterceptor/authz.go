@@ -0,0 +1,64 @@
+package serverinterceptor
+
+import (
+	"context"
+	"strings"
+
+	"github.com/sirupsen/logrus"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/peer"
+	"singer.com/basic/auth"
+	"singer.com/basic/log"
+)
+
+// UnaryAuthorizeInterceptor 按授权策略检查调用方是否可以访问方法，需要放在认证拦截器之后，
+// 拒绝时返回PERMISSION_DENIED_ERROR并打印审计日志
+func UnaryAuthorizeInterceptor(policy *auth.Policy) grpc.UnaryServerInterceptor {
+	return func(ctx context.Context, req interface{}, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (interface{}, error) {
+		if err := authorize(ctx, policy, info.FullMethod); err != nil {
+			return nil, err
+		}
+		return handler(ctx, req)
+	}
+}
+
+// StreamAuthorizeInterceptor 按授权策略检查流式请求
+func StreamAuthorizeInterceptor(policy *auth.Policy) grpc.StreamServerInterceptor {
+	return func(srv interface{}, ss grpc.ServerStream, info *grpc.StreamServerInfo, handler grpc.StreamHandler) error {
+		if err := authorize(ss.Context(), policy, info.FullMethod); err != nil {
+			return err
+		}
+		return handler(srv, ss)
+	}
+}
+
+func authorize(ctx context.Context, policy *auth.Policy, fullMethod string) error {
+	if strings.HasPrefix(fullMethod, healthCheckPrefix) {
+		return nil
+	}
+	p, _ := auth.FromContext(ctx)
+	err := policy.Authorize(p, fullMethod)
+	if err != nil {
+		auditDenied(ctx, p, fullMethod, err)
+	}
+	return err
+}
+
+// auditDenied 打印拒绝访问的审计日志，可以通过Logger=audit过滤
+func auditDenied(ctx context.Context, p *auth.Principal, fullMethod string, err error) {
+	fields := logrus.Fields{
+		"Logger": "audit",
+		"Event":  "permission_denied",
+		"Method": fullMethod,
+	}
+	if p != nil {
+		fields["Subject"] = p.Subject
+		fields["AuthType"] = p.Type
+		fields["Roles"] = p.Roles
+		fields["Scopes"] = p.Scopes
+	}
+	if pr, ok := peer.FromContext(ctx); ok && pr.Addr != nil {
+		fields["Peer"] = pr.Addr.String()
+	}
+	log.FromContext(ctx).WithFields(fields).Warnf("permission denied: %v", err)
+}
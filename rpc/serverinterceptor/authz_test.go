@@ -0,0 +1,50 @@
+package serverinterceptor
+
+import (
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"google.golang.org/grpc"
+	"singer.com/basic/auth"
+	"singer.com/basic/errorx"
+)
+
+func TestUnaryAuthorizeInterceptor(t *testing.T) {
+	policy := auth.NewPolicy(auth.PolicyConfig{Rules: []auth.Rule{
+		{Method: "/api.Order/", Roles: []string{"admin"}},
+	}})
+	interceptor := UnaryAuthorizeInterceptor(policy)
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return "ok", nil
+	}
+
+	ctx := auth.NewContext(context.Background(), &auth.Principal{Subject: "u1", Roles: []string{"admin"}})
+	resp, err := interceptor(ctx, nil, &grpc.UnaryServerInfo{FullMethod: "/api.Order/Get"}, handler)
+	assert.Nil(t, err)
+	assert.Equal(t, "ok", resp)
+
+	ctx = auth.NewContext(context.Background(), &auth.Principal{Subject: "u2", Roles: []string{"viewer"}})
+	_, err = interceptor(ctx, nil, &grpc.UnaryServerInfo{FullMethod: "/api.Order/Get"}, handler)
+	assert.True(t, errorx.IsCode(err, errorx.PERMISSION_DENIED_ERROR))
+
+	_, err = interceptor(context.Background(), nil, &grpc.UnaryServerInfo{FullMethod: "/grpc.health.v1.Health/Check"}, handler)
+	assert.Nil(t, err)
+}
+
+func TestStreamAuthorizeInterceptor(t *testing.T) {
+	policy := auth.NewPolicy(auth.PolicyConfig{Rules: []auth.Rule{
+		{Method: "/api.Order/Watch", Scopes: []string{"order:read"}},
+	}})
+	interceptor := StreamAuthorizeInterceptor(policy)
+	handler := func(srv interface{}, stream grpc.ServerStream) error {
+		return nil
+	}
+
+	ctx := auth.NewContext(context.Background(), &auth.Principal{Subject: "u1", Scopes: []string{"order:read"}})
+	err := interceptor(nil, mockedStream{ctx: ctx}, &grpc.StreamServerInfo{FullMethod: "/api.Order/Watch"}, handler)
+	assert.Nil(t, err)
+
+	err = interceptor(nil, mockedStream{ctx: context.Background()}, &grpc.StreamServerInfo{FullMethod: "/api.Order/Watch"}, handler)
+	assert.True(t, errorx.IsCode(err, errorx.PERMISSION_DENIED_ERROR))
+}
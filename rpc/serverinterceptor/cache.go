@@ -0,0 +1,146 @@
+package serverinterceptor
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/proto"
+	"singer.com/basic/auth"
+	"singer.com/basic/log"
+	"singer.com/basic/metric"
+	"singer.com/basic/respcache"
+	"singer.com/basic/single"
+)
+
+const (
+	cacheHit   = "hit"
+	cacheMiss  = "miss"
+	cacheStale = "stale"
+)
+
+var cacheRequests = metric.NewCounterVec(prometheus.CounterOpts{
+	Subsystem: "rpc_server",
+	Name:      "cache_requests_total",
+	Help:      "Total number of requests to cached RPC methods by result (hit, miss or stale).",
+}, "grpc_service", "grpc_method", "result")
+
+// cacheNow 测试中替换
+var cacheNow = time.Now
+
+// UnaryCacheInterceptor 缓存配置的只读方法的响应，缓存key由调用方身份、请求的确定性序列化结果和指定的metadata计算。
+// 并发的未命中请求只调用一次handler，响应过期但在StaleTTL内时返回旧响应并在后台刷新。只缓存成功的响应
+func UnaryCacheInterceptor(cfg respcache.Config) grpc.UnaryServerInterceptor {
+	cfg = cfg.WithDefaults()
+	group := single.New()
+	var refreshing sync.Map
+	return func(ctx context.Context, req interface{}, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (interface{}, error) {
+		mc, ok := cfg.Methods[info.FullMethod]
+		if !ok || mc.TTL <= 0 {
+			return handler(ctx, req)
+		}
+		msg, ok := req.(proto.Message)
+		if !ok {
+			return handler(ctx, req)
+		}
+		var principal string
+		if p, ok := auth.FromContext(ctx); ok && !mc.Shared {
+			principal = p.Type + ":" + p.Subject
+		}
+		md, _ := metadata.FromIncomingContext(ctx)
+		key, err := respcache.Key(info.FullMethod, principal, msg, md, mc.MetadataKeys)
+		if err != nil {
+			return handler(ctx, req)
+		}
+		service, method := metric.SplitMethodName(info.FullMethod)
+
+		// 合并的加载和后台刷新在独立的ctx中执行，不受发起请求的调用方取消或超时的影响
+		load := func(loadCtx context.Context) func() (interface{}, error) {
+			return func() (interface{}, error) {
+				ctx, cancel := context.WithTimeout(loadCtx, cfg.RefreshTimeout)
+				defer cancel()
+				resp, err := handler(ctx, req)
+				if err != nil {
+					return nil, err
+				}
+				if m, ok := resp.(proto.Message); ok {
+					storeResponse(ctx, cfg.Store, key, m, mc)
+				}
+				return resp, nil
+			}
+		}
+
+		entry := loadEntry(ctx, cfg.Store, key)
+		switch {
+		case entry != nil && entry.Fresh(cacheNow()):
+			cacheRequests.WithLabelValues(service, method, cacheHit).Inc()
+			return entry.Response, nil
+		case entry != nil:
+			cacheRequests.WithLabelValues(service, method, cacheStale).Inc()
+			// 同一个key同时只有一个后台刷新
+			if _, loaded := refreshing.LoadOrStore(key, struct{}{}); !loaded {
+				loadCtx := loadContext(ctx)
+				go func() {
+					defer refreshing.Delete(key)
+					if _, err, _ := group.Do(key, load(loadCtx)); err != nil {
+						log.FromContext(loadCtx).Warnf("cache: refresh %s failed, err: %v", info.FullMethod, err)
+					}
+				}()
+			}
+			return entry.Response, nil
+		}
+		cacheRequests.WithLabelValues(service, method, cacheMiss).Inc()
+		// 每个调用方只等待自己的ctx
+		select {
+		case r := <-group.DoChan(key, load(loadContext(ctx))):
+			return r.Val, r.Err
+		case <-ctx.Done():
+			return nil, status.FromContextError(ctx.Err()).Err()
+		}
+	}
+}
+
+func loadEntry(ctx context.Context, store respcache.Store, key string) *respcache.Entry {
+	data, err := store.Get(ctx, key)
+	if err != nil {
+		log.FromContext(ctx).Warnf("cache: get %s failed, err: %v", key, err)
+		return nil
+	}
+	if data == nil {
+		return nil
+	}
+	entry, err := respcache.UnmarshalEntry(data)
+	if err != nil {
+		log.FromContext(ctx).Warnf("cache: unmarshal %s failed, err: %v", key, err)
+		return nil
+	}
+	return entry
+}
+
+func storeResponse(ctx context.Context, store respcache.Store, key string, resp proto.Message, mc respcache.MethodConfig) {
+	entry := &respcache.Entry{Response: resp, FreshUntil: cacheNow().Add(mc.TTL)}
+	data, err := entry.Marshal()
+	if err == nil {
+		err = store.Set(ctx, key, data, mc.TTL+mc.StaleTTL)
+	}
+	if err != nil {
+		log.FromContext(ctx).Warnf("cache: set %s failed, err: %v", key, err)
+	}
+}
+
+// loadContext 返回不随请求结束而取消的新ctx，只携带metadata、logger和调用方身份，
+// 不会持有原请求的transport stream、peer等请求级的值
+func loadContext(ctx context.Context) context.Context {
+	fresh := context.Background()
+	if md, ok := metadata.FromIncomingContext(ctx); ok {
+		fresh = metadata.NewIncomingContext(fresh, md.Copy())
+	}
+	if p, ok := auth.FromContext(ctx); ok {
+		fresh = auth.NewContext(fresh, p)
+	}
+	return log.WithFields(fresh, log.FromContext(ctx).Data)
+}
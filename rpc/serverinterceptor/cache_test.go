@@ -0,0 +1,223 @@
+package serverinterceptor
+
+import (
+	"context"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"github.com/stretchr/testify/assert"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/types/known/wrapperspb"
+	"singer.com/basic/auth"
+	"singer.com/basic/respcache"
+)
+
+func TestUnaryCacheInterceptor(t *testing.T) {
+	interceptor := UnaryCacheInterceptor(respcache.Config{
+		Store: respcache.NewLRUStore(100),
+		Methods: map[string]respcache.MethodConfig{
+			"/pb.Cache/Get": {TTL: time.Minute, MetadataKeys: []string{"accept-language"}},
+		},
+	})
+	info := &grpc.UnaryServerInfo{FullMethod: "/pb.Cache/Get"}
+	var calls int32
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		atomic.AddInt32(&calls, 1)
+		md, _ := metadata.FromIncomingContext(ctx)
+		return wrapperspb.String(req.(*wrapperspb.StringValue).GetValue() + "-" + md.Get("accept-language")[0]), nil
+	}
+	hits := testutil.ToFloat64(cacheRequests.WithLabelValues("pb.Cache", "Get", cacheHit))
+	misses := testutil.ToFloat64(cacheRequests.WithLabelValues("pb.Cache", "Get", cacheMiss))
+
+	zh := metadata.NewIncomingContext(context.Background(), metadata.Pairs("accept-language", "zh-CN"))
+	en := metadata.NewIncomingContext(context.Background(), metadata.Pairs("accept-language", "en-US"))
+	for i := 0; i < 3; i++ {
+		resp, err := interceptor(zh, wrapperspb.String("a"), info, handler)
+		assert.Nil(t, err)
+		assert.True(t, proto.Equal(wrapperspb.String("a-zh-CN"), resp.(proto.Message)))
+	}
+	assert.Equal(t, int32(1), calls)
+
+	resp, _ := interceptor(en, wrapperspb.String("a"), info, handler)
+	assert.True(t, proto.Equal(wrapperspb.String("a-en-US"), resp.(proto.Message)))
+	interceptor(zh, wrapperspb.String("b"), info, handler)
+	assert.Equal(t, int32(3), calls)
+	assert.Equal(t, hits+2, testutil.ToFloat64(cacheRequests.WithLabelValues("pb.Cache", "Get", cacheHit)))
+	assert.Equal(t, misses+3, testutil.ToFloat64(cacheRequests.WithLabelValues("pb.Cache", "Get", cacheMiss)))
+
+	// 没有配置的方法
+	interceptor(zh, wrapperspb.String("a"), &grpc.UnaryServerInfo{FullMethod: "/pb.Cache/Update"}, handler)
+	interceptor(zh, wrapperspb.String("a"), &grpc.UnaryServerInfo{FullMethod: "/pb.Cache/Update"}, handler)
+	assert.Equal(t, int32(5), calls)
+}
+
+func TestUnaryCacheInterceptorCollapseMisses(t *testing.T) {
+	interceptor := UnaryCacheInterceptor(respcache.Config{
+		Store:   respcache.NewLRUStore(100),
+		Methods: map[string]respcache.MethodConfig{"/pb.Cache/Get": {TTL: time.Minute}},
+	})
+	info := &grpc.UnaryServerInfo{FullMethod: "/pb.Cache/Get"}
+	var calls int32
+	release := make(chan struct{})
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		atomic.AddInt32(&calls, 1)
+		<-release
+		return wrapperspb.String("v"), nil
+	}
+
+	var wg sync.WaitGroup
+	for i := 0; i < 10; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			resp, err := interceptor(context.Background(), wrapperspb.String("a"), info, handler)
+			assert.Nil(t, err)
+			assert.True(t, proto.Equal(wrapperspb.String("v"), resp.(proto.Message)))
+		}()
+	}
+	time.Sleep(50 * time.Millisecond)
+	close(release)
+	wg.Wait()
+	assert.Equal(t, int32(1), calls)
+}
+
+func TestUnaryCacheInterceptorStaleWhileRevalidate(t *testing.T) {
+	now := time.Now()
+	var mtx sync.Mutex
+	cacheNow = func() time.Time {
+		mtx.Lock()
+		defer mtx.Unlock()
+		return now
+	}
+	defer func() { cacheNow = time.Now }()
+
+	interceptor := UnaryCacheInterceptor(respcache.Config{
+		Store:   respcache.NewLRUStore(100),
+		Methods: map[string]respcache.MethodConfig{"/pb.Cache/Get": {TTL: time.Second, StaleTTL: time.Minute}},
+	})
+	info := &grpc.UnaryServerInfo{FullMethod: "/pb.Cache/Get"}
+	var version int32
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		v := atomic.AddInt32(&version, 1)
+		if v == 3 {
+			return nil, status.Error(codes.Unavailable, "unavailable")
+		}
+		return wrapperspb.Int32(v), nil
+	}
+
+	resp, _ := interceptor(context.Background(), wrapperspb.String("a"), info, handler)
+	assert.True(t, proto.Equal(wrapperspb.Int32(1), resp.(proto.Message)))
+
+	// 过期后返回旧响应，并在后台刷新
+	mtx.Lock()
+	now = now.Add(2 * time.Second)
+	mtx.Unlock()
+	resp, _ = interceptor(context.Background(), wrapperspb.String("a"), info, handler)
+	assert.True(t, proto.Equal(wrapperspb.Int32(1), resp.(proto.Message)))
+	assert.Eventually(t, func() bool {
+		resp, _ := interceptor(context.Background(), wrapperspb.String("a"), info, handler)
+		return proto.Equal(wrapperspb.Int32(2), resp.(proto.Message))
+	}, time.Second, 10*time.Millisecond)
+
+	// 刷新失败时继续返回旧响应
+	mtx.Lock()
+	now = now.Add(2 * time.Second)
+	mtx.Unlock()
+	resp, err := interceptor(context.Background(), wrapperspb.String("a"), info, handler)
+	assert.Nil(t, err)
+	assert.True(t, proto.Equal(wrapperspb.Int32(2), resp.(proto.Message)))
+	assert.Eventually(t, func() bool {
+		return atomic.LoadInt32(&version) == 3
+	}, time.Second, 10*time.Millisecond)
+}
+
+func TestUnaryCacheInterceptorPrincipal(t *testing.T) {
+	interceptor := UnaryCacheInterceptor(respcache.Config{
+		Store: respcache.NewLRUStore(100),
+		Methods: map[string]respcache.MethodConfig{
+			"/pb.Cache/Me":     {TTL: time.Minute},
+			"/pb.Cache/Public": {TTL: time.Minute, Shared: true},
+		},
+	})
+	var calls int32
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		atomic.AddInt32(&calls, 1)
+		p, _ := auth.FromContext(ctx)
+		return wrapperspb.String(p.Subject), nil
+	}
+	u1 := auth.NewContext(context.Background(), &auth.Principal{Type: auth.TypeJWT, Subject: "u1"})
+	u2 := auth.NewContext(context.Background(), &auth.Principal{Type: auth.TypeJWT, Subject: "u2"})
+
+	// 默认按调用方隔离
+	me := &grpc.UnaryServerInfo{FullMethod: "/pb.Cache/Me"}
+	resp, _ := interceptor(u1, wrapperspb.String("a"), me, handler)
+	assert.True(t, proto.Equal(wrapperspb.String("u1"), resp.(proto.Message)))
+	resp, _ = interceptor(u2, wrapperspb.String("a"), me, handler)
+	assert.True(t, proto.Equal(wrapperspb.String("u2"), resp.(proto.Message)))
+	interceptor(u1, wrapperspb.String("a"), me, handler)
+	assert.Equal(t, int32(2), calls)
+
+	// Shared的方法所有调用方共享
+	public := &grpc.UnaryServerInfo{FullMethod: "/pb.Cache/Public"}
+	interceptor(u1, wrapperspb.String("a"), public, handler)
+	resp, _ = interceptor(u2, wrapperspb.String("a"), public, handler)
+	assert.True(t, proto.Equal(wrapperspb.String("u1"), resp.(proto.Message)))
+	assert.Equal(t, int32(3), calls)
+}
+
+type cacheTestKey struct{}
+
+func TestUnaryCacheInterceptorDetachedLoad(t *testing.T) {
+	interceptor := UnaryCacheInterceptor(respcache.Config{
+		Store:   respcache.NewLRUStore(100),
+		Methods: map[string]respcache.MethodConfig{"/pb.Cache/Get": {TTL: time.Minute}},
+	})
+	info := &grpc.UnaryServerInfo{FullMethod: "/pb.Cache/Get"}
+	release := make(chan struct{})
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		<-release
+		if ctx.Err() != nil {
+			return nil, ctx.Err()
+		}
+		// 只携带metadata、logger和调用方身份
+		md, _ := metadata.FromIncomingContext(ctx)
+		p, _ := auth.FromContext(ctx)
+		assert.Equal(t, []string{"zh-CN"}, md.Get("accept-language"))
+		assert.Equal(t, "u1", p.Subject)
+		assert.Nil(t, ctx.Value(cacheTestKey{}))
+		return wrapperspb.String("v"), nil
+	}
+	base := metadata.NewIncomingContext(context.Background(), metadata.Pairs("accept-language", "zh-CN"))
+	base = auth.NewContext(context.WithValue(base, cacheTestKey{}, "request"), &auth.Principal{Type: auth.TypeJWT, Subject: "u1"})
+
+	// 发起加载的调用方取消后，其他等待的调用方仍然得到结果
+	first, cancel := context.WithCancel(base)
+	firstDone := make(chan error, 1)
+	go func() {
+		_, err := interceptor(first, wrapperspb.String("a"), info, handler)
+		firstDone <- err
+	}()
+	time.Sleep(20 * time.Millisecond)
+	var wg sync.WaitGroup
+	for i := 0; i < 5; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			resp, err := interceptor(base, wrapperspb.String("a"), info, handler)
+			assert.Nil(t, err)
+			assert.True(t, proto.Equal(wrapperspb.String("v"), resp.(proto.Message)))
+		}()
+	}
+	time.Sleep(20 * time.Millisecond)
+	cancel()
+	assert.Equal(t, codes.Canceled, status.Code(<-firstDone))
+	close(release)
+	wg.Wait()
+}
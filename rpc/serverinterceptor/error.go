@@ -11,17 +11,22 @@ import (
 	"google.golang.org/grpc/status"
 )
 
-//error拦截器可以自动打印错误日志，并且将自定义类型错误对应的脱敏信息返回给调用者 (自定义错误类型为errorx)
+//error拦截器可以自动打印错误日志，并且将自定义类型错误对应的脱敏信息及错误详情返回给调用者 (自定义错误类型为errorx)
+//脱敏信息的语言由请求metadata中的accept-language决定
 func UnaryErrorInterceptor(ctx context.Context, req interface{}, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (resp interface{}, err error) {
 	resp, err = handler(ctx, req)
 	if err != nil {
-		log.RpcErrorf(ctx, "%+v", err)
+		grpcErr := err
 		causeErr := errorx.Cause(err)                  // err类型
 		if e, ok := causeErr.(*errorx.CodeError); ok { //自定义错误类型
 			//转成grpc err
-			err = status.Error(codes.Code(e.GetErrCode()), e.GetUsrMsg())
+			grpcErr = e.GRPCStatusWithLang(errorx.LangFromContext(ctx)).Err()
 			e.SetTrailer(ctx)
 		}
+		if !isAuthRejection(grpcErr) {
+			log.RpcErrorf(ctx, "%+v", err)
+		}
+		err = grpcErr
 	}
 	return resp, err
 }
@@ -29,13 +34,24 @@ func UnaryErrorInterceptor(ctx context.Context, req interface{}, info *grpc.Unar
 func StreamErrorInterceptor(srv interface{}, ss grpc.ServerStream, info *grpc.StreamServerInfo, handler grpc.StreamHandler) error {
 	err := handler(srv, ss)
 	if err != nil {
-		log.RpcErrorf(ss.Context(), "%+v", err)
+		grpcErr := err
 		causeErr := errorx.Cause(err)                  // err类型
 		if e, ok := causeErr.(*errorx.CodeError); ok { //自定义错误类型
 			//转成grpc err
-			err = status.Error(codes.Code(e.GetErrCode()), e.GetUsrMsg())
+			grpcErr = e.GRPCStatusWithLang(errorx.LangFromContext(ss.Context())).Err()
 			e.SetTrailer(ss.Context())
 		}
+		if !isAuthRejection(grpcErr) {
+			log.RpcErrorf(ss.Context(), "%+v", err)
+		}
+		err = grpcErr
 	}
 	return err
 }
+
+// isAuthRejection 认证和授权失败已经由认证、授权拦截器记录了Warn日志和审计日志，不再打印错误日志，
+// 避免扫描流量刷屏错误日志和告警
+func isAuthRejection(err error) bool {
+	code := status.Code(err)
+	return code == codes.Unauthenticated || code == codes.PermissionDenied
+}
@@ -4,7 +4,13 @@ import (
 	"context"
 	"testing"
 
+	"github.com/sirupsen/logrus"
+	logtest "github.com/sirupsen/logrus/hooks/test"
+	"github.com/stretchr/testify/assert"
+	"google.golang.org/genproto/googleapis/rpc/errdetails"
 	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
 	"singer.com/basic/errorx"
 )
 
@@ -27,3 +33,42 @@ func TestStreamErrorInterceptor(t *testing.T) {
 
 	t.Log(err)
 }
+
+func TestUnaryErrorInterceptorWithDetails(t *testing.T) {
+	_, err := UnaryErrorInterceptor(context.Background(), nil, &grpc.UnaryServerInfo{
+		FullMethod: "/Unary/ErrorDetails",
+	}, func(ctx context.Context, req interface{}) (interface{}, error) {
+		return nil, errorx.WithDetails(errorx.Wrap(errorx.REUQEST_PARAM_ERROR, "age out of range"),
+			errorx.BadRequest(errorx.FieldViolation("age", "must be positive")))
+	})
+
+	st, ok := status.FromError(err)
+	assert.True(t, ok)
+	assert.Equal(t, codes.InvalidArgument, st.Code())
+	assert.Len(t, st.Details(), 2)
+	br, ok := st.Details()[0].(*errdetails.BadRequest)
+	assert.True(t, ok)
+	assert.Equal(t, "age", br.GetFieldViolations()[0].GetField())
+	assert.Equal(t, errorx.REUQEST_PARAM_ERROR, errorx.CodeFromError(err))
+}
+
+func TestUnaryErrorInterceptorAuthRejection(t *testing.T) {
+	hook := logtest.NewGlobal()
+	defer logrus.StandardLogger().ReplaceHooks(make(logrus.LevelHooks))
+
+	info := &grpc.UnaryServerInfo{FullMethod: "/Unary/Auth"}
+	for _, code := range []errorx.ErrorCode{errorx.UNAUTHENTICATED_ERROR, errorx.PERMISSION_DENIED_ERROR} {
+		_, err := UnaryErrorInterceptor(context.Background(), nil, info, func(ctx context.Context, req interface{}) (interface{}, error) {
+			return nil, errorx.Wrap(code, "rejected")
+		})
+		assert.Equal(t, code, errorx.CodeFromError(err))
+	}
+	// 认证和授权失败不打印错误日志
+	assert.Empty(t, hook.AllEntries())
+
+	UnaryErrorInterceptor(context.Background(), nil, info, func(ctx context.Context, req interface{}) (interface{}, error) {
+		return nil, errorx.Wrap(errorx.SERVER_COMMON_ERROR, "db error")
+	})
+	assert.Len(t, hook.AllEntries(), 1)
+	assert.Equal(t, logrus.ErrorLevel, hook.LastEntry().Level)
+}
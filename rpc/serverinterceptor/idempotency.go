@@ -0,0 +1,176 @@
+package serverinterceptor
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"strconv"
+
+	protoV1 "github.com/golang/protobuf/proto"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/types/known/anypb"
+	"singer.com/basic/auth"
+	"singer.com/basic/errorx"
+	"singer.com/basic/idempotency"
+	"singer.com/basic/log"
+	"singer.com/basic/meta"
+)
+
+// 重放的响应在header中带有该标记
+const idempotentReplayedKey = "x-idempotent-replayed"
+
+// UnaryIdempotencyInterceptor 对携带幂等键的请求，保存第一次处理的响应或错误，之后相同方法、调用方和幂等键的请求直接返回保存的结果。
+// 同一幂等键的请求正在处理时，重复的请求返回codes.Aborted；幂等键相同但请求内容不同时返回codes.InvalidArgument。
+// 超时、取消、限流、内部错误等可能是临时的错误不会保存，调用方可以使用相同的幂等键重试
+func UnaryIdempotencyInterceptor(cfg idempotency.Config) grpc.UnaryServerInterceptor {
+	cfg = cfg.WithDefaults()
+	return func(ctx context.Context, req interface{}, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (interface{}, error) {
+		idemKey := meta.GetIdempotencyKey(ctx)
+		if idemKey == "" {
+			return handler(ctx, req)
+		}
+		key := idempotencyStoreKey(ctx, info.FullMethod, idemKey)
+		reqHash := requestHash(req)
+		logger := log.FromContext(ctx)
+
+		if resp, err, ok := replay(ctx, cfg.Store, key, reqHash); ok {
+			return resp, err
+		}
+		unlock, ok, err := cfg.Store.Lock(ctx, key, cfg.LockTTL)
+		if err != nil {
+			// 存储不可用时不阻塞请求
+			logger.Errorf("idempotency: lock %s failed, err: %v", key, err)
+			return handler(ctx, req)
+		}
+		if !ok {
+			// 拿锁前第一个请求可能刚好处理完
+			if resp, err, ok := replay(ctx, cfg.Store, key, reqHash); ok {
+				return resp, err
+			}
+			return nil, status.Error(codes.Aborted, "request with the same idempotency key is in progress")
+		}
+		defer unlock()
+
+		resp, err := handler(ctx, req)
+		if record, ok := newRecord(resp, err); ok {
+			record.RequestHash = reqHash
+			if serr := cfg.Store.Save(ctx, key, record, cfg.TTL); serr != nil {
+				logger.Errorf("idempotency: save %s failed, err: %v", key, serr)
+			}
+		}
+		return resp, err
+	}
+}
+
+// idempotencyStoreKey 有认证身份时按身份隔离，避免其他调用方使用相同的幂等键读取到别人的响应。
+// 身份带长度前缀，不同的身份和幂等键组合不会得到相同的key
+func idempotencyStoreKey(ctx context.Context, fullMethod, idemKey string) string {
+	var principal string
+	if p, ok := auth.FromContext(ctx); ok {
+		principal = p.Type + ":" + p.Subject
+	}
+	return fullMethod + ":" + strconv.Itoa(len(principal)) + ":" + principal + ":" + idemKey
+}
+
+// requestHash 请求的确定性序列化结果的hash，非proto请求返回nil
+func requestHash(req interface{}) []byte {
+	msg, ok := req.(proto.Message)
+	if !ok {
+		return nil
+	}
+	data, err := proto.MarshalOptions{Deterministic: true}.Marshal(msg)
+	if err != nil {
+		return nil
+	}
+	h := sha256.Sum256(data)
+	return h[:]
+}
+
+// replay 返回已保存的结果
+func replay(ctx context.Context, store idempotency.Store, key string, reqHash []byte) (interface{}, error, bool) {
+	record, err := store.Get(ctx, key)
+	if err != nil {
+		log.FromContext(ctx).Errorf("idempotency: get %s failed, err: %v", key, err)
+		return nil, nil, false
+	}
+	if record == nil {
+		return nil, nil, false
+	}
+	if record.RequestHash != nil && reqHash != nil && !bytes.Equal(record.RequestHash, reqHash) {
+		return nil, status.Error(codes.InvalidArgument, "idempotency key is reused with a different request"), true
+	}
+	grpc.SetHeader(ctx, metadata.Pairs(idempotentReplayedKey, "true"))
+	if record.Code != 0 {
+		return nil, replayedError(ctx, record), true
+	}
+	if record.Status != nil {
+		return nil, status.ErrorProto(record.Status), true
+	}
+	resp, err := record.Response.UnmarshalNew()
+	if err != nil {
+		log.FromContext(ctx).Errorf("idempotency: unmarshal %s failed, err: %v", key, err)
+		return nil, nil, false
+	}
+	return resp, nil, true
+}
+
+func newRecord(resp interface{}, err error) (*idempotency.Record, bool) {
+	if err != nil {
+		st := errorStatus(err)
+		switch st.Code() {
+		case codes.Canceled, codes.DeadlineExceeded, codes.ResourceExhausted, codes.Aborted, codes.Unavailable,
+			codes.Unknown, codes.Internal:
+			return nil, false
+		}
+		if e, ok := errorx.Cause(err).(*errorx.CodeError); ok && errorx.IsCodeErr(e.GetErrCode()) {
+			return codeRecord(e), true
+		}
+		return &idempotency.Record{Status: st.Proto()}, true
+	}
+	msg, ok := resp.(proto.Message)
+	if !ok {
+		return nil, false
+	}
+	a, merr := anypb.New(msg)
+	if merr != nil {
+		return nil, false
+	}
+	return &idempotency.Record{Response: a}, true
+}
+
+// codeRecord 保存业务错误码和错误详情，不保存第一次请求语言的脱敏信息
+func codeRecord(e *errorx.CodeError) *idempotency.Record {
+	record := &idempotency.Record{Code: uint32(e.GetErrCode())}
+	for _, d := range e.Details() {
+		if a, err := anypb.New(protoV1.MessageV2(d)); err == nil {
+			record.Details = append(record.Details, a)
+		}
+	}
+	return record
+}
+
+// replayedError 按请求的语言重新生成errorx错误的status，与第一次请求经过UnaryErrorInterceptor的结果一致
+func replayedError(ctx context.Context, record *idempotency.Record) error {
+	e := errorx.NewErrCode(errorx.ErrorCode(record.Code))
+	for _, a := range record.Details {
+		if m, err := a.UnmarshalNew(); err == nil {
+			e.WithDetails(protoV1.MessageV1(m))
+		}
+	}
+	return e.GRPCStatusWithLang(errorx.LangFromContext(ctx)).Err()
+}
+
+// errorStatus 自定义错误转换为携带业务错误码的status，重放时调用方仍然可以通过errorx.FromError获取错误码
+func errorStatus(err error) *status.Status {
+	if e, ok := errorx.Cause(err).(*errorx.CodeError); ok {
+		return e.GRPCStatus()
+	}
+	if st, ok := status.FromError(err); ok {
+		return st
+	}
+	return status.FromContextError(err)
+}
@@ -0,0 +1,153 @@
+package serverinterceptor
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/types/known/wrapperspb"
+	"singer.com/basic/auth"
+	"singer.com/basic/errorx"
+	"singer.com/basic/idempotency"
+)
+
+func idempotencyContext(key string) context.Context {
+	return metadata.NewIncomingContext(context.Background(), metadata.Pairs("x-idempotency-key", key))
+}
+
+func TestUnaryIdempotencyInterceptor(t *testing.T) {
+	interceptor := UnaryIdempotencyInterceptor(idempotency.Config{Store: idempotency.NewLocalStore()})
+	info := &grpc.UnaryServerInfo{FullMethod: "/api.Order/Create"}
+	calls := 0
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		calls++
+		return wrapperspb.String("order-1"), nil
+	}
+
+	for i := 0; i < 3; i++ {
+		resp, err := interceptor(idempotencyContext("k1"), nil, info, handler)
+		assert.Nil(t, err)
+		assert.True(t, proto.Equal(wrapperspb.String("order-1"), resp.(proto.Message)))
+	}
+	assert.Equal(t, 1, calls)
+
+	// 不同的方法或幂等键
+	interceptor(idempotencyContext("k1"), nil, &grpc.UnaryServerInfo{FullMethod: "/api.Order/Update"}, handler)
+	interceptor(idempotencyContext("k2"), nil, info, handler)
+	assert.Equal(t, 3, calls)
+
+	// 没有幂等键
+	interceptor(context.Background(), nil, info, handler)
+	interceptor(context.Background(), nil, info, handler)
+	assert.Equal(t, 5, calls)
+}
+
+func TestUnaryIdempotencyInterceptorErrors(t *testing.T) {
+	interceptor := UnaryIdempotencyInterceptor(idempotency.Config{Store: idempotency.NewLocalStore()})
+	info := &grpc.UnaryServerInfo{FullMethod: "/api.Order/Create"}
+	calls := 0
+	var handlerErr error
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		calls++
+		return nil, handlerErr
+	}
+
+	// 确定性的错误会保存，重放时仍然可以获取业务错误码
+	handlerErr = errorx.Wrap(errorx.REUQEST_PARAM_ERROR, "invalid amount")
+	interceptor(idempotencyContext("k1"), nil, info, handler)
+	_, err := interceptor(idempotencyContext("k1"), nil, info, handler)
+	assert.Equal(t, 1, calls)
+	assert.Equal(t, codes.InvalidArgument, status.Code(err))
+	assert.True(t, errorx.IsCode(err, errorx.REUQEST_PARAM_ERROR))
+
+	// 重放的错误使用重放请求的语言，并保留错误详情
+	handlerErr = errorx.WithDetails(errorx.Wrap(errorx.REUQEST_PARAM_ERROR, "invalid amount"),
+		errorx.BadRequest(errorx.FieldViolation("amount", "must be positive")))
+	zh := metadata.NewIncomingContext(context.Background(), metadata.Pairs("x-idempotency-key", "k3", "accept-language", "zh-CN"))
+	en := metadata.NewIncomingContext(context.Background(), metadata.Pairs("x-idempotency-key", "k3", "accept-language", "en-US"))
+	interceptor(zh, nil, info, handler)
+	_, err = interceptor(en, nil, info, handler)
+	assert.Equal(t, 2, calls)
+	st := status.Convert(err)
+	assert.Equal(t, codes.InvalidArgument, st.Code())
+	assert.Equal(t, "Invalid request parameter", st.Message())
+	assert.True(t, errorx.IsCode(err, errorx.REUQEST_PARAM_ERROR))
+	e, _ := errorx.FromError(err)
+	assert.Equal(t, "amount", e.BadRequest().GetFieldViolations()[0].GetField())
+	assert.Equal(t, "en-US", e.LocalizedMessage().GetLocale())
+
+	// 临时错误不保存
+	handlerErr = status.Error(codes.Unavailable, "db unavailable")
+	interceptor(idempotencyContext("k2"), nil, info, handler)
+	interceptor(idempotencyContext("k2"), nil, info, handler)
+	assert.Equal(t, 4, calls)
+}
+
+func TestUnaryIdempotencyInterceptorInProgress(t *testing.T) {
+	interceptor := UnaryIdempotencyInterceptor(idempotency.Config{Store: idempotency.NewLocalStore()})
+	info := &grpc.UnaryServerInfo{FullMethod: "/api.Order/Create"}
+	started, release := make(chan struct{}), make(chan struct{})
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		interceptor(idempotencyContext("k1"), nil, info, func(ctx context.Context, req interface{}) (interface{}, error) {
+			close(started)
+			<-release
+			return wrapperspb.String("order-1"), nil
+		})
+	}()
+	<-started
+
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		t.Error("duplicate request should not be handled")
+		return nil, nil
+	}
+	_, err := interceptor(idempotencyContext("k1"), nil, info, handler)
+	assert.Equal(t, codes.Aborted, status.Code(err))
+
+	close(release)
+	select {
+	case <-done:
+	case <-time.After(time.Second):
+		t.Fatal("first request not finished")
+	}
+	resp, err := interceptor(idempotencyContext("k1"), nil, info, handler)
+	assert.Nil(t, err)
+	assert.True(t, proto.Equal(wrapperspb.String("order-1"), resp.(proto.Message)))
+}
+
+func TestUnaryIdempotencyInterceptorScope(t *testing.T) {
+	interceptor := UnaryIdempotencyInterceptor(idempotency.Config{Store: idempotency.NewLocalStore()})
+	info := &grpc.UnaryServerInfo{FullMethod: "/api.Order/Create"}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		p, _ := auth.FromContext(ctx)
+		return wrapperspb.String("order-of-" + p.Subject), nil
+	}
+	principalContext := func(subject string) context.Context {
+		return auth.NewContext(idempotencyContext("k1"), &auth.Principal{Type: "jwt", Subject: subject})
+	}
+
+	req := wrapperspb.String("amount=1")
+	resp, err := interceptor(principalContext("alice"), req, info, handler)
+	assert.Nil(t, err)
+	assert.Equal(t, "order-of-alice", resp.(*wrapperspb.StringValue).Value)
+
+	// 其他调用方使用相同的幂等键不会读取到alice的响应
+	resp, err = interceptor(principalContext("bob"), req, info, handler)
+	assert.Nil(t, err)
+	assert.Equal(t, "order-of-bob", resp.(*wrapperspb.StringValue).Value)
+
+	// 相同幂等键不同的请求内容
+	_, err = interceptor(principalContext("alice"), wrapperspb.String("amount=2"), info, handler)
+	assert.Equal(t, codes.InvalidArgument, status.Code(err))
+
+	resp, err = interceptor(principalContext("alice"), wrapperspb.String("amount=1"), info, handler)
+	assert.Nil(t, err)
+	assert.Equal(t, "order-of-alice", resp.(*wrapperspb.StringValue).Value)
+}
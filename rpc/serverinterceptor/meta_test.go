@@ -7,6 +7,7 @@ import (
 	"github.com/stretchr/testify/assert"
 	"google.golang.org/grpc"
 	"google.golang.org/grpc/metadata"
+	"singer.com/basic/log"
 )
 
 func TestUnaryGenerateMetadataInterceptor(t *testing.T) {
@@ -17,6 +18,9 @@ func TestUnaryGenerateMetadataInterceptor(t *testing.T) {
 			md, exist := metadata.FromIncomingContext(ctx)
 			assert.EqualValues(t, exist, true)
 			t.Log(md)
+			entry := log.FromContext(ctx)
+			assert.Equal(t, "/Unary/Meta", entry.Data["Method"])
+			assert.Equal(t, md.Get("requestid")[0], entry.Data["RequestId"])
 			return nil, nil
 		})
 }
@@ -0,0 +1,54 @@
+package serverinterceptor
+
+import (
+	"context"
+
+	"google.golang.org/grpc"
+	"singer.com/basic/metric"
+)
+
+// UnaryMetricsInterceptor 记录请求数、错误数、耗时、处理中的请求数和消息大小，
+// 需要放在recovery和error拦截器之前，使panic和自定义错误都能按grpc错误码统计
+func UnaryMetricsInterceptor(ctx context.Context, req interface{}, info *grpc.UnaryServerInfo,
+	handler grpc.UnaryHandler) (resp interface{}, err error) {
+	r := metric.StartRPC(metric.RPCServer, info.FullMethod)
+	r.MsgReceived(req)
+	defer func() {
+		if err == nil {
+			r.MsgSent(resp)
+		}
+		r.Done(err)
+	}()
+	return handler(ctx, req)
+}
+
+// StreamMetricsInterceptor 同UnaryMetricsInterceptor，消息大小按每条消息统计
+func StreamMetricsInterceptor(srv interface{}, ss grpc.ServerStream, info *grpc.StreamServerInfo,
+	handler grpc.StreamHandler) (err error) {
+	r := metric.StartRPC(metric.RPCServer, info.FullMethod)
+	defer func() {
+		r.Done(err)
+	}()
+	return handler(srv, &metricsServerStream{ServerStream: ss, recorder: r})
+}
+
+type metricsServerStream struct {
+	grpc.ServerStream
+	recorder *metric.RPCRecorder
+}
+
+func (s *metricsServerStream) SendMsg(m interface{}) error {
+	err := s.ServerStream.SendMsg(m)
+	if err == nil {
+		s.recorder.MsgSent(m)
+	}
+	return err
+}
+
+func (s *metricsServerStream) RecvMsg(m interface{}) error {
+	err := s.ServerStream.RecvMsg(m)
+	if err == nil {
+		s.recorder.MsgReceived(m)
+	}
+	return err
+}
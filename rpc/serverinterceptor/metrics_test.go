@@ -0,0 +1,55 @@
+package serverinterceptor
+
+import (
+	"context"
+	"testing"
+
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"github.com/stretchr/testify/assert"
+	"google.golang.org/grpc"
+	"google.golang.org/protobuf/types/known/wrapperspb"
+	"singer.com/basic/metric"
+)
+
+func TestUnaryMetricsInterceptor(t *testing.T) {
+	interceptor := func(handler grpc.UnaryHandler) {
+		UnaryMetricsInterceptor(context.Background(), wrapperspb.String("req"),
+			&grpc.UnaryServerInfo{FullMethod: "/pb.Metrics/Get"}, handler)
+	}
+	interceptor(func(ctx context.Context, req interface{}) (interface{}, error) {
+		return wrapperspb.String("resp"), nil
+	})
+	// panic由recovery拦截器转换为Internal错误
+	interceptor(func(ctx context.Context, req interface{}) (interface{}, error) {
+		return UnaryCrashInterceptor(ctx, req, &grpc.UnaryServerInfo{FullMethod: "/pb.Metrics/Get"},
+			func(ctx context.Context, req interface{}) (interface{}, error) {
+				panic("boom")
+			})
+	})
+
+	assert.Equal(t, float64(1), counterValue(t, "rpc_server_requests_total", "OK"))
+	assert.Equal(t, float64(1), counterValue(t, "rpc_server_requests_total", "Internal"))
+	assert.Equal(t, float64(1), counterValue(t, "rpc_server_errors_total", "Internal"))
+	assert.Equal(t, float64(0), counterValue(t, "rpc_server_errors_total", "OK"))
+	assert.Equal(t, float64(1), testutil.ToFloat64(panicCounter.WithLabelValues("pb.Metrics", "Get")))
+}
+
+func counterValue(t *testing.T, name, code string) float64 {
+	families, err := metric.Gatherer().Gather()
+	assert.Nil(t, err)
+	for _, mf := range families {
+		if mf.GetName() != name {
+			continue
+		}
+		for _, m := range mf.GetMetric() {
+			labels := map[string]string{}
+			for _, l := range m.GetLabel() {
+				labels[l.GetName()] = l.GetValue()
+			}
+			if labels["grpc_service"] == "pb.Metrics" && labels["code"] == code {
+				return m.GetCounter().GetValue()
+			}
+		}
+	}
+	return 0
+}
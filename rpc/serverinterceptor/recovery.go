@@ -4,27 +4,44 @@ import (
 	"context"
 	"runtime"
 
+	"github.com/prometheus/client_golang/prometheus"
 	"google.golang.org/grpc"
 	"google.golang.org/grpc/codes"
 	"google.golang.org/grpc/status"
 	"singer.com/basic/log"
+	"singer.com/basic/meta"
+	"singer.com/basic/metric"
+	"singer.com/util/recovery"
 )
 
+// 上报的请求内容最大长度
+const maxCrashRequestLen = 4 << 10
+
+var panicCounter = metric.NewCounterVec(prometheus.CounterOpts{
+	Subsystem: "rpc_server",
+	Name:      "panics_total",
+	Help:      "Total number of panics recovered in RPC handlers.",
+}, "grpc_service", "grpc_method")
+
 // StreamCrashInterceptor catches panics in processing stream requests and recovers.
-func StreamCrashInterceptor(svr interface{}, stream grpc.ServerStream, _ *grpc.StreamServerInfo,
+func StreamCrashInterceptor(svr interface{}, stream grpc.ServerStream, info *grpc.StreamServerInfo,
 	handler grpc.StreamHandler) (err error) {
 	defer handleCrash(func(r interface{}) {
-		err = toPanicError(stream.Context(), r)
+		ctx := context.Background()
+		if stream != nil {
+			ctx = stream.Context()
+		}
+		err = toPanicError(ctx, info.FullMethod, nil, r)
 	})
 
 	return handler(svr, stream)
 }
 
 // UnaryCrashInterceptor catches panics in processing unary requests and recovers.
-func UnaryCrashInterceptor(ctx context.Context, req interface{}, _ *grpc.UnaryServerInfo,
+func UnaryCrashInterceptor(ctx context.Context, req interface{}, info *grpc.UnaryServerInfo,
 	handler grpc.UnaryHandler) (resp interface{}, err error) {
 	defer handleCrash(func(r interface{}) {
-		err = toPanicError(ctx, r)
+		err = toPanicError(ctx, info.FullMethod, req, r)
 	})
 
 	return handler(ctx, req)
@@ -36,7 +53,8 @@ func handleCrash(handler func(interface{})) {
 	}
 }
 
-func toPanicError(ctx context.Context, r interface{}) error {
+// toPanicError 打印panic日志，统计每个方法的panic次数，并上报到recovery.SetReporter设置的reporter
+func toPanicError(ctx context.Context, method string, req, r interface{}) error {
 	const size = 64 << 10
 	stacktrace := make([]byte, size)
 	stacktrace = stacktrace[:runtime.Stack(stacktrace, false)]
@@ -45,5 +63,14 @@ func toPanicError(ctx context.Context, r interface{}) error {
 	} else {
 		log.RpcPanicf(ctx, "Observed a panic: %#v (%v)\n%s", r, r, stacktrace)
 	}
+
+	service, name := metric.SplitMethodName(method)
+	panicCounter.WithLabelValues(service, name).Inc()
+
+	report := recovery.NewCrashReport(r, stacktrace)
+	report.RequestId = meta.GetRequestId(ctx)
+	report.Method = method
+	report.Request = recovery.SanitizeRequest(req, maxCrashRequestLen)
+	recovery.Report(ctx, report)
 	return status.Error(codes.Internal, "panic")
 }
@@ -6,6 +6,10 @@ import (
 
 	"github.com/stretchr/testify/assert"
 	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+	"singer.com/util/recovery"
 )
 
 func TestUnaryCrashInterceptor(t *testing.T) {
@@ -27,3 +31,31 @@ func TestStreamCrashInterceptor(t *testing.T) {
 		})
 	})
 }
+
+type crashReporter struct {
+	reports []*recovery.CrashReport
+}
+
+func (c *crashReporter) Report(_ context.Context, r *recovery.CrashReport) error {
+	c.reports = append(c.reports, r)
+	return nil
+}
+
+func TestUnaryCrashInterceptorReport(t *testing.T) {
+	cr := &crashReporter{}
+	recovery.SetReporter(cr)
+	defer recovery.SetReporter(nil)
+
+	ctx := metadata.NewIncomingContext(context.Background(), metadata.Pairs("requestid", "crash-req-id"))
+	_, err := UnaryCrashInterceptor(ctx, map[string]string{"user": "singer", "token": "secret-token"}, &grpc.UnaryServerInfo{
+		FullMethod: "/Crash/Report",
+	}, func(ctx context.Context, req interface{}) (interface{}, error) {
+		panic("crash report")
+	})
+	assert.Equal(t, codes.Internal, status.Code(err))
+	assert.Len(t, cr.reports, 1)
+	assert.Equal(t, "/Crash/Report", cr.reports[0].Method)
+	assert.Equal(t, "crash-req-id", cr.reports[0].RequestId)
+	assert.Contains(t, cr.reports[0].Request, "singer")
+	assert.NotContains(t, cr.reports[0].Request, "secret-token")
+}
@@ -0,0 +1,32 @@
+package serverinterceptor
+
+import (
+	"context"
+
+	"google.golang.org/grpc"
+	"singer.com/basic/meta"
+	"singer.com/basic/trace"
+)
+
+// UnaryDebugSamplingInterceptor 请求携带调试标记时强制采样整条调用链，上游调用链没有采样(如经过采样率为0的网关)时也会采样，
+// 需要放在tracing拦截器之前
+func UnaryDebugSamplingInterceptor(ctx context.Context, req interface{}, info *grpc.UnaryServerInfo,
+	handler grpc.UnaryHandler) (interface{}, error) {
+	if !meta.IsDebug(ctx) {
+		return handler(ctx, req)
+	}
+	span, ctx := trace.StartDebugSpan(ctx, info.FullMethod)
+	defer span.Finish()
+	return handler(ctx, req)
+}
+
+// StreamDebugSamplingInterceptor 同UnaryDebugSamplingInterceptor
+func StreamDebugSamplingInterceptor(srv interface{}, ss grpc.ServerStream, info *grpc.StreamServerInfo,
+	handler grpc.StreamHandler) error {
+	if !meta.IsDebug(ss.Context()) {
+		return handler(srv, ss)
+	}
+	span, ctx := trace.StartDebugSpan(ss.Context(), info.FullMethod)
+	defer span.Finish()
+	return handler(srv, meta.NewServerStream(ctx, ss))
+}
@@ -0,0 +1,45 @@
+package serverinterceptor
+
+import (
+	"context"
+	"testing"
+
+	"github.com/opentracing/opentracing-go"
+	"github.com/opentracing/opentracing-go/mocktracer"
+	"github.com/stretchr/testify/assert"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/metadata"
+)
+
+func TestUnaryDebugSamplingInterceptor(t *testing.T) {
+	tracer := mocktracer.New()
+	old := opentracing.GlobalTracer()
+	opentracing.SetGlobalTracer(tracer)
+	defer opentracing.SetGlobalTracer(old)
+
+	info := &grpc.UnaryServerInfo{FullMethod: "/Debug/Sampling"}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return opentracing.SpanFromContext(ctx), nil
+	}
+
+	span, _ := UnaryDebugSamplingInterceptor(context.Background(), nil, info, handler)
+	assert.Nil(t, span)
+
+	ctx := metadata.NewIncomingContext(context.Background(), metadata.Pairs("x-debug", "1"))
+	span, _ = UnaryDebugSamplingInterceptor(ctx, nil, info, handler)
+	assert.NotNil(t, span)
+	assert.Len(t, tracer.FinishedSpans(), 1)
+	assert.EqualValues(t, 1, tracer.FinishedSpans()[0].Tag("sampling.priority"))
+
+	// 上游调用链没有采样时也强制采样
+	ctx = metadata.NewIncomingContext(context.Background(), metadata.Pairs("x-debug", "1", "traceparent", "00-0af7651916cd43dd8448eb211c80319c-b7ad6b7169203331-00"))
+	span, _ = UnaryDebugSamplingInterceptor(ctx, nil, info, handler)
+	assert.NotNil(t, span)
+	assert.Len(t, tracer.FinishedSpans(), 2)
+	assert.EqualValues(t, 1, tracer.FinishedSpans()[1].Tag("sampling.priority"))
+
+	// 没有调试标记时由上游决定
+	ctx = metadata.NewIncomingContext(context.Background(), metadata.Pairs("traceparent", "00-0af7651916cd43dd8448eb211c80319c-b7ad6b7169203331-00"))
+	span, _ = UnaryDebugSamplingInterceptor(ctx, nil, info, handler)
+	assert.Nil(t, span)
+}
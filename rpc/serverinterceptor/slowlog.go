@@ -10,6 +10,7 @@ import (
 	"google.golang.org/grpc"
 	"google.golang.org/grpc/peer"
 	"singer.com/basic/log"
+	"singer.com/basic/pprof"
 )
 
 const defaultSlowThreshold int64 = int64(time.Millisecond * 500)
@@ -43,6 +44,10 @@ func logDuration(ctx context.Context, method string, req interface{}, duration t
 		addr = client.Addr.String()
 	}
 
+	if duration > time.Duration(slowThreshold) {
+		pprof.RecordSlowRequest()
+	}
+
 	_, ok = notLoggingContentMethods.Load(method)
 	if !ok {
 		content, err := json.Marshal(req)
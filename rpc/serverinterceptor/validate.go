@@ -0,0 +1,99 @@
+package serverinterceptor
+
+import (
+	"context"
+
+	"google.golang.org/genproto/googleapis/rpc/errdetails"
+	"google.golang.org/grpc"
+	"singer.com/basic/errorx"
+)
+
+// protoc-gen-validate生成的校验方法，ValidateAll返回所有校验失败的字段，Validate只返回第一个
+
+type validatorAll interface {
+	ValidateAll() error
+}
+
+type validator interface {
+	Validate() error
+}
+
+// fieldError protoc-gen-validate生成的XxxValidationError
+type fieldError interface {
+	Field() string
+	Reason() string
+	Cause() error
+}
+
+// multiError protoc-gen-validate生成的XxxMultiError
+type multiError interface {
+	AllErrors() []error
+}
+
+// UnaryValidateInterceptor 请求实现了ValidateAll或Validate时校验请求，
+// 校验失败返回REUQEST_PARAM_ERROR，并附带BadRequest字段错误详情
+func UnaryValidateInterceptor(ctx context.Context, req interface{}, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (interface{}, error) {
+	if err := validate(req); err != nil {
+		return nil, err
+	}
+	return handler(ctx, req)
+}
+
+// StreamValidateInterceptor 校验流式请求中客户端发送的每一条消息
+func StreamValidateInterceptor(srv interface{}, ss grpc.ServerStream, info *grpc.StreamServerInfo, handler grpc.StreamHandler) error {
+	return handler(srv, &validateServerStream{ServerStream: ss})
+}
+
+type validateServerStream struct {
+	grpc.ServerStream
+}
+
+func (s *validateServerStream) RecvMsg(m interface{}) error {
+	if err := s.ServerStream.RecvMsg(m); err != nil {
+		return err
+	}
+	return validate(m)
+}
+
+func validate(req interface{}) error {
+	var err error
+	switch v := req.(type) {
+	case validatorAll:
+		err = v.ValidateAll()
+	case validator:
+		err = v.Validate()
+	}
+	if err == nil {
+		return nil
+	}
+	violations := fieldViolations("", err, nil)
+	return errorx.WithDetails(errorx.Wrap(errorx.REUQEST_PARAM_ERROR, err.Error()), errorx.BadRequest(violations...))
+}
+
+// fieldViolations 将校验错误展开为字段错误，嵌套消息的字段路径以.连接
+func fieldViolations(prefix string, err error, violations []*errdetails.BadRequest_FieldViolation) []*errdetails.BadRequest_FieldViolation {
+	switch e := err.(type) {
+	case multiError:
+		for _, err := range e.AllErrors() {
+			violations = fieldViolations(prefix, err, violations)
+		}
+	case fieldError:
+		field := e.Field()
+		if prefix != "" {
+			field = prefix + "." + field
+		}
+		// 嵌套消息校验失败时使用嵌套消息中的字段错误
+		if cause := e.Cause(); cause != nil {
+			if _, ok := cause.(fieldError); ok {
+				return fieldViolations(field, cause, violations)
+			}
+			if _, ok := cause.(multiError); ok {
+				return fieldViolations(field, cause, violations)
+			}
+		}
+		violations = append(violations, errorx.FieldViolation(field, e.Reason()))
+	default:
+		violations = append(violations, errorx.FieldViolation(prefix, err.Error()))
+	}
+	return violations
+}
@@ -0,0 +1,132 @@
+package serverinterceptor
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"google.golang.org/grpc"
+	"singer.com/basic/errorx"
+)
+
+// 模拟protoc-gen-validate生成的代码
+
+type testValidationError struct {
+	field  string
+	reason string
+	cause  error
+}
+
+func (e testValidationError) Field() string  { return e.field }
+func (e testValidationError) Reason() string { return e.reason }
+func (e testValidationError) Cause() error   { return e.cause }
+func (e testValidationError) Error() string  { return "invalid " + e.field + ": " + e.reason }
+
+type testMultiError []error
+
+func (m testMultiError) AllErrors() []error { return m }
+func (m testMultiError) Error() string {
+	var msgs []string
+	for _, err := range m {
+		msgs = append(msgs, err.Error())
+	}
+	return strings.Join(msgs, "; ")
+}
+
+type testAddress struct {
+	City string
+}
+
+func (a *testAddress) ValidateAll() error {
+	if a.City == "" {
+		return testMultiError{testValidationError{field: "City", reason: "value length must be at least 1 runes"}}
+	}
+	return nil
+}
+
+type testUserRequest struct {
+	Name    string
+	Age     int
+	Address *testAddress
+}
+
+func (r *testUserRequest) ValidateAll() error {
+	var errs testMultiError
+	if r.Name == "" {
+		errs = append(errs, testValidationError{field: "Name", reason: "value length must be at least 1 runes"})
+	}
+	if r.Age <= 0 {
+		errs = append(errs, testValidationError{field: "Age", reason: "value must be greater than 0"})
+	}
+	if err := r.Address.ValidateAll(); err != nil {
+		errs = append(errs, testValidationError{field: "Address", reason: "embedded message failed validation", cause: err})
+	}
+	if len(errs) > 0 {
+		return errs
+	}
+	return nil
+}
+
+type testSimpleRequest struct {
+	ID string
+}
+
+func (r *testSimpleRequest) Validate() error {
+	if r.ID == "" {
+		return errors.New("id is required")
+	}
+	return nil
+}
+
+func TestUnaryValidateInterceptor(t *testing.T) {
+	info := &grpc.UnaryServerInfo{FullMethod: "/api.User/Create"}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return "ok", nil
+	}
+
+	resp, err := UnaryValidateInterceptor(context.Background(), &testUserRequest{Name: "a", Age: 1, Address: &testAddress{City: "sz"}}, info, handler)
+	assert.Nil(t, err)
+	assert.Equal(t, "ok", resp)
+
+	_, err = UnaryValidateInterceptor(context.Background(), &testUserRequest{Age: 1, Address: &testAddress{}}, info, handler)
+	assert.True(t, errorx.IsCode(err, errorx.REUQEST_PARAM_ERROR))
+	ce, ok := errorx.FromError(err)
+	assert.True(t, ok)
+	violations := ce.BadRequest().GetFieldViolations()
+	assert.Len(t, violations, 2)
+	assert.Equal(t, "Name", violations[0].GetField())
+	assert.Equal(t, "Address.City", violations[1].GetField())
+
+	_, err = UnaryValidateInterceptor(context.Background(), &testSimpleRequest{}, info, handler)
+	assert.True(t, errorx.IsCode(err, errorx.REUQEST_PARAM_ERROR))
+	ce, _ = errorx.FromError(err)
+	assert.Equal(t, "id is required", ce.BadRequest().GetFieldViolations()[0].GetDescription())
+
+	// 没有校验方法
+	_, err = UnaryValidateInterceptor(context.Background(), struct{}{}, info, handler)
+	assert.Nil(t, err)
+}
+
+type recvStream struct {
+	mockedStream
+	msgs []*testSimpleRequest
+}
+
+func (s *recvStream) RecvMsg(m interface{}) error {
+	*m.(*testSimpleRequest) = *s.msgs[0]
+	s.msgs = s.msgs[1:]
+	return nil
+}
+
+func TestStreamValidateInterceptor(t *testing.T) {
+	ss := &recvStream{mockedStream: mockedStream{ctx: context.Background()}, msgs: []*testSimpleRequest{{ID: "1"}, {}}}
+	err := StreamValidateInterceptor(nil, ss, &grpc.StreamServerInfo{FullMethod: "/api.User/Upload"},
+		func(srv interface{}, stream grpc.ServerStream) error {
+			var req testSimpleRequest
+			assert.Nil(t, stream.RecvMsg(&req))
+			return stream.RecvMsg(&req)
+		})
+	assert.True(t, errorx.IsCode(err, errorx.REUQEST_PARAM_ERROR))
+}
@@ -1,8 +1,10 @@
 package recovery
 
 import (
+	"context"
 	"fmt"
 	"runtime"
+	"time"
 
 	"github.com/sirupsen/logrus"
 )
@@ -11,6 +13,9 @@ var (
 	reallyCrash = true
 )
 
+// flushTimeout is the longest time HandleCrash waits for queued crash reports before crashing
+const flushTimeout = 5 * time.Second
+
 var panicHandlers = []func(interface{}){logPanic}
 
 func HandleCrash(additionalHandlers ...func(interface{})) {
@@ -22,6 +27,10 @@ func HandleCrash(additionalHandlers ...func(interface{})) {
 			fn(r)
 		}
 		if reallyCrash {
+			// deliver the queued crash reports before the process exits
+			ctx, cancel := context.WithTimeout(context.Background(), flushTimeout)
+			Flush(ctx)
+			cancel()
 			// Actually proceed to panic.
 			panic(r)
 		}
@@ -61,4 +70,5 @@ func logPanic(r interface{}) {
 	} else {
 		logrus.Errorf("Observed a panic: %#v (%v)\n%s", r, r, stacktrace)
 	}
+	Report(context.Background(), NewCrashReport(r, stacktrace))
 }
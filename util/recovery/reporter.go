@@ -0,0 +1,396 @@
+package recovery
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+	"unicode/utf8"
+
+	"github.com/sirupsen/logrus"
+	"golang.org/x/time/rate"
+	"singer.com/basic/hash"
+)
+
+const (
+	defaultReportWindow = time.Minute
+	defaultReportLimit  = 1
+	defaultReportBurst  = 10
+	defaultReportQueue  = 100
+)
+
+// ErrReportDropped is returned by the async reporter when its queue is full.
+var ErrReportDropped = errors.New("crash report dropped: queue is full")
+
+// CrashReport describes a recovered panic.
+type CrashReport struct {
+	Time      time.Time `json:"time"`
+	Panic     string    `json:"panic"`
+	Stack     string    `json:"stack"`
+	Signature string    `json:"signature"` // identical stacks share the same signature
+	RequestId string    `json:"request_id,omitempty"`
+	Method    string    `json:"method,omitempty"`
+	Request   string    `json:"request,omitempty"` // sanitized request
+	// Suppressed is the number of reports with the same signature dropped since the last one was sent
+	Suppressed int `json:"suppressed,omitempty"`
+}
+
+// Reporter sends crash reports to a sink, e.g. a local file or a webhook.
+type Reporter interface {
+	Report(ctx context.Context, r *CrashReport) error
+}
+
+var (
+	reporterMtx sync.RWMutex
+	reporter    Reporter
+)
+
+// SetReporter sets the global reporter used by HandleCrash and the grpc crash interceptors.
+func SetReporter(r Reporter) {
+	reporterMtx.Lock()
+	defer reporterMtx.Unlock()
+	reporter = r
+}
+
+func currentReporter() Reporter {
+	reporterMtx.RLock()
+	defer reporterMtx.RUnlock()
+	return reporter
+}
+
+// Report sends r to the global reporter, it is a no-op if no reporter has been set.
+func Report(ctx context.Context, r *CrashReport) {
+	rp := currentReporter()
+	if rp == nil {
+		return
+	}
+	if err := rp.Report(ctx, r); err != nil {
+		logrus.Errorf("report crash %s failed: %v", r.Signature, err)
+	}
+}
+
+type flusher interface {
+	Flush(ctx context.Context) error
+}
+
+// Flush waits until the reports queued in the global reporter have been sent, see NewAsyncReporter.
+func Flush(ctx context.Context) error {
+	if f, ok := currentReporter().(flusher); ok {
+		return f.Flush(ctx)
+	}
+	return nil
+}
+
+var (
+	goroutineHeader = regexp.MustCompile(`^goroutine \d+ \[.*\]:$`)
+	frameArgs       = regexp.MustCompile(`\(0x[0-9a-f, .]*\)$|\(\.\.\.\)$`)
+	frameOffset     = regexp.MustCompile(` \+0x[0-9a-f]+$`)
+	createdBy       = regexp.MustCompile(` in goroutine \d+$`)
+)
+
+// NewCrashReport builds a report from the recovered value and the stack of the panicking goroutine.
+func NewCrashReport(r interface{}, stack []byte) *CrashReport {
+	var p string
+	if s, ok := r.(string); ok {
+		p = s
+	} else {
+		p = fmt.Sprintf("%#v (%v)", r, r)
+	}
+	return &CrashReport{
+		Time:      time.Now(),
+		Panic:     p,
+		Stack:     string(stack),
+		Signature: StackSignature(stack),
+	}
+}
+
+// StackSignature hashes the frames of a stack, ignoring goroutine ids, argument values and pc offsets,
+// so that the same panic site always gets the same signature.
+func StackSignature(stack []byte) string {
+	var b strings.Builder
+	for _, line := range strings.Split(string(stack), "\n") {
+		line = strings.TrimSpace(line)
+		if line == "" || goroutineHeader.MatchString(line) {
+			continue
+		}
+		line = frameArgs.ReplaceAllString(line, "")
+		line = frameOffset.ReplaceAllString(line, "")
+		line = createdBy.ReplaceAllString(line, "")
+		b.WriteString(line)
+		b.WriteByte('\n')
+	}
+	return hash.Md5Hex([]byte(b.String()))
+}
+
+var sensitiveKeys = []string{"password", "passwd", "token", "secret", "authorization", "credential", "apikey", "api_key"}
+
+// SanitizeRequest marshals req to json, masks sensitive fields and truncates the result to maxLen bytes.
+func SanitizeRequest(req interface{}, maxLen int) string {
+	if req == nil {
+		return ""
+	}
+	var out string
+	data, err := json.Marshal(req)
+	if err != nil {
+		// the content can not be masked, only output the type
+		out = fmt.Sprintf("<%T: unserializable>", req)
+	} else {
+		var v interface{}
+		if json.Unmarshal(data, &v) == nil {
+			data, _ = json.Marshal(maskSensitive(v))
+		}
+		out = string(data)
+	}
+	if maxLen > 0 && len(out) > maxLen {
+		// do not split a multi-byte character
+		n := maxLen
+		for n > 0 && !utf8.RuneStart(out[n]) {
+			n--
+		}
+		out = out[:n] + "...(truncated)"
+	}
+	return out
+}
+
+func maskSensitive(v interface{}) interface{} {
+	switch val := v.(type) {
+	case map[string]interface{}:
+		for k, sub := range val {
+			if isSensitiveKey(k) {
+				val[k] = "***"
+			} else {
+				val[k] = maskSensitive(sub)
+			}
+		}
+		return val
+	case []interface{}:
+		for i, sub := range val {
+			val[i] = maskSensitive(sub)
+		}
+		return val
+	default:
+		return v
+	}
+}
+
+func isSensitiveKey(k string) bool {
+	k = strings.ToLower(k)
+	for _, s := range sensitiveKeys {
+		if strings.Contains(k, s) {
+			return true
+		}
+	}
+	return false
+}
+
+type fileReporter struct {
+	mtx sync.Mutex
+	dir string
+}
+
+// NewFileReporter appends reports as json lines to dir/crash-YYYYMMDD.log.
+func NewFileReporter(dir string) (Reporter, error) {
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return nil, err
+	}
+	return &fileReporter{dir: dir}, nil
+}
+
+func (f *fileReporter) Report(_ context.Context, r *CrashReport) error {
+	data, err := json.Marshal(r)
+	if err != nil {
+		return err
+	}
+	f.mtx.Lock()
+	defer f.mtx.Unlock()
+	name := filepath.Join(f.dir, "crash-"+r.Time.Format("20060102")+".log")
+	file, err := os.OpenFile(name, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0644)
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+	_, err = file.Write(append(data, '\n'))
+	return err
+}
+
+type webhookReporter struct {
+	url    string
+	client *http.Client
+}
+
+// NewWebhookReporter posts reports as json to url, waiting at most timeout.
+// The post is synchronous, wrap it with NewAsyncReporter (or NewDefaultReporter) so that a panicking request is not blocked.
+// It is a minimal stub, adapt the payload to the receiver (e.g. an IM robot) when needed.
+func NewWebhookReporter(url string, timeout time.Duration) Reporter {
+	return &webhookReporter{url: url, client: &http.Client{Timeout: timeout}}
+}
+
+func (w *webhookReporter) Report(_ context.Context, r *CrashReport) error {
+	data, err := json.Marshal(r)
+	if err != nil {
+		return err
+	}
+	resp, err := w.client.Post(w.url, "application/json", bytes.NewReader(data))
+	if err != nil {
+		return err
+	}
+	resp.Body.Close()
+	if resp.StatusCode >= 300 {
+		return fmt.Errorf("webhook status: %s", resp.Status)
+	}
+	return nil
+}
+
+type limitedReporter struct {
+	next    Reporter
+	window  time.Duration
+	limiter *rate.Limiter
+
+	mtx  sync.Mutex
+	seen map[string]*seenCrash
+}
+
+type seenCrash struct {
+	last       time.Time
+	suppressed int
+}
+
+// NewLimitedReporter wraps next, reports with the same signature are sent at most once per window
+// and all reports are rate limited to limit per second with burst.
+// The number of dropped reports is attached to the next report with the same signature.
+func NewLimitedReporter(next Reporter, window time.Duration, limit float64, burst int) Reporter {
+	return &limitedReporter{
+		next:    next,
+		window:  window,
+		limiter: rate.NewLimiter(rate.Limit(limit), burst),
+		seen:    make(map[string]*seenCrash),
+	}
+}
+
+func (l *limitedReporter) Report(ctx context.Context, r *CrashReport) error {
+	l.mtx.Lock()
+	for sig, s := range l.seen {
+		if r.Time.Sub(s.last) > l.window && s.suppressed == 0 {
+			delete(l.seen, sig)
+		}
+	}
+	s, ok := l.seen[r.Signature]
+	if !ok {
+		s = &seenCrash{}
+		l.seen[r.Signature] = s
+	} else if r.Time.Sub(s.last) < l.window {
+		s.suppressed++
+		l.mtx.Unlock()
+		return nil
+	}
+	if !l.limiter.Allow() {
+		s.suppressed++
+		l.mtx.Unlock()
+		return nil
+	}
+	s.last = r.Time
+	r.Suppressed = s.suppressed
+	s.suppressed = 0
+	l.mtx.Unlock()
+	return l.next.Report(ctx, r)
+}
+
+// Flush flushes next if it queues reports.
+func (l *limitedReporter) Flush(ctx context.Context) error {
+	if f, ok := l.next.(flusher); ok {
+		return f.Flush(ctx)
+	}
+	return nil
+}
+
+type asyncReporter struct {
+	next    Reporter
+	queue   chan asyncItem
+	dropped uint64
+}
+
+// asyncItem is either a report or a flush marker
+type asyncItem struct {
+	report *CrashReport
+	done   chan struct{}
+}
+
+// NewAsyncReporter sends reports to next in a background goroutine through a queue of queueSize,
+// reports are dropped with ErrReportDropped when the queue is full so that the caller is never blocked.
+// HandleCrash flushes the queue before the process exits.
+func NewAsyncReporter(next Reporter, queueSize int) Reporter {
+	if queueSize <= 0 {
+		queueSize = defaultReportQueue
+	}
+	a := &asyncReporter{next: next, queue: make(chan asyncItem, queueSize)}
+	go a.run()
+	return a
+}
+
+func (a *asyncReporter) Report(_ context.Context, r *CrashReport) error {
+	select {
+	case a.queue <- asyncItem{report: r}:
+		return nil
+	default:
+		atomic.AddUint64(&a.dropped, 1)
+		return ErrReportDropped
+	}
+}
+
+// Flush waits until the reports queued before the call have been sent.
+func (a *asyncReporter) Flush(ctx context.Context) error {
+	done := make(chan struct{})
+	select {
+	case a.queue <- asyncItem{done: done}:
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+	select {
+	case <-done:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// Dropped returns the number of reports dropped because the queue was full.
+func (a *asyncReporter) Dropped() uint64 {
+	return atomic.LoadUint64(&a.dropped)
+}
+
+func (a *asyncReporter) run() {
+	for item := range a.queue {
+		if item.done != nil {
+			close(item.done)
+			continue
+		}
+		if err := a.next.Report(context.Background(), item.report); err != nil {
+			logrus.Errorf("report crash %s failed: %v", item.report.Signature, err)
+		}
+	}
+}
+
+// NewDefaultReporter wraps next with NewLimitedReporter (one report per signature per minute,
+// 1 report per second with burst 10) unless it is already limited, and with NewAsyncReporter (queue of 100)
+// so that reporting never blocks the panicking request.
+func NewDefaultReporter(next Reporter) Reporter {
+	if l, ok := next.(*limitedReporter); ok {
+		// keep the limits of next, only queue the reports
+		return &limitedReporter{
+			next:    NewAsyncReporter(l.next, defaultReportQueue),
+			window:  l.window,
+			limiter: l.limiter,
+			seen:    make(map[string]*seenCrash),
+		}
+	}
+	return NewLimitedReporter(NewAsyncReporter(next, defaultReportQueue), defaultReportWindow, defaultReportLimit, defaultReportBurst)
+}
@@ -0,0 +1,207 @@
+package recovery
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"runtime"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+type memReporter struct {
+	mtx     sync.Mutex
+	reports []*CrashReport
+}
+
+func (m *memReporter) Report(_ context.Context, r *CrashReport) error {
+	m.mtx.Lock()
+	defer m.mtx.Unlock()
+	m.reports = append(m.reports, r)
+	return nil
+}
+
+func panicStack() (stack []byte) {
+	defer func() {
+		if r := recover(); r != nil {
+			buf := make([]byte, 64<<10)
+			stack = buf[:runtime.Stack(buf, false)]
+		}
+	}()
+	var m map[string]int
+	m["crash"] = 1
+	return nil
+}
+
+func TestStackSignature(t *testing.T) {
+	stacks := make([][]byte, 2)
+	var wg sync.WaitGroup
+	for i := range stacks {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			stacks[i] = panicStack()
+		}(i)
+	}
+	wg.Wait()
+	s1, s2 := stacks[0], stacks[1]
+
+	assert.NotEqual(t, string(s1), string(s2)) //goroutine id is different
+	assert.Equal(t, StackSignature(s1), StackSignature(s2))
+	assert.NotEqual(t, StackSignature(s1), StackSignature([]byte("main.main()\n\t/app/main.go:10")))
+}
+
+func TestLimitedReporter(t *testing.T) {
+	mem := &memReporter{}
+	r := NewLimitedReporter(mem, time.Minute, 100, 100)
+
+	now := time.Now()
+	for i := 0; i < 5; i++ {
+		r.Report(context.Background(), &CrashReport{Time: now, Signature: "a"})
+	}
+	r.Report(context.Background(), &CrashReport{Time: now, Signature: "b"})
+	assert.Len(t, mem.reports, 2)
+
+	r.Report(context.Background(), &CrashReport{Time: now.Add(2 * time.Minute), Signature: "a"})
+	assert.Len(t, mem.reports, 3)
+	assert.Equal(t, 4, mem.reports[2].Suppressed)
+
+	limited := NewLimitedReporter(&memReporter{}, time.Minute, 0.001, 1)
+	lm := limited.(*limitedReporter).next.(*memReporter)
+	limited.Report(context.Background(), &CrashReport{Time: now, Signature: "x"})
+	limited.Report(context.Background(), &CrashReport{Time: now, Signature: "y"})
+	assert.Len(t, lm.reports, 1)
+}
+
+func TestSanitizeRequest(t *testing.T) {
+	req := map[string]interface{}{
+		"name":     "singer",
+		"password": "123456",
+		"auth":     map[string]interface{}{"AccessToken": "abc"},
+	}
+	s := SanitizeRequest(req, 0)
+	assert.Contains(t, s, `"name":"singer"`)
+	assert.NotContains(t, s, "123456")
+	assert.NotContains(t, s, "abc")
+
+	assert.Equal(t, `"abc...(truncated)`, SanitizeRequest("abcdef", 4))
+	assert.Equal(t, `"中...(truncated)`, SanitizeRequest("中文", 5))
+	assert.Equal(t, "", SanitizeRequest(nil, 0))
+
+	unserializable := struct {
+		Password string
+		C        chan int
+	}{Password: "123456"}
+	s = SanitizeRequest(unserializable, 0)
+	assert.NotContains(t, s, "123456")
+	assert.Contains(t, s, "unserializable")
+}
+
+func TestWebhookReporter(t *testing.T) {
+	var received []byte
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		received, _ = io.ReadAll(r.Body)
+	}))
+	defer srv.Close()
+
+	// the report has been delivered when Report returns
+	err := NewWebhookReporter(srv.URL, time.Second).Report(context.Background(), &CrashReport{Signature: "sig"})
+	assert.Nil(t, err)
+	assert.Contains(t, string(received), `"sig"`)
+
+	err = NewWebhookReporter("http://127.0.0.1:1", time.Second).Report(context.Background(), &CrashReport{})
+	assert.NotNil(t, err)
+}
+
+func TestFileReporter(t *testing.T) {
+	dir := t.TempDir()
+	r, err := NewFileReporter(dir)
+	assert.Nil(t, err)
+
+	report := NewCrashReport("file crash", panicStack())
+	assert.Nil(t, r.Report(context.Background(), report))
+
+	data, err := os.ReadFile(filepath.Join(dir, "crash-"+report.Time.Format("20060102")+".log"))
+	assert.Nil(t, err)
+	assert.Contains(t, string(data), report.Signature)
+}
+
+func TestHandleCrashReport(t *testing.T) {
+	mem := &memReporter{}
+	SetReporter(mem)
+	defer SetReporter(nil)
+
+	func() {
+		defer func() {
+			recover()
+		}()
+		defer HandleCrash()
+		panic("Test Report")
+	}()
+	assert.Len(t, mem.reports, 1)
+	assert.Equal(t, "Test Report", mem.reports[0].Panic)
+}
+
+// blockingReporter blocks until release is closed
+type blockingReporter struct {
+	memReporter
+	release chan struct{}
+}
+
+func (b *blockingReporter) Report(ctx context.Context, r *CrashReport) error {
+	<-b.release
+	return b.memReporter.Report(ctx, r)
+}
+
+func TestAsyncReporter(t *testing.T) {
+	slow := &blockingReporter{release: make(chan struct{})}
+	r := NewAsyncReporter(slow, 2)
+
+	// the caller is never blocked, reports are dropped when the queue is full
+	start := time.Now()
+	var dropped int
+	for i := 0; i < 5; i++ {
+		if r.Report(context.Background(), &CrashReport{Signature: "a"}) == ErrReportDropped {
+			dropped++
+		}
+	}
+	assert.Less(t, time.Since(start), 100*time.Millisecond)
+	assert.GreaterOrEqual(t, dropped, 2)
+	assert.Equal(t, uint64(dropped), r.(*asyncReporter).Dropped())
+
+	ctx, cancel := context.WithTimeout(context.Background(), 20*time.Millisecond)
+	assert.Equal(t, context.DeadlineExceeded, r.(flusher).Flush(ctx))
+	cancel()
+
+	close(slow.release)
+	assert.Nil(t, r.(flusher).Flush(context.Background()))
+	slow.mtx.Lock()
+	assert.Len(t, slow.reports, 5-dropped)
+	slow.mtx.Unlock()
+}
+
+func TestDefaultReporter(t *testing.T) {
+	mem := &memReporter{}
+	SetReporter(NewDefaultReporter(mem))
+	defer SetReporter(nil)
+
+	now := time.Now()
+	for i := 0; i < 3; i++ {
+		Report(context.Background(), &CrashReport{Time: now, Signature: "a"})
+	}
+	Report(context.Background(), &CrashReport{Time: now, Signature: "b"})
+	assert.Nil(t, Flush(context.Background()))
+	assert.Len(t, mem.reports, 2)
+
+	// the limits of an already limited reporter are kept
+	limited := NewDefaultReporter(NewLimitedReporter(mem, time.Hour, 100, 100)).(*limitedReporter)
+	assert.Equal(t, time.Hour, limited.window)
+	_, ok := limited.next.(*asyncReporter)
+	assert.True(t, ok)
+}